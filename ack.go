@@ -0,0 +1,108 @@
+package msgrouter
+
+import (
+	"errors"
+	"time"
+)
+
+// Ack is the token returned by an AckingComponent for a single delivery. The
+// component sends true to acknowledge the message or false to negatively
+// acknowledge it. Closing the channel without a value counts as a NACK.
+type Ack <-chan bool
+
+// AckingComponent is an optional interface for components which acknowledge
+// each delivery. When a destination implements it the router calls SendAck
+// instead of Send and retries NACKed or timed out deliveries according to its
+// RetryPolicy. Components not implementing it are treated as auto-ACK.
+type AckingComponent interface {
+	Component
+	SendAck(interface{}) (Ack, error)
+}
+
+// ErrNack is reported when a destination NACKs a delivery on its last attempt.
+var ErrNack = errors.New("Delivery negatively acknowledged")
+
+// ErrAckTimeout is reported when a destination fails to ACK a delivery within
+// RetryPolicy.AckTimeout on its last attempt.
+var ErrAckTimeout = errors.New("Timed out waiting for delivery acknowledgement")
+
+// ErrNoAck is reported when an AckingComponent's SendAck returns a nil Ack
+// without an error. Such a delivery can never be acknowledged, so it fails
+// without waiting and is not retried.
+var ErrNoAck = errors.New("Component returned no Ack")
+
+// RetryPolicy controls redelivery of NACKed or unacknowledged messages.
+type RetryPolicy struct {
+	// MaxRetries is the number of redeliveries after the first attempt.
+	MaxRetries int
+	// Backoff is the pause between attempts.
+	Backoff time.Duration
+	// AckTimeout bounds how long the router waits for an ACK. Zero waits
+	// forever.
+	AckTimeout time.Duration
+}
+
+// DefaultRetryPolicy is used by routers not configured with WithRetryPolicy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	Backoff:    10 * time.Millisecond,
+	AckTimeout: 5 * time.Second,
+}
+
+// WithRetryPolicy sets the policy used to redeliver to AckingComponents, and
+// to every destination under AtLeastOnce delivery semantics.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(r *GenericRouter) {
+		r.retry = p
+	}
+}
+
+// deliverAck sends to an AckingComponent, redelivering on NACK or ACK timeout
+// until the retry policy is exhausted. Errors returned by SendAck itself are
+// not retried.
+func (r *GenericRouter) deliverAck(ac AckingComponent, payload interface{}) error {
+
+	var err error
+	for attempt := 0; attempt <= r.retry.MaxRetries; attempt++ {
+		if attempt > 0 && r.retry.Backoff > 0 {
+			<-r.clock.After(r.retry.Backoff)
+		}
+
+		err = r.awaitAck(ac, payload)
+		if err != ErrNack && err != ErrAckTimeout {
+			return err
+		}
+	}
+
+	return err
+}
+
+// awaitAck performs a single delivery attempt and waits for its Ack.
+func (r *GenericRouter) awaitAck(ac AckingComponent, payload interface{}) error {
+
+	ack, err := ac.SendAck(payload)
+	if err != nil {
+		return err
+	}
+	// Receiving from a nil channel blocks forever
+	if ack == nil {
+		return ErrNoAck
+	}
+
+	var timeout <-chan time.Time
+	if r.retry.AckTimeout > 0 {
+		timer := r.clock.NewTimer(r.retry.AckTimeout)
+		defer timer.Stop()
+		timeout = timer.C()
+	}
+
+	select {
+	case ok := <-ack:
+		if !ok {
+			return ErrNack
+		}
+		return nil
+	case <-timeout:
+		return ErrAckTimeout
+	}
+}
@@ -0,0 +1,76 @@
+package msgrouter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// nackOnce NACKs its first delivery and ACKs the rest.
+type nackOnce struct {
+	testComponent
+}
+
+func (c *nackOnce) SendAck(payload interface{}) (Ack, error) {
+	ack := make(chan bool, 1)
+	ack <- c.count() > 0
+	c.Send(payload)
+	return ack, nil
+}
+
+func TestNackRetriesOnce(t *testing.T) {
+	r := newRouter(t, WithRetryPolicy(RetryPolicy{MaxRetries: 3}))
+	register(t, r, "src")
+	dest := &nackOnce{}
+	registerAs(t, r, "dest", dest)
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	if err := r.Send(msgMsg{src: "src", payload: "p"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	eventually(t, func() bool { return dest.count() == 2 })
+
+	// The ACKed retry is the last delivery
+	time.Sleep(20 * time.Millisecond)
+	if n := dest.count(); n != 2 {
+		t.Fatalf("delivered %d times, want 2", n)
+	}
+}
+
+// nilAcker returns no Ack at all.
+type nilAcker struct {
+	testComponent
+}
+
+func (c *nilAcker) SendAck(payload interface{}) (Ack, error) {
+	c.Send(payload)
+	return nil, nil
+}
+
+func TestNilAckFails(t *testing.T) {
+	dlq := make(chan DeadLetter, 1)
+	// No ACK timeout, so waiting on the nil Ack would never end
+	r := newRouter(t, WithRetryPolicy(RetryPolicy{MaxRetries: 3}), WithDeadLetterQueue(dlq), WithInlineDelivery())
+	register(t, r, "src")
+	dest := &nilAcker{}
+	registerAs(t, r, "dest", dest)
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	if err := r.Send(msgMsg{src: "src", payload: "p"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	within(t, "Flush", func() {
+		if err := r.Flush(context.Background()); err != nil {
+			t.Errorf("Flush: %v", err)
+		}
+	})
+	if dl := <-dlq; !errors.Is(dl.Err, ErrNoAck) {
+		t.Fatalf("dead letter %+v, want ErrNoAck", dl)
+	}
+	if n := dest.count(); n != 1 {
+		t.Fatalf("delivered %d times, want 1 without retries", n)
+	}
+}
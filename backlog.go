@@ -0,0 +1,42 @@
+package msgrouter
+
+// BacklogComponent is an optional interface for components which buffer
+// payloads, e.g. in a channel, and can report how many are waiting to be
+// processed.
+type BacklogComponent interface {
+	Component
+	Backlog() int
+}
+
+// ComponentBacklog returns how many messages each component has yet to
+// process: the deliveries waiting in its queue if the router was created
+// with WithDestinationQueues, plus the payloads it reports itself if it
+// implements BacklogComponent. Without destination queues, components not
+// implementing BacklogComponent are left out.
+func (r *GenericRouter) ComponentBacklog() map[ComponentID]int {
+	if !r.initialized() {
+		return nil
+	}
+
+	// Backlog is called without holding the registry lock
+	backlog := make(map[ComponentID]int)
+	for id, c := range r.rc.snapshot() {
+		if bc, ok := c.(BacklogComponent); ok {
+			backlog[id] = bc.Backlog()
+		} else if r.queues != nil {
+			backlog[id] = 0
+		}
+	}
+
+	if r.queues == nil {
+		return backlog
+	}
+
+	qs := r.queues
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	for id, q := range qs.queues {
+		backlog[id] += len(q.jobs)
+	}
+	return backlog
+}
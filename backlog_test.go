@@ -0,0 +1,74 @@
+package msgrouter
+
+import (
+	"context"
+	"testing"
+)
+
+// chanComponent buffers its payloads on a channel for a consumer to drain.
+type chanComponent struct {
+	testComponent
+	ch chan interface{}
+}
+
+func (c *chanComponent) Send(payload interface{}) error {
+	c.ch <- payload
+	return nil
+}
+
+func (c *chanComponent) Backlog() int {
+	return len(c.ch)
+}
+
+func TestComponentBacklog(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	dest := &chanComponent{ch: make(chan interface{}, 8)}
+	registerAs(t, r, "dest", dest)
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	for i := 0; i < 3; i++ {
+		if err := r.Send(msgMsg{src: "src", payload: i}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	<-dest.ch
+
+	backlog := r.ComponentBacklog()
+	if n := backlog["dest"]; n != 2 {
+		t.Fatalf("dest backlog %d, want 2 undrained", n)
+	}
+	if _, ok := backlog["src"]; ok {
+		t.Fatalf("backlog %v reports src, which can't tell", backlog)
+	}
+}
+
+func TestComponentBacklogCountsQueuedDeliveries(t *testing.T) {
+	r := newRouter(t, WithDestinationQueues(64, 1))
+	register(t, r, "src")
+	slow := &gateComponent{entered: make(chan struct{}), gate: make(chan struct{})}
+	registerAs(t, r, "slow", slow)
+	addRoute(t, r, "src", "slow")
+	start(t, r)
+
+	for i := 0; i < 10; i++ {
+		if err := r.Send(msgMsg{src: "src", payload: i}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	<-slow.entered
+	// The delivery held at the gate has left the queue
+	eventually(t, func() bool { return r.ComponentBacklog()["slow"] == 9 })
+
+	close(slow.gate)
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if n := r.ComponentBacklog()["slow"]; n != 0 {
+		t.Fatalf("slow backlog %d after Flush, want 0", n)
+	}
+}
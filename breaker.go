@@ -0,0 +1,127 @@
+package msgrouter
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is the dead letter error of deliveries skipped because the
+// destination's circuit breaker is open.
+var ErrCircuitOpen = errors.New("Destination circuit breaker is open")
+
+// breaker is a destination's circuit breaker. It is shared by delivery go
+// routines, so it is guarded by a mutex.
+type breaker struct {
+	mu       sync.Mutex
+	failures int
+	open     bool
+	openedAt time.Time
+	// probing is set while the single delivery let through after the cooldown
+	// is in flight
+	probing bool
+}
+
+// WithCircuitBreaker gives every destination a circuit breaker which opens
+// once n deliveries to it in a row have failed. A delivery to an
+// AckingComponent which is NACKed, or still not ACKed, once its retries are
+// exhausted counts as failed, like an error returned by Send. While the
+// breaker is open, deliveries to the destination are skipped and dead
+// lettered with ErrCircuitOpen. Once cooldown has passed a single delivery is
+// let through, closing the breaker if it succeeds and opening it again if it
+// fails. BreakerOpened and BreakerClosed events mark the transitions.
+func WithCircuitBreaker(n int, cooldown time.Duration) Option {
+	return func(r *GenericRouter) {
+		r.breakerAfter = n
+		r.breakerCooldown = cooldown
+	}
+}
+
+// breakerFor returns dest's circuit breaker, or nil if circuit breakers are
+// disabled.
+func (r *GenericRouter) breakerFor(dest ComponentID) *breaker {
+
+	if r.breakerAfter <= 0 {
+		return nil
+	}
+
+	r.breakerMu.Lock()
+	defer r.breakerMu.Unlock()
+	b, ok := r.breakers[dest]
+	if !ok {
+		b = &breaker{}
+		r.breakers[dest] = b
+	}
+	return b
+
+}
+
+// breakerAllows reports whether dest's circuit breaker lets m through. A
+// skipped message is recorded as skipped and dead lettered.
+func (r *GenericRouter) breakerAllows(src ComponentID, dest Component, m msgMsg, rec *detailRecorder) bool {
+
+	id, _ := dest.GetID()
+	b := r.breakerFor(id)
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	allowed := !b.open
+	if b.open && !b.probing && r.clock.Now().Sub(b.openedAt) >= r.breakerCooldown {
+		b.probing = true
+		allowed = true
+	}
+	b.mu.Unlock()
+	if allowed {
+		return true
+	}
+
+	rec.skip(dest, "circuit open")
+	r.countDropped(CircuitOpen, 1)
+	r.deadLetter(DeadLetter{
+		Src:     src,
+		Dest:    id,
+		Payload: m.payload,
+		Err:     ErrCircuitOpen,
+		Reason:  CircuitOpen,
+		Time:    r.clock.Now(),
+	})
+	return false
+
+}
+
+// trackBreaker records the outcome of a delivery to dest, opening its circuit
+// breaker once too many deliveries failed in a row.
+func (r *GenericRouter) trackBreaker(dest Component, err error) {
+
+	id, _ := dest.GetID()
+	b := r.breakerFor(id)
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	wasOpen := b.open
+	b.probing = false
+	if err == nil {
+		b.failures = 0
+		b.open = false
+	} else {
+		b.failures++
+		if b.open || b.failures >= r.breakerAfter {
+			b.open = true
+			b.openedAt = r.clock.Now()
+		}
+	}
+	isOpen := b.open
+	b.mu.Unlock()
+
+	switch {
+	case isOpen && !wasOpen:
+		r.emit(Event{Type: BreakerOpened, Dest: id})
+	case wasOpen && !isOpen:
+		r.emit(Event{Type: BreakerClosed, Dest: id})
+	}
+
+}
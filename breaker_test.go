@@ -0,0 +1,68 @@
+package msgrouter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAckTimeoutsOpenBreaker(t *testing.T) {
+	dlq := make(chan DeadLetter, 8)
+	events := make(chan Event, 8)
+	r := newRouter(t,
+		WithCircuitBreaker(2, 20*time.Millisecond),
+		WithRetryPolicy(RetryPolicy{AckTimeout: 5 * time.Millisecond}),
+		WithDeadLetterQueue(dlq),
+		WithEvents(events),
+		WithInlineDelivery(),
+	)
+	register(t, r, "src")
+	dest := &silentComponent{}
+	registerAs(t, r, "dest", dest)
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	send := func(payload interface{}) {
+		t.Helper()
+		if err := r.Send(msgMsg{src: "src", payload: payload}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		if err := r.Flush(context.Background()); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		send(i)
+	}
+
+	for i := 0; i < 2; i++ {
+		if dl := <-dlq; !errors.Is(dl.Err, ErrAckTimeout) {
+			t.Fatalf("dead letter %+v, want an ACK timeout", dl)
+		}
+	}
+	if dl := <-dlq; dl.Payload != 2 || dl.Reason != CircuitOpen || dl.Dest != "dest" {
+		t.Fatalf("dead letter %+v, want payload 2 skipped by the open breaker", dl)
+	}
+	if n := dest.count(); n != 2 {
+		t.Fatalf("dest received %d deliveries, want 2 before the breaker opened", n)
+	}
+	if ev := nextEvent(t, events, DeliveryComplete); ev.Type != BreakerOpened || ev.Dest != "dest" {
+		t.Fatalf("event %+v, want BreakerOpened for dest", ev)
+	}
+
+	// After the cooldown one delivery is tried; it fails, so the breaker
+	// opens again
+	time.Sleep(30 * time.Millisecond)
+	send(3)
+	send(4)
+	if dl := <-dlq; dl.Payload != 3 || !errors.Is(dl.Err, ErrAckTimeout) {
+		t.Fatalf("dead letter %+v, want payload 3 tried after the cooldown", dl)
+	}
+	if dl := <-dlq; dl.Payload != 4 || dl.Reason != CircuitOpen {
+		t.Fatalf("dead letter %+v, want payload 4 skipped", dl)
+	}
+	if n := dest.count(); n != 3 {
+		t.Fatalf("dest received %d deliveries, want 3", n)
+	}
+}
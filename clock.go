@@ -0,0 +1,161 @@
+package msgrouter
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the router's source of time. Route TTLs, scheduled sends, pending
+// message expiry, rate sampling, timeouts, retry backoff, coalescing windows
+// and DialComponent's backoff and heartbeats all read it, so tests can drive
+// them with a FakeClock. Connection deadlines are left to the network and
+// use real time.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a timer created by a Clock. It behaves like a time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// WithClock makes the router read time from c instead of the system clock.
+func WithClock(c Clock) Option {
+	return func(r *GenericRouter) {
+		r.clock = c
+	}
+}
+
+// realClock is the default Clock, backed by the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+// realTimer adapts a time.Timer to Timer.
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// FakeClock is a Clock for tests which only moves when Advance is called.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock creates a FakeClock reading now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the clock's current time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel receiving the clock's time once it has been
+// advanced by d.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// NewTimer creates a timer firing once the clock has been advanced by d.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d, firing every timer which comes due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	c.fire()
+}
+
+// fire fires and forgets the due timers. c.mu must be held.
+func (c *FakeClock) fire() {
+	kept := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			kept = append(kept, t)
+			continue
+		}
+		t.active = false
+		select {
+		case t.c <- c.now:
+		default:
+		}
+	}
+	c.timers = kept
+}
+
+// fakeTimer is a Timer of a FakeClock.
+type fakeTimer struct {
+	clock  *FakeClock
+	c      chan time.Time
+	at     time.Time
+	active bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	was := t.active
+	t.forget()
+	return was
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	was := t.active
+	t.forget()
+	t.at = c.now.Add(d)
+	t.active = true
+	c.timers = append(c.timers, t)
+	c.fire()
+	return was
+}
+
+// forget removes the timer from its clock. The clock's mu must be held.
+func (t *fakeTimer) forget() {
+	t.active = false
+	timers := t.clock.timers
+	for i, other := range timers {
+		if other == t {
+			t.clock.timers = append(timers[:i], timers[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package msgrouter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClockExpiresRoute(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	events := make(chan Event, 8)
+	r := newRouter(t, WithClock(clock), WithEvents(events))
+	register(t, r, "src")
+	register(t, r, "dest")
+	start(t, r)
+	if _, err := r.AddRoute(msgRt{src: "src", dest: "dest", ttl: time.Minute}); err != nil {
+		t.Fatalf("AddRoute: %v", err)
+	}
+
+	clock.Advance(59 * time.Second)
+	if dests, _ := r.GetRoutes("src"); len(dests) != 1 {
+		t.Fatalf("routes %v before the TTL passed, want [dest]", dests)
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("event %+v before the TTL passed", ev)
+	default:
+	}
+
+	clock.Advance(time.Second)
+	if ev := nextEvent(t, events, DeliveryComplete); ev.Type != RouteExpired || ev.Dest != "dest" {
+		t.Fatalf("event %+v, want src -> dest expiring", ev)
+	}
+	if dests, _ := r.GetRoutes("src"); len(dests) != 0 {
+		t.Fatalf("routes %v after the TTL passed, want none", dests)
+	}
+}
@@ -0,0 +1,100 @@
+package msgrouter
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// coalescer accumulates payloads for a single route and delivers them to the
+// destination as one []interface{} once the window elapses or max payloads
+// are buffered, whichever comes first. Each coalescer runs its own go routine
+// until the route is removed.
+type coalescer struct {
+	clock  Clock
+	in     chan interface{}
+	done   chan struct{}
+	window time.Duration
+	max    int
+	flush  func([]interface{})
+}
+
+// newCoalescer starts a coalescer delivering batches through flush.
+func newCoalescer(clock Clock, window time.Duration, max int, flush func([]interface{})) *coalescer {
+	c := &coalescer{
+		clock:  clock,
+		in:     make(chan interface{}),
+		done:   make(chan struct{}),
+		window: window,
+		max:    max,
+		flush:  flush,
+	}
+	go c.run()
+	return c
+}
+
+// add hands a payload to the coalescer. Payloads added after the coalescer
+// is stopped are dropped.
+func (c *coalescer) add(payload interface{}) {
+	select {
+	case c.in <- payload:
+	case <-c.done:
+	}
+}
+
+// stop flushes anything buffered and ends the coalescer's go routine.
+func (c *coalescer) stop() {
+	close(c.done)
+}
+
+func (c *coalescer) run() {
+
+	var buf []interface{}
+	var timer Timer
+	var timeout <-chan time.Time
+
+	flush := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, timeout = nil, nil
+		}
+		if len(buf) == 0 {
+			return
+		}
+		c.flush(buf)
+		buf = nil
+	}
+
+	for {
+		select {
+		case p := <-c.in:
+			buf = append(buf, p)
+			// Window starts with the first buffered payload
+			if len(buf) == 1 && c.window > 0 {
+				timer = c.clock.NewTimer(c.window)
+				timeout = timer.C()
+			}
+			if c.max > 0 && len(buf) >= c.max {
+				flush()
+			}
+		case <-timeout:
+			flush()
+		case <-c.done:
+			flush()
+			return
+		}
+	}
+
+}
+
+// coalesceFlush returns the function a route's coalescer uses to deliver a
+// batch from src to dest.
+func (r *GenericRouter) coalesceFlush(src ComponentID, dest Component, delivered *uint64) func([]interface{}) {
+	return func(batch []interface{}) {
+		if err := r.deliverWithRetry(dest, msgMsg{src: src, payload: batch}); err != nil {
+			r.sendError(src, dest, batch, err)
+			return
+		}
+		r.countDelivered(len(batch))
+		atomic.AddUint64(delivered, uint64(len(batch)))
+	}
+}
@@ -0,0 +1,33 @@
+package msgrouter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCoalescedRoute(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	dest := register(t, r, "dest")
+	r.addRoute(msgRt{src: "src", dest: "dest", coalesceWindow: 30 * time.Millisecond, coalesceMax: 3})
+	start(t, r)
+
+	// A full batch goes out without waiting for the window
+	if err := r.SendBatch("src", []interface{}{0, 1, 2}); err != nil {
+		t.Fatalf("SendBatch: %v", err)
+	}
+	eventually(t, func() bool { return dest.count() == 1 })
+	if got := dest.received()[0]; !reflect.DeepEqual(got, []interface{}{0, 1, 2}) {
+		t.Fatalf("delivered %v, want one slice [0 1 2]", got)
+	}
+
+	// A partial batch goes out once the window elapses
+	if err := r.Send(msgMsg{src: "src", payload: 3}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	eventually(t, func() bool { return dest.count() == 2 })
+	if got := dest.received()[1]; !reflect.DeepEqual(got, []interface{}{3}) {
+		t.Fatalf("delivered %v, want one slice [3]", got)
+	}
+}
@@ -0,0 +1,31 @@
+package msgrouter
+
+// Compact removes sources left without any routes from the routing table, as
+// happens once all of a source's routes are removed or expire, and returns how
+// many sources were removed.
+func (r *GenericRouter) Compact() (int, error) {
+	if !r.initialized() {
+		return 0, opError("Compact", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+
+	v, err := r.query(msgRt{op: COMPACT})
+	if err != nil {
+		return 0, opError("Compact", ZeroComponentID, ZeroComponentID, err)
+	}
+	return v.(int), nil
+}
+
+// compact deletes routing table entries with no destinations.
+func (r *GenericRouter) compact(m msgRt) {
+
+	reclaimed := 0
+	for src, routes := range r.rt {
+		if len(routes) == 0 {
+			delete(r.rt, src)
+			reclaimed++
+		}
+	}
+
+	m.reply <- reclaimed
+
+}
@@ -0,0 +1,33 @@
+package msgrouter
+
+import "testing"
+
+func TestCompactRemovesEmptySources(t *testing.T) {
+	r := newRouter(t)
+	for _, id := range []ComponentID{"src", "kept", "a", "b"} {
+		register(t, r, id)
+	}
+	addRoute(t, r, "src", "a")
+	addRoute(t, r, "src", "b")
+	addRoute(t, r, "kept", "a")
+	start(t, r)
+	for _, dest := range []ComponentID{"a", "b"} {
+		if err := r.RemoveRoute(msgRt{src: "src", dest: dest}); err != nil {
+			t.Fatalf("RemoveRoute: %v", err)
+		}
+	}
+
+	if n, err := r.Compact(); err != nil || n != 1 {
+		t.Fatalf("Compact = %d, %v, want 1 source removed", n, err)
+	}
+	// The reply orders this read after compact's writes
+	if _, ok := r.rt["src"]; ok {
+		t.Fatal("src is still in the routing table")
+	}
+	if _, ok := r.rt["kept"]; !ok {
+		t.Fatal("kept was removed from the routing table")
+	}
+	if n, err := r.Compact(); err != nil || n != 0 {
+		t.Fatalf("second Compact = %d, %v, want nothing removed", n, err)
+	}
+}
@@ -1,15 +1,86 @@
 package msgrouter
 
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
 // Component is an interface for go routines which will be routed from or to
 //
 // Send is the interface in which the router will use to send a message to
 // this go routine. This is usually a wrapper around the go routines IN channel.
 //
 // SetID and GetID will be used to register and lookup our components in the
-// router
+// router. Before registration GetID should return ZeroComponentID, with or
+// without an error; the router treats both as "no ID yet".
 type Component interface {
 	Send(interface{}) error
 	SetID(ComponentID) error
-	// TODO Determine best way to handle empty UUID.
 	GetID() (ComponentID, error)
 }
+
+// HeaderComponent is an optional interface for components which want the
+// headers the router attaches to each message (e.g. "seq"). When a
+// destination implements it the router calls SendWithHeaders instead of Send.
+type HeaderComponent interface {
+	Component
+	SendWithHeaders(payload interface{}, headers map[string]string) error
+}
+
+// CtxComponent is an optional interface for components which want the
+// context a message was sent with by GenericRouter.SendCtx, e.g. to honor its
+// deadline. Messages sent without a context are delivered through Send.
+type CtxComponent interface {
+	Component
+	SendCtx(ctx context.Context, payload interface{}) error
+}
+
+// FilteringComponent is an optional interface for components which only
+// handle some payloads. The router asks Accepts before each delivery over a
+// route or rule; a rejected message is not delivered to the component, is
+// reported as skipped to SendDetailed and is dead lettered with the Filtered
+// reason. Accepts is called from delivery go routines and must be safe for
+// concurrent use.
+type FilteringComponent interface {
+	Component
+	Accepts(payload interface{}) bool
+}
+
+// ErrNotAccepted is the dead letter error of a message a FilteringComponent
+// did not accept.
+var ErrNotAccepted = errors.New("Message not accepted by destination")
+
+// NamedComponent is an optional interface for components with a human
+// readable name. Names need not be unique; the router prefers them over
+// ComponentIDs in ListRoutes and ExportDOT output.
+type NamedComponent interface {
+	Component
+	Name() string
+}
+
+// displayName returns the component's name if it has one, otherwise its ID.
+func displayName(id ComponentID, c Component) string {
+	if nc, ok := c.(NamedComponent); ok {
+		if name := nc.Name(); name != "" {
+			return name
+		}
+	}
+	return string(id)
+}
+
+// sameComponent reports whether a and b are the same component. Unlike ==
+// it doesn't panic for components whose dynamic type isn't comparable; those
+// are taken to be the same if their types and IDs match.
+func sameComponent(a, b Component) bool {
+	ta := reflect.TypeOf(a)
+	if ta == nil || ta != reflect.TypeOf(b) {
+		return false
+	}
+	if ta.Comparable() {
+		return a == b
+	}
+	ida, erra := a.GetID()
+	idb, errb := b.GetID()
+	return erra == nil && errb == nil && !ida.IsZero() && ida == idb
+}
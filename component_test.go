@@ -0,0 +1,52 @@
+package msgrouter
+
+import (
+	"reflect"
+	"testing"
+)
+
+// stringsOnlyComponent is a FilteringComponent accepting only strings.
+type stringsOnlyComponent struct {
+	testComponent
+}
+
+func (c *stringsOnlyComponent) Accepts(payload interface{}) bool {
+	_, ok := payload.(string)
+	return ok
+}
+
+func TestFilteringComponentSkipsRejected(t *testing.T) {
+	r := newRouter(t, WithDeadLetterRing(4))
+	register(t, r, "src")
+	dest := &stringsOnlyComponent{}
+	registerAs(t, r, "dest", dest)
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	for _, payload := range []interface{}{"a", 1, "b", 2.5} {
+		results, err := r.SendDetailed(msgMsg{src: "src", payload: payload})
+		if err != nil {
+			t.Fatalf("SendDetailed(%v): %v", payload, err)
+		}
+		_, accepted := payload.(string)
+		if len(results) != 1 || results[0].Delivered != accepted {
+			t.Fatalf("SendDetailed(%v) = %+v, want delivered %v", payload, results, accepted)
+		}
+		if !accepted && results[0].Skipped != "not accepted" {
+			t.Fatalf("SendDetailed(%v) skipped for %q, want not accepted", payload, results[0].Skipped)
+		}
+	}
+
+	if got, want := dest.received(), []interface{}{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("dest received %v, want %v", got, want)
+	}
+	dls := r.DeadLetters()
+	if len(dls) != 2 {
+		t.Fatalf("%d dead letters, want 2", len(dls))
+	}
+	for _, dl := range dls {
+		if dl.Reason != Filtered || dl.Dest != "dest" {
+			t.Fatalf("dead letter %+v, want filtered by dest", dl)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package msgrouter
+
+import "sync/atomic"
+
+// WithMaxConcurrentDeliveries bounds the delivery go routines the router runs
+// at once to n. Once n are running the consume loop waits for one to finish
+// before starting the next delivery, so the message buffer fills up and Send
+// applies the router's overflow policy instead of go routines piling up. The
+// router's operations wait as well, so destinations must not call back into
+// the router while a delivery is blocked. Queue workers started by
+// WithDestinationQueues are bounded by their own worker count instead.
+func WithMaxConcurrentDeliveries(n int) Option {
+	return func(r *GenericRouter) {
+		if n > 0 {
+			r.deliverySlots = make(chan struct{}, n)
+		}
+	}
+}
+
+// ActiveDeliveries returns the number of delivery go routines currently
+// running, including the workers of destination queues.
+func (r *GenericRouter) ActiveDeliveries() int {
+	if !r.initialized() {
+		return 0
+	}
+	return int(atomic.LoadInt64(&r.counters.active))
+}
+
+// startDelivery takes a delivery slot, waiting for one if the router is at
+// its maximum, and counts the delivery go routine about to be started. The
+// returned func must be called once it has finished.
+func (r *GenericRouter) startDelivery() func() {
+
+	if r.deliverySlots != nil {
+		r.deliverySlots <- struct{}{}
+	}
+	atomic.AddInt64(&r.counters.active, 1)
+	finish := r.trackDelivery()
+
+	return func() {
+		atomic.AddInt64(&r.counters.active, -1)
+		if r.deliverySlots != nil {
+			<-r.deliverySlots
+		}
+		finish()
+	}
+
+}
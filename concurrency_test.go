@@ -0,0 +1,69 @@
+package msgrouter
+
+import (
+	"context"
+	"testing"
+)
+
+// concurrencyComponent blocks in Send until release is closed, recording the
+// most Sends it ever had running at once.
+type concurrencyComponent struct {
+	testComponent
+	release chan struct{}
+	running int
+	peak    int
+}
+
+func (c *concurrencyComponent) Send(payload interface{}) error {
+	c.mu.Lock()
+	c.running++
+	if c.running > c.peak {
+		c.peak = c.running
+	}
+	c.mu.Unlock()
+
+	<-c.release
+
+	c.mu.Lock()
+	c.running--
+	c.mu.Unlock()
+	return c.testComponent.Send(payload)
+}
+
+func TestMaxConcurrentDeliveries(t *testing.T) {
+	const max = 3
+	r := newRouter(t, WithMaxConcurrentDeliveries(max))
+	register(t, r, "src")
+	dest := &concurrencyComponent{release: make(chan struct{})}
+	registerAs(t, r, "dest", dest)
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	const n = 30
+	for i := 0; i < n; i++ {
+		if err := r.Send(msgMsg{src: "src", payload: i}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	// The cap is reached and the remaining messages wait in the buffer
+	eventually(t, func() bool { return r.ActiveDeliveries() == max })
+	if active := r.ActiveDeliveries(); active != max {
+		t.Fatalf("%d deliveries active, want %d", active, max)
+	}
+
+	close(dest.release)
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if m := dest.count(); m != n {
+		t.Fatalf("dest received %d messages, want %d", m, n)
+	}
+	if active := r.ActiveDeliveries(); active != 0 {
+		t.Fatalf("%d deliveries active after Flush, want 0", active)
+	}
+	dest.mu.Lock()
+	defer dest.mu.Unlock()
+	if dest.peak > max {
+		t.Fatalf("dest ran %d Sends at once, want at most %d", dest.peak, max)
+	}
+}
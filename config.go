@@ -0,0 +1,68 @@
+package msgrouter
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Config declares a router's topology up front for NewGenericRouterFromConfig.
+type Config struct {
+	// BufferSize is the buffer size passed to NewGenericRouter.
+	BufferSize int
+	// Components are the component instances to register, by name.
+	Components map[string]Component
+	// Routes are the routes to add, naming components from Components.
+	Routes []ConfigRoute
+}
+
+// ConfigRoute is a route between two components named in a Config.
+type ConfigRoute struct {
+	Src  string
+	Dest string
+}
+
+// NewGenericRouterFromConfig creates a router with NewGenericRouter, registers
+// every component in cfg and adds its routes, in order. It returns the router,
+// which still has to be started with Consume, and the ID each component was
+// registered under by name.
+func NewGenericRouterFromConfig(cfg Config, opts ...Option) (*GenericRouter, map[string]ComponentID, error) {
+
+	r := NewGenericRouter(cfg.BufferSize, opts...)
+
+	// The consume loop isn't running yet, so call its handlers directly.
+	// Register in name order so ID generation is repeatable.
+	names := make([]string, 0, len(cfg.Components))
+	for name := range cfg.Components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ids := make(map[string]ComponentID, len(names))
+	for _, name := range names {
+		c := cfg.Components[name]
+		if c == nil {
+			return nil, nil, fmt.Errorf("Component %q is nil", name)
+		}
+		if err := r.registerComponent(msgReg{c: c}); err != nil {
+			return nil, nil, fmt.Errorf("Registering component %q: %w", name, err)
+		}
+		ids[name], _ = c.GetID()
+	}
+
+	for _, cr := range cfg.Routes {
+		src, ok := ids[cr.Src]
+		if !ok {
+			return nil, nil, fmt.Errorf("Route %s -> %s: unknown component %q", cr.Src, cr.Dest, cr.Src)
+		}
+		dest, ok := ids[cr.Dest]
+		if !ok {
+			return nil, nil, fmt.Errorf("Route %s -> %s: unknown component %q", cr.Src, cr.Dest, cr.Dest)
+		}
+		if _, err := r.addRoute(msgRt{src: src, dest: dest}); err != nil {
+			return nil, nil, fmt.Errorf("Route %s -> %s: %w", cr.Src, cr.Dest, err)
+		}
+	}
+
+	return r, ids, nil
+
+}
@@ -0,0 +1,50 @@
+package msgrouter
+
+import "testing"
+
+func TestNewGenericRouterFromConfig(t *testing.T) {
+	ingest, parse, store := &testComponent{}, &testComponent{}, &testComponent{}
+	r, ids, err := NewGenericRouterFromConfig(Config{
+		BufferSize: 8,
+		Components: map[string]Component{"ingest": ingest, "parse": parse, "store": store},
+		Routes: []ConfigRoute{
+			{Src: "ingest", Dest: "parse"},
+			{Src: "parse", Dest: "store"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewGenericRouterFromConfig: %v", err)
+	}
+	go r.Consume()
+	t.Cleanup(func() { r.Stop() })
+
+	if len(ids) != 3 {
+		t.Fatalf("ids = %v, want one per component", ids)
+	}
+	for _, m := range []msgMsg{
+		{src: ids["ingest"], payload: "raw"},
+		{src: ids["parse"], payload: "parsed"},
+	} {
+		if _, err := r.SendDetailed(m); err != nil {
+			t.Fatalf("SendDetailed: %v", err)
+		}
+	}
+
+	if got := parse.received(); len(got) != 1 || got[0] != "raw" {
+		t.Fatalf("parse received %v, want [raw]", got)
+	}
+	if got := store.received(); len(got) != 1 || got[0] != "parsed" {
+		t.Fatalf("store received %v, want [parsed]", got)
+	}
+	if n := ingest.count(); n != 0 {
+		t.Fatalf("ingest received %d messages, want none", n)
+	}
+
+	_, _, err = NewGenericRouterFromConfig(Config{
+		Components: map[string]Component{"a": &testComponent{}},
+		Routes:     []ConfigRoute{{Src: "a", Dest: "missing"}},
+	})
+	if err == nil {
+		t.Fatal("NewGenericRouterFromConfig with a route to an unknown component succeeded")
+	}
+}
@@ -0,0 +1,180 @@
+package msgrouter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// maxFrameSize bounds the length of a single frame read by ConnSource so a
+// corrupt length prefix can't make the router allocate unbounded memory.
+const maxFrameSize = 16 << 20
+
+// ErrFrameTooLarge is reported when ConnSource reads a frame whose length
+// prefix exceeds the maximum frame size. The frame is skipped.
+var ErrFrameTooLarge = errors.New("Frame exceeds maximum size")
+
+// ErrEncode is returned by ConnComponent.Send when its codec fails to encode
+// a payload. The codec's error is wrapped as well.
+var ErrEncode = errors.New("Could not encode payload")
+
+// ErrDecode is the dead letter error of a frame ConnSource could not decode.
+// The codec's error is wrapped as well.
+var ErrDecode = errors.New("Could not decode frame")
+
+// Codec converts payloads to and from their wire representation.
+type Codec interface {
+	Encode(payload interface{}) ([]byte, error)
+	Decode(data []byte) (interface{}, error)
+}
+
+// ConnSource reads frames off conn and sends each decoded payload into the
+// router as src. Every frame is a 4 byte big endian length followed by that
+// many bytes, which codec decodes into a payload. Empty frames are heartbeats
+// (see RedialPolicy) and skipped. ConnSource blocks until conn
+// is closed, returning nil, or the router is stopped, in which case conn is
+// closed and ErrRouterClosed returned. Frames which can't be decoded are dead
+// lettered with the DecodeError reason, the raw frame as payload, and skipped.
+// Frames which can't be sent are reported on the router's error channel (see
+// WithErrors) and skipped.
+func (r *GenericRouter) ConnSource(conn net.Conn, src ComponentID, codec Codec) error {
+	if !r.initialized() {
+		return opError("ConnSource", src, ZeroComponentID, ErrNotInitialized)
+	}
+
+	// Unblock the reader below if the router stops while it waits on conn
+	done := r.done
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-done:
+			conn.Close()
+		case <-finished:
+		}
+	}()
+
+	var prefix [4]byte
+	for {
+		// io.ReadFull takes care of frames arriving over several reads
+		if _, err := io.ReadFull(conn, prefix[:]); err != nil {
+			return opError("ConnSource", src, ZeroComponentID, r.connSourceErr(done, err))
+		}
+
+		n := binary.BigEndian.Uint32(prefix[:])
+		if n == 0 {
+			continue
+		}
+		if n > maxFrameSize {
+			r.reportError(fmt.Errorf("%w: %d bytes from %v", ErrFrameTooLarge, n, src))
+			if _, err := io.CopyN(io.Discard, conn, int64(n)); err != nil {
+				return opError("ConnSource", src, ZeroComponentID, r.connSourceErr(done, err))
+			}
+			continue
+		}
+
+		frame := make([]byte, n)
+		if _, err := io.ReadFull(conn, frame); err != nil {
+			return opError("ConnSource", src, ZeroComponentID, r.connSourceErr(done, err))
+		}
+
+		payload, err := codec.Decode(frame)
+		if err != nil {
+			r.dropMessage(src, frame, DecodeError, fmt.Errorf("%w: %w", ErrDecode, err))
+			continue
+		}
+
+		if err := r.Send(msgMsg{src: src, payload: payload}); err != nil {
+			r.reportError(fmt.Errorf("Sending frame from %v: %w", src, err))
+		}
+	}
+}
+
+// connSourceErr translates the error which ended a ConnSource read loop.
+func (r *GenericRouter) connSourceErr(done <-chan struct{}, err error) error {
+
+	select {
+	case <-done:
+		return ErrRouterClosed
+	default:
+	}
+
+	if err == io.EOF || errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	if err == io.ErrUnexpectedEOF {
+		r.reportError(fmt.Errorf("Truncated frame: %w", err))
+		return nil
+	}
+	return err
+
+}
+
+// ConnComponent is a Component which writes every payload sent to it onto a
+// connection, in the frame format read by ConnSource, making it the sending
+// end of a bridge between two routers.
+type ConnComponent struct {
+	mu    sync.Mutex
+	conn  net.Conn
+	codec Codec
+	id    ComponentID
+
+	// Set by DialComponent
+	outbox    chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewConnComponent creates a ConnComponent writing payloads encoded by codec
+// onto conn.
+func NewConnComponent(conn net.Conn, codec Codec) *ConnComponent {
+	return &ConnComponent{conn: conn, codec: codec}
+}
+
+// Send encodes payload and writes it as a single frame. It returns ErrEncode
+// if the codec fails and ErrFrameTooLarge if the encoding exceeds the maximum
+// frame size. A ConnComponent created by DialComponent queues the frame on its
+// outbox instead, see there.
+func (c *ConnComponent) Send(payload interface{}) error {
+
+	b, err := c.codec.Encode(payload)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrEncode, err)
+	}
+	if len(b) > maxFrameSize {
+		return fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, len(b))
+	}
+
+	frame := make([]byte, 4+len(b))
+	binary.BigEndian.PutUint32(frame, uint32(len(b)))
+	copy(frame[4:], b)
+
+	if c.outbox != nil {
+		return c.queue(frame)
+	}
+
+	// Concurrent deliveries must not interleave their frames
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.conn.Write(frame)
+	return err
+
+}
+
+// SetID is called by the router on registration.
+func (c *ConnComponent) SetID(id ComponentID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.id = id
+	return nil
+}
+
+// GetID returns the ID the component was registered under.
+func (c *ConnComponent) GetID() (ComponentID, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.id, nil
+}
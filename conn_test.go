@@ -0,0 +1,76 @@
+package msgrouter
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+)
+
+// stringCodec encodes string payloads as their bytes.
+type stringCodec struct{}
+
+func (stringCodec) Encode(payload interface{}) ([]byte, error) {
+	s, ok := payload.(string)
+	if !ok {
+		return nil, errors.New("not a string")
+	}
+	return []byte(s), nil
+}
+
+func (stringCodec) Decode(data []byte) (interface{}, error) {
+	return string(data), nil
+}
+
+func TestConnSourceFeedsRouter(t *testing.T) {
+	r := newRouter(t)
+	dest := register(t, r, "dest")
+	register(t, r, "remote")
+	addRoute(t, r, "remote", "dest")
+	start(t, r)
+
+	client, server := net.Pipe()
+	finished := make(chan error, 1)
+	go func() { finished <- r.ConnSource(server, "remote", stringCodec{}) }()
+
+	for _, p := range []string{"a", "b", "c"} {
+		var prefix [4]byte
+		binary.BigEndian.PutUint32(prefix[:], uint32(len(p)))
+		if _, err := client.Write(append(prefix[:], p...)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	eventually(t, func() bool { return dest.count() == 3 })
+	got := map[interface{}]bool{}
+	for _, p := range dest.received() {
+		got[p] = true
+	}
+	if !got["a"] || !got["b"] || !got["c"] {
+		t.Fatalf("received %v, want a, b and c", dest.received())
+	}
+
+	client.Close()
+	if err := <-finished; err != nil {
+		t.Fatalf("ConnSource after the peer closed = %v, want nil", err)
+	}
+}
+
+func TestConnComponentErrEncode(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	registerAs(t, r, "remote", NewConnComponent(client, stringCodec{}))
+	addRoute(t, r, "src", "remote")
+	start(t, r)
+
+	// Nothing reads the pipe, so reaching the write would block
+	results, err := r.SendDetailed(msgMsg{src: "src", payload: 42})
+	if err != nil {
+		t.Fatalf("SendDetailed: %v", err)
+	}
+	if len(results) != 1 || !errors.Is(results[0].Err, ErrEncode) {
+		t.Fatalf("SendDetailed = %+v, want the delivery to fail with ErrEncode", results)
+	}
+}
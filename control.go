@@ -0,0 +1,211 @@
+package msgrouter
+
+import (
+	"errors"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+)
+
+// ErrRemoteRegisterDisabled is returned by the control interface's Register
+// unless the router was created with WithRemoteRegister.
+var ErrRemoteRegisterDisabled = errors.New("Remote registration disabled")
+
+// ErrNoCodec is returned by the control interface's Register when the router
+// has no codec set with WithCodec to encode payloads for the new component.
+var ErrNoCodec = errors.New("Router has no codec")
+
+// ControlRoute names a route for the control interface's AddRoute and
+// RemoveRoute.
+type ControlRoute struct {
+	Src    ComponentID
+	Dest   ComponentID
+	Labels map[string]string
+}
+
+// ControlRegister asks the control interface to register a component which
+// forwards payloads to a remote router, see DialComponent.
+type ControlRegister struct {
+	// Network and Addr are passed to net.Dial, e.g. "tcp" and
+	// "host:port". The remote end reads frames with ConnSource.
+	Network string
+	Addr    string
+}
+
+// ControlServer serves a JSON-RPC 1.0 control interface for the router on a
+// listener bound to addr, e.g. for remote administration. It returns the
+// listener, whose Addr is the one actually bound; closing it, or stopping the
+// router, ends the server. Requests name methods of the "Router" service:
+//
+//	Router.AddRoute        ControlRoute    -> bool (whether the route is new)
+//	Router.RemoveRoute     ControlRoute    -> null
+//	Router.ListRoutes      {}              -> string, see ListRoutes
+//	Router.ListRoutesLines {}              -> []string, see ListRoutesLines
+//	Router.ListComponents  {}              -> []ComponentID
+//	Router.Register        ControlRegister -> ComponentID assigned
+//	Router.Unregister      ComponentID     -> null
+//
+// Components can't be sent over the wire, so Register creates one with
+// DialComponent, writing payloads encoded by the router's codec (see
+// WithCodec) to the given address. Since that lets clients make the process
+// dial any address, Register fails with ErrRemoteRegisterDisabled unless the
+// router was created with WithRemoteRegister. Unregister closes the components
+// created by Register. The control interface is not authenticated; bind it to
+// a trusted address.
+func (r *GenericRouter) ControlServer(addr string) (net.Listener, error) {
+	if !r.initialized() {
+		return nil, opError("ControlServer", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+
+	srv := rpc.NewServer()
+	ctl := &control{r: r, dialed: make(map[ComponentID]*ConnComponent)}
+	if err := srv.RegisterName("Router", ctl); err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, opError("ControlServer", ZeroComponentID, ZeroComponentID, err)
+	}
+
+	// stopped is closed once the listener is, by the caller or below
+	done := r.done
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+			l.Close()
+		case <-stopped:
+		}
+	}()
+
+	go func() {
+		defer close(stopped)
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				// Don't leave clients connected to a stopped server
+				finished := make(chan struct{})
+				defer close(finished)
+				go func() {
+					select {
+					case <-stopped:
+						conn.Close()
+					case <-finished:
+					}
+				}()
+				srv.ServeCodec(jsonrpc.NewServerCodec(conn))
+			}()
+		}
+	}()
+
+	return l, nil
+}
+
+// WithRemoteRegister lets clients of ControlServer register components with
+// Register, each dialing an address of the client's choosing.
+func WithRemoteRegister() Option {
+	return func(r *GenericRouter) {
+		r.remoteRegister = true
+	}
+}
+
+// control exposes the router's serializable operations to net/rpc.
+type control struct {
+	r *GenericRouter
+
+	mu sync.Mutex
+	// dialed holds the components created by Register
+	dialed map[ComponentID]*ConnComponent
+}
+
+// AddRoute adds a route, see GenericRouter.AddRoute.
+func (c *control) AddRoute(args ControlRoute, added *bool) error {
+	var err error
+	*added, err = c.r.AddRoute(msgRt{src: args.Src, dest: args.Dest, labels: args.Labels})
+	return err
+}
+
+// RemoveRoute removes a route, see GenericRouter.RemoveRoute.
+func (c *control) RemoveRoute(args ControlRoute, _ *struct{}) error {
+	return c.r.RemoveRoute(msgRt{src: args.Src, dest: args.Dest})
+}
+
+// ListRoutes returns the output of GenericRouter.ListRoutes.
+func (c *control) ListRoutes(_ struct{}, routes *string) error {
+	var err error
+	*routes, err = c.r.ListRoutes()
+	return err
+}
+
+// ListRoutesLines returns the output of GenericRouter.ListRoutesLines.
+func (c *control) ListRoutesLines(_ struct{}, lines *[]string) error {
+	l, err := c.r.ListRoutesLines()
+	// A null result reads as a malformed response to jsonrpc clients
+	*lines = append([]string{}, l...)
+	return err
+}
+
+// ListComponents returns the IDs of the registered components.
+func (c *control) ListComponents(_ struct{}, ids *[]ComponentID) error {
+	l, err := c.r.ListComponents()
+	*ids = append([]ComponentID{}, l...)
+	return err
+}
+
+// Register registers a component dialing args.Addr and returns its ID.
+func (c *control) Register(args ControlRegister, id *ComponentID) error {
+
+	if !c.r.remoteRegister {
+		return opError("Register", ZeroComponentID, ZeroComponentID, ErrRemoteRegisterDisabled)
+	}
+	if c.r.codec == nil {
+		return opError("Register", ZeroComponentID, ZeroComponentID, ErrNoCodec)
+	}
+
+	dial := func() (net.Conn, error) {
+		return net.Dial(args.Network, args.Addr)
+	}
+	comp, err := c.r.DialComponent(dial, c.r.codec, RedialPolicy{})
+	if err != nil {
+		return err
+	}
+	if err := c.r.RegisterComponent(msgReg{c: comp}); err != nil {
+		comp.Close()
+		return err
+	}
+	*id, _ = comp.GetID()
+
+	c.mu.Lock()
+	c.dialed[*id] = comp
+	c.mu.Unlock()
+	return nil
+
+}
+
+// Unregister unregisters the component registered under id, closing it if
+// Register created it.
+func (c *control) Unregister(id ComponentID, _ *struct{}) error {
+
+	comp, ok := c.r.rc.get(id)
+	if !ok {
+		return opError("Unregister", id, ZeroComponentID, ErrNotRegistered)
+	}
+	if err := c.r.UnregisterComponent(msgReg{c: comp}); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	dialed, ok := c.dialed[id]
+	delete(c.dialed, id)
+	c.mu.Unlock()
+	if ok {
+		dialed.Close()
+	}
+	return nil
+
+}
@@ -0,0 +1,164 @@
+package msgrouter
+
+import (
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"strings"
+	"testing"
+)
+
+// dialControl starts a control server for r and connects a client to it.
+func dialControl(t *testing.T, r *GenericRouter) (*rpc.Client, net.Listener) {
+	t.Helper()
+	l, err := r.ControlServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ControlServer: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	client, err := jsonrpc.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, l
+}
+
+func TestControlServerAddRouteListRoutes(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "a")
+	b := register(t, r, "b")
+	start(t, r)
+	client, _ := dialControl(t, r)
+
+	var added bool
+	if err := client.Call("Router.AddRoute", ControlRoute{Src: "a", Dest: "b"}, &added); err != nil {
+		t.Fatalf("AddRoute: %v", err)
+	}
+	if !added {
+		t.Fatal("AddRoute reported an existing route")
+	}
+	if err := client.Call("Router.AddRoute", ControlRoute{Src: "a", Dest: "missing"}, &added); err == nil {
+		t.Fatal("AddRoute to an unregistered destination succeeded")
+	}
+
+	var routes string
+	if err := client.Call("Router.ListRoutes", struct{}{}, &routes); err != nil {
+		t.Fatalf("ListRoutes: %v", err)
+	}
+	if routes != "a -> b\n" {
+		t.Fatalf("ListRoutes = %q, want %q", routes, "a -> b\n")
+	}
+
+	if err := r.Send(msgMsg{src: "a", payload: "hi"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	eventually(t, func() bool { return b.count() == 1 })
+
+	if err := client.Call("Router.RemoveRoute", ControlRoute{Src: "a", Dest: "b"}, nil); err != nil {
+		t.Fatalf("RemoveRoute: %v", err)
+	}
+	var lines []string
+	if err := client.Call("Router.ListRoutesLines", struct{}{}, &lines); err != nil || len(lines) != 0 {
+		t.Fatalf("ListRoutesLines = %v, %v, want no routes", lines, err)
+	}
+}
+
+func TestControlServerRegisterDisabled(t *testing.T) {
+	r := newRouter(t, WithCodec(stringCodec{}))
+	start(t, r)
+	client, l := dialControl(t, r)
+
+	var id ComponentID
+	err := client.Call("Router.Register", ControlRegister{Network: "tcp", Addr: l.Addr().String()}, &id)
+	if err == nil || !strings.Contains(err.Error(), ErrRemoteRegisterDisabled.Error()) {
+		t.Fatalf("Register = %v, want ErrRemoteRegisterDisabled", err)
+	}
+}
+
+func TestControlServerRegisterUnregister(t *testing.T) {
+	// The remote router reads what the registered component writes
+	remote := newRouter(t)
+	sink := register(t, remote, "sink")
+	register(t, remote, "in")
+	addRoute(t, remote, "in", "sink")
+	start(t, remote)
+	rl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer rl.Close()
+	conns := make(chan net.Conn, 4)
+	go func() {
+		for {
+			conn, err := rl.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+			go remote.ConnSource(conn, "in", stringCodec{})
+		}
+	}()
+
+	r := newRouter(t, WithCodec(stringCodec{}), WithRemoteRegister())
+	register(t, r, "src")
+	start(t, r)
+	client, _ := dialControl(t, r)
+
+	var id ComponentID
+	if err := client.Call("Router.Register", ControlRegister{Network: "tcp", Addr: rl.Addr().String()}, &id); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if id.IsZero() {
+		t.Fatal("Register returned no ID")
+	}
+	var added bool
+	if err := client.Call("Router.AddRoute", ControlRoute{Src: "src", Dest: id}, &added); err != nil {
+		t.Fatalf("AddRoute: %v", err)
+	}
+	if err := r.Send(msgMsg{src: "src", payload: "hi"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	eventually(t, func() bool { return sink.count() == 1 })
+
+	if err := client.Call("Router.Unregister", id, nil); err != nil {
+		t.Fatalf("Unregister: %v", err)
+	}
+	var ids []ComponentID
+	if err := client.Call("Router.ListComponents", struct{}{}, &ids); err != nil {
+		t.Fatalf("ListComponents: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != "src" {
+		t.Fatalf("ListComponents = %v, want [src]", ids)
+	}
+
+	// Unregister closed the component, so its connection goes down
+	conn := <-conns
+	buf := make([]byte, 1)
+	within(t, "reading the closed connection", func() {
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func TestControlServerStopsWithRouter(t *testing.T) {
+	r := newRouter(t)
+	start(t, r)
+	client, l := dialControl(t, r)
+	r.Stop()
+
+	var routes string
+	if err := client.Call("Router.ListRoutes", struct{}{}, &routes); err == nil {
+		t.Fatal("ListRoutes succeeded on a stopped router")
+	}
+	eventually(t, func() bool {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+		return err != nil
+	})
+}
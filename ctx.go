@@ -0,0 +1,18 @@
+package msgrouter
+
+import "context"
+
+// SendCtx sends m with ctx attached. Destinations implementing CtxComponent
+// receive ctx with the payload. If ctx is done before the message is
+// delivered the message is dropped and dead lettered with ctx's error
+// instead. SendCtx returns ctx's error right away if ctx is already done.
+func (r *GenericRouter) SendCtx(ctx context.Context, m msgMsg) error {
+
+	if err := ctx.Err(); err != nil {
+		return opError("Send", m.src, ZeroComponentID, err)
+	}
+
+	m.ctx = ctx
+	return r.Send(m)
+
+}
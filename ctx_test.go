@@ -0,0 +1,78 @@
+package msgrouter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// ctxRecorder records the deadline of the context each payload came with.
+type ctxRecorder struct {
+	testComponent
+	deadlines []time.Time
+}
+
+func (c *ctxRecorder) SendCtx(ctx context.Context, payload interface{}) error {
+	deadline, _ := ctx.Deadline()
+	c.mu.Lock()
+	c.deadlines = append(c.deadlines, deadline)
+	c.mu.Unlock()
+	return c.Send(payload)
+}
+
+func TestSendCtx(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	dest := &ctxRecorder{}
+	registerAs(t, r, "dest", dest)
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.SendCtx(cancelled, msgMsg{src: "src", payload: "cancelled"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("SendCtx with a cancelled context = %v, want context.Canceled", err)
+	}
+
+	alive, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := r.SendCtx(alive, msgMsg{src: "src", payload: "alive"}); err != nil {
+		t.Fatalf("SendCtx: %v", err)
+	}
+	eventually(t, func() bool { return dest.count() == 1 })
+
+	if got := dest.received(); got[0] != "alive" {
+		t.Fatalf("delivered %v, want [alive]", got)
+	}
+	want, _ := alive.Deadline()
+	dest.mu.Lock()
+	defer dest.mu.Unlock()
+	if !dest.deadlines[0].Equal(want) {
+		t.Fatalf("destination saw deadline %v, want %v", dest.deadlines[0], want)
+	}
+}
+
+func TestSendCtxCancelledWhileBuffered(t *testing.T) {
+	dlq := make(chan DeadLetter, 1)
+	r := newRouter(t, WithDeadLetterQueue(dlq))
+	register(t, r, "src")
+	dest := register(t, r, "dest")
+	addRoute(t, r, "src", "dest")
+
+	// The context ends while the message waits for the consume loop
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := r.SendCtx(ctx, msgMsg{src: "src", payload: 1}); err != nil {
+		t.Fatalf("SendCtx: %v", err)
+	}
+	cancel()
+	start(t, r)
+
+	dl := <-dlq
+	if dl.Payload != 1 || !errors.Is(dl.Err, context.Canceled) {
+		t.Fatalf("dead letter %+v, want payload 1 cancelled by its context", dl)
+	}
+	if n := dest.count(); n != 0 {
+		t.Fatalf("delivered %d messages, want none", n)
+	}
+}
@@ -0,0 +1,250 @@
+package msgrouter
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DeadLetterReason classifies why a message became a dead letter, or was
+// otherwise dropped.
+type DeadLetterReason int
+
+const (
+	// SendError means a destination's Send failed. Dest is set.
+	SendError DeadLetterReason = iota
+	// NoRoute means the message's source had no destination for it.
+	NoRoute
+	// UnregisteredSource means the message was sent from an ID which is not
+	// registered.
+	UnregisteredSource
+	// Expired means the message's context ended, or its pending destination
+	// did not register in time, before it could be delivered.
+	Expired
+	// SourceClosed means the message's source was closed by DrainSource.
+	SourceClosed
+	// Quarantined means the message's source is quarantined.
+	Quarantined
+	// PendingFull means the buffer of a pending destination was full.
+	PendingFull
+	// Filtered means a FilteringComponent did not accept the message. Dest
+	// is set.
+	Filtered
+	// DecodeError means a frame read by ConnSource could not be decoded.
+	// Payload is the raw frame.
+	DecodeError
+	// BufferFull means the message buffer was full. Shed and PayloadTooLarge
+	// mean Send rejected the message under overload or for its size. Send
+	// returns these to the caller, so they are only counted in
+	// Stats.DroppedByReason and never dead lettered.
+	BufferFull
+	Shed
+	PayloadTooLarge
+	// CircuitOpen means the destination's circuit breaker was open. Dest is
+	// set.
+	CircuitOpen
+
+	// numReasons is the number of reasons, for counting
+	numReasons
+)
+
+// DeadLetter describes a message the router failed to deliver to a
+// destination. Payload is the message's original payload.
+type DeadLetter struct {
+	Src     ComponentID
+	Dest    ComponentID
+	Payload interface{}
+	Err     error
+	Reason  DeadLetterReason
+	Time    time.Time
+}
+
+// DeadLetterFunc is invoked for every dead letter.
+type DeadLetterFunc func(dl DeadLetter)
+
+// WithOnDeadLetter registers a callback which is invoked for every dead
+// letter, whether or not a dead letter queue is configured. It is called from
+// the consume loop and from delivery go routines, so it must be safe for
+// concurrent use and should return quickly.
+func WithOnDeadLetter(fn DeadLetterFunc) Option {
+	return func(r *GenericRouter) {
+		r.onDeadLetter = fn
+	}
+}
+
+// WithDeadLetterQueue makes the router publish every failed delivery onto dlq.
+// Publishing never blocks delivery; if dlq is full the dead letter is
+// dropped and counted in Stats.DeadLettersDropped. See WithDeadLetterOverflow
+// to evict the oldest dead letter instead.
+func WithDeadLetterQueue(dlq chan<- DeadLetter) Option {
+	return func(r *GenericRouter) {
+		r.dlq = dlq
+	}
+}
+
+// WithDeadLetterOverflow makes the router publish dead letters onto dlq like
+// WithDeadLetterQueue, applying p when dlq is full. DropNewest drops the dead
+// letter being published, DropOldest evicts the oldest unread dead letter to
+// make room. Either way the lost dead letter is counted in
+// Stats.DeadLettersDropped.
+func WithDeadLetterOverflow(dlq chan DeadLetter, p OverflowPolicy) Option {
+	return func(r *GenericRouter) {
+		r.dlq = dlq
+		r.dlqEvict = nil
+		if p == DropOldest {
+			r.dlqEvict = dlq
+		}
+	}
+}
+
+// WithDeadLetterRing makes the router retain the last n dead letters for
+// retrieval with DeadLetters. The oldest dead letter is evicted once n are
+// retained.
+func WithDeadLetterRing(n int) Option {
+	return func(r *GenericRouter) {
+		if n > 0 {
+			r.dlRing = &deadLetterRing{letters: make([]DeadLetter, n)}
+		}
+	}
+}
+
+// DeadLetters returns copies of the retained dead letters, oldest first. It
+// returns nil unless the router was configured with WithDeadLetterRing.
+func (r *GenericRouter) DeadLetters() []DeadLetter {
+	if r.dlRing == nil {
+		return nil
+	}
+	return r.dlRing.list()
+}
+
+// deadLetterRing is a bounded ring of recent dead letters. Dead letters are
+// produced by delivery go routines, so unlike the router's tables it is
+// guarded by a mutex rather than the consume loop.
+type deadLetterRing struct {
+	mu      sync.Mutex
+	letters []DeadLetter
+	next    int
+	full    bool
+}
+
+// add stores a dead letter, evicting the oldest when the ring is full.
+func (d *deadLetterRing) add(dl DeadLetter) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.letters[d.next] = dl
+	d.next = (d.next + 1) % len(d.letters)
+	if d.next == 0 {
+		d.full = true
+	}
+}
+
+// list copies the ring's contents, oldest first.
+func (d *deadLetterRing) list() []DeadLetter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.full {
+		return append([]DeadLetter(nil), d.letters[:d.next]...)
+	}
+	out := make([]DeadLetter, 0, len(d.letters))
+	out = append(out, d.letters[d.next:]...)
+	return append(out, d.letters[:d.next]...)
+}
+
+// deadLetter hands a dead letter to the OnDeadLetter callback, retains it in
+// the ring and publishes it onto the DLQ if they are configured.
+func (r *GenericRouter) deadLetter(dl DeadLetter) {
+
+	if r.onDeadLetter != nil {
+		r.onDeadLetter(dl)
+	}
+
+	if r.dlRing != nil {
+		r.dlRing.add(dl)
+	}
+
+	if r.dlq == nil {
+		return
+	}
+
+	select {
+	case r.dlq <- dl:
+		return
+	default:
+	}
+
+	// Unbuffered channels have nothing to evict
+	if r.dlqEvict == nil || cap(r.dlq) == 0 {
+		atomic.AddUint64(&r.counters.deadLettersDropped, 1)
+		return
+	}
+
+	// Evict the oldest dead letter and retry until ours fits. A reader may
+	// drain the channel between attempts, which is fine.
+	for {
+		select {
+		case <-r.dlqEvict:
+			atomic.AddUint64(&r.counters.deadLettersDropped, 1)
+		default:
+		}
+		select {
+		case r.dlq <- dl:
+			return
+		default:
+		}
+	}
+
+}
+
+// dropMessage counts a message which could not be routed to any destination
+// and publishes it to the DLQ.
+func (r *GenericRouter) dropMessage(src ComponentID, payload interface{}, reason DeadLetterReason, err error) {
+
+	r.countDropped(reason, 1)
+	r.deadLetter(DeadLetter{
+		Src:     src,
+		Payload: payload,
+		Err:     err,
+		Reason:  reason,
+		Time:    r.clock.Now(),
+	})
+
+}
+
+// accepted reports whether dest accepts m. A rejected message is recorded as
+// skipped and dead lettered.
+func (r *GenericRouter) accepted(src ComponentID, dest Component, m msgMsg, rec *detailRecorder) bool {
+
+	fc, ok := dest.(FilteringComponent)
+	if !ok || fc.Accepts(m.payload) {
+		return true
+	}
+
+	id, _ := dest.GetID()
+	rec.skip(dest, "not accepted")
+	r.countDropped(Filtered, 1)
+	r.deadLetter(DeadLetter{
+		Src:     src,
+		Dest:    id,
+		Payload: m.payload,
+		Err:     ErrNotAccepted,
+		Reason:  Filtered,
+		Time:    r.clock.Now(),
+	})
+	return false
+
+}
+
+// dropPayloads dead letters every payload of a message.
+func (r *GenericRouter) dropPayloads(m msgMsg, reason DeadLetterReason, err error) {
+
+	if m.batch == nil {
+		r.dropMessage(m.src, m.payload, reason, err)
+		return
+	}
+	for _, payload := range m.batch {
+		r.dropMessage(m.src, payload, reason, err)
+	}
+
+}
@@ -0,0 +1,127 @@
+package msgrouter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestDeadLetterRing(t *testing.T) {
+	r := newRouter(t, WithDeadLetterRing(2))
+	register(t, r, "src")
+	failing := register(t, r, "failing")
+	failing.err = errors.New("failed")
+	addRoute(t, r, "src", "failing")
+	start(t, r)
+
+	if err := r.SendBatch("src", []interface{}{1, 2, 3}); err != nil {
+		t.Fatalf("SendBatch: %v", err)
+	}
+	eventually(t, func() bool { return failing.count() == 3 })
+
+	var dls []DeadLetter
+	eventually(t, func() bool {
+		dls = r.DeadLetters()
+		return len(dls) == 2 && dls[1].Payload == 3
+	})
+	if dls[0].Payload != 2 || dls[0].Dest != "failing" || dls[0].Err != failing.err {
+		t.Fatalf("oldest dead letter %+v, want payload 2 failing to send", dls[0])
+	}
+}
+
+func TestDeadLetterOverflow(t *testing.T) {
+	for _, policy := range []OverflowPolicy{DropNewest, DropOldest} {
+		dlq := make(chan DeadLetter, 1)
+		r := newRouter(t, WithDeadLetterOverflow(dlq, policy))
+		register(t, r, "src")
+		failing := register(t, r, "failing")
+		failing.err = errors.New("failed")
+		addRoute(t, r, "src", "failing")
+		start(t, r)
+
+		for i := 0; i < 3; i++ {
+			if _, err := r.SendDetailed(msgMsg{src: "src", payload: i}); err != nil {
+				t.Fatalf("SendDetailed: %v", err)
+			}
+		}
+
+		stats, err := r.Stats()
+		if err != nil {
+			t.Fatalf("Stats: %v", err)
+		}
+		if stats.DeadLettersDropped != 2 {
+			t.Fatalf("policy %v: DeadLettersDropped = %d, want 2", policy, stats.DeadLettersDropped)
+		}
+		want := 0
+		if policy == DropOldest {
+			want = 2
+		}
+		if dl := <-dlq; dl.Payload != want {
+			t.Fatalf("policy %v: dead letter queue kept payload %v, want %d", policy, dl.Payload, want)
+		}
+	}
+}
+
+// rejectingComponent is a FilteringComponent accepting no payloads.
+type rejectingComponent struct {
+	testComponent
+}
+
+func (c *rejectingComponent) Accepts(payload interface{}) bool {
+	return false
+}
+
+func TestOnDeadLetterReasons(t *testing.T) {
+	var mu sync.Mutex
+	reasons := make(map[interface{}]DeadLetterReason)
+	onDeadLetter := func(dl DeadLetter) {
+		mu.Lock()
+		defer mu.Unlock()
+		reasons[dl.Payload] = dl.Reason
+	}
+	r := newRouter(t, WithOnDeadLetter(onDeadLetter))
+	register(t, r, "lonely")
+	register(t, r, "src")
+	failing := register(t, r, "failing")
+	failing.err = errors.New("failed")
+	addRoute(t, r, "src", "failing")
+	register(t, r, "picky")
+	registerAs(t, r, "rejecting", &rejectingComponent{})
+	addRoute(t, r, "picky", "rejecting")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, m := range []msgMsg{
+		{src: "lonely", payload: "no route"},
+		{src: "unknown", payload: "unregistered"},
+		{src: "src", payload: "send error"},
+		{src: "src", payload: "expired", ctx: ctx},
+		{src: "picky", payload: "filtered"},
+	} {
+		if err := r.Send(m); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	start(t, r)
+
+	want := map[interface{}]DeadLetterReason{
+		"no route":     NoRoute,
+		"unregistered": UnregisteredSource,
+		"send error":   SendError,
+		"expired":      Expired,
+		"filtered":     Filtered,
+	}
+	eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(reasons) == len(want)
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	for payload, reason := range want {
+		if got, ok := reasons[payload]; !ok || got != reason {
+			t.Errorf("%v: reason %v (reported %v), want %v", payload, got, ok, reason)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package msgrouter
+
+import (
+	"errors"
+	"sync"
+)
+
+// DeliveryResult is the outcome of a message for a single destination, as
+// reported by SendDetailed.
+type DeliveryResult struct {
+	Dest      ComponentID
+	Delivered bool
+	// Err is the error the delivery failed with, nil if it succeeded or was
+	// skipped.
+	Err error
+	// Skipped is the reason no delivery was attempted, one of "route
+	// disabled", "not selected by delivery mode", "not selected by
+	// predicate", "coalesced" or "not accepted", and empty otherwise.
+	// Coalesced payloads are delivered later in a batch; see SendWhere for
+	// "not selected by predicate" and FilteringComponent for "not accepted".
+	Skipped string
+}
+
+// sendDetail carries SendDetailed's results back from delivery.
+type sendDetail struct {
+	results []DeliveryResult
+	err     error
+}
+
+// SendDetailed sends m like Send and waits until it has been delivered,
+// returning the outcome for each of the source's route and rule destinations.
+// Taps are not reported. The error is set if the message could not be routed
+// at all, e.g. ErrNotRegistered for an unknown source or ErrNoRoute for a
+// source without destinations.
+func (r *GenericRouter) SendDetailed(m msgMsg) ([]DeliveryResult, error) {
+	if !r.initialized() {
+		return nil, opError("SendDetailed", m.src, ZeroComponentID, ErrNotInitialized)
+	}
+
+	done := r.done
+	m.detail = make(chan sendDetail, 1)
+	if err := r.Send(m); err != nil {
+		return nil, err
+	}
+
+	select {
+	case d := <-m.detail:
+		return d.results, opError("SendDetailed", m.src, ZeroComponentID, d.err)
+	case <-done:
+		return nil, opError("SendDetailed", m.src, ZeroComponentID, ErrRouterClosed)
+	}
+}
+
+// detailRecorder collects the results of a delivery made by SendDetailed or
+// with a done channel. A nil recorder records nothing, so plain sends pay no
+// cost. Deliveries queued by WithDestinationQueues record concurrently, so
+// the recorder is locked, and each holds it until done.
+type detailRecorder struct {
+	mu      sync.Mutex
+	reply   chan<- sendDetail
+	done    chan<- error
+	results []DeliveryResult
+	err     error
+	holds   int
+}
+
+// newDetailRecorder returns a recorder for d, or nil if nobody waits for the
+// outcome of d's message.
+func newDetailRecorder(d delivery) *detailRecorder {
+	if d.detail == nil && d.done == nil {
+		return nil
+	}
+	return &detailRecorder{reply: d.detail, done: d.done, results: d.skipped}
+}
+
+// record notes the outcome of a delivery attempt to dest.
+func (rec *detailRecorder) record(dest Component, err error) {
+	if rec == nil {
+		return
+	}
+	id, _ := dest.GetID()
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.results = append(rec.results, DeliveryResult{Dest: id, Delivered: err == nil, Err: err})
+}
+
+// skip notes that dest was not delivered to for reason.
+func (rec *detailRecorder) skip(dest Component, reason string) {
+	if rec == nil {
+		return
+	}
+	id, _ := dest.GetID()
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.results = append(rec.results, DeliveryResult{Dest: id, Skipped: reason})
+}
+
+// unselected notes the routes the delivery mode left out of selected.
+func (rec *detailRecorder) unselected(routes, selected []route) {
+	if rec == nil {
+		return
+	}
+	// Compare by ID, components need not be comparable
+	chosen := make(map[ComponentID]bool, len(selected))
+	for _, s := range selected {
+		id, _ := s.dest.GetID()
+		chosen[id] = true
+	}
+	for _, rte := range routes {
+		if id, _ := rte.dest.GetID(); !chosen[id] {
+			rec.skip(rte.dest, "not selected by delivery mode")
+		}
+	}
+}
+
+// fail notes an error which kept the message from being routed.
+func (rec *detailRecorder) fail(err error) {
+	if rec == nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.err == nil {
+		rec.err = err
+	}
+}
+
+// merge adds the results collected by sub.
+func (rec *detailRecorder) merge(sub *detailRecorder) {
+	if rec == nil || sub == nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.results = append(rec.results, sub.results...)
+}
+
+// hold keeps the recorder from finishing until a matching release.
+func (rec *detailRecorder) hold() {
+	if rec == nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.holds++
+}
+
+// release drops a hold, finishing the recorder when the last is dropped.
+func (rec *detailRecorder) release() {
+	if rec == nil {
+		return
+	}
+	rec.mu.Lock()
+	rec.holds--
+	last := rec.holds == 0
+	rec.mu.Unlock()
+	if last {
+		rec.finish()
+	}
+}
+
+// finish hands the collected results to SendDetailed and the aggregated
+// delivery errors to the message's done channel.
+func (rec *detailRecorder) finish() {
+	if rec == nil {
+		return
+	}
+	if rec.reply != nil {
+		rec.reply <- sendDetail{results: rec.results, err: rec.err}
+	}
+	if rec.done != nil {
+		errs := []error{rec.err}
+		for _, res := range rec.results {
+			errs = append(errs, res.Err)
+		}
+		signalDone(rec.done, errors.Join(errs...))
+	}
+}
+
+// notifyDropped tells SendDetailed and the message's done channel about a
+// message dropped before delivery.
+func notifyDropped(m msgMsg, err error) {
+	if m.detail != nil {
+		m.detail <- sendDetail{err: err}
+	}
+	if m.done != nil {
+		signalDone(m.done, err)
+	}
+}
+
+// signalDone offers err to a done channel without blocking and closes it, so
+// a receiver gets err, or nil once the channel is closed.
+func signalDone(done chan<- error, err error) {
+	if err != nil {
+		select {
+		case done <- err:
+		default:
+		}
+	}
+	close(done)
+}
@@ -0,0 +1,122 @@
+package msgrouter
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSendDetailedResults(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	register(t, r, "ok")
+	failing := register(t, r, "failing")
+	errFailed := errors.New("failed")
+	failing.err = errFailed
+	register(t, r, "muted")
+	for _, dest := range []ComponentID{"ok", "failing", "muted"} {
+		addRoute(t, r, "src", dest)
+	}
+	start(t, r)
+	if err := r.DisableRoute("src", "muted"); err != nil {
+		t.Fatalf("DisableRoute: %v", err)
+	}
+
+	results, err := r.SendDetailed(msgMsg{src: "src", payload: 1})
+	if err != nil {
+		t.Fatalf("SendDetailed: %v", err)
+	}
+	byDest := make(map[ComponentID]DeliveryResult)
+	for _, res := range results {
+		byDest[res.Dest] = res
+	}
+	if len(byDest) != 3 {
+		t.Fatalf("results %+v, want one per destination", results)
+	}
+	if res := byDest["ok"]; !res.Delivered || res.Err != nil || res.Skipped != "" {
+		t.Fatalf("ok: %+v, want delivered", res)
+	}
+	if res := byDest["failing"]; res.Delivered || !errors.Is(res.Err, errFailed) {
+		t.Fatalf("failing: %+v, want its Send error", res)
+	}
+	if res := byDest["muted"]; res.Delivered || res.Err != nil || res.Skipped != "route disabled" {
+		t.Fatalf("muted: %+v, want skipped as disabled", res)
+	}
+}
+
+func TestDoneChannel(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	dest := register(t, r, "dest")
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	done := make(chan error, 1)
+	if err := r.Send(msgMsg{src: "src", payload: 1, done: done}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("done carried %v, want nil", err)
+	}
+	if n := dest.count(); n != 1 {
+		t.Fatalf("done closed after %d deliveries, want 1", n)
+	}
+
+	failing := &testComponent{err: errors.New("failed")}
+	if err := r.RegisterWithID("failing", failing); err != nil {
+		t.Fatalf("RegisterWithID: %v", err)
+	}
+	if _, err := r.AddRoute(msgRt{src: "src", dest: "failing"}); err != nil {
+		t.Fatalf("AddRoute: %v", err)
+	}
+
+	done = make(chan error, 1)
+	if err := r.Send(msgMsg{src: "src", payload: 2, done: done}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := <-done; !errors.Is(err, failing.err) {
+		t.Fatalf("done carried %v, want %v", err, failing.err)
+	}
+	if _, ok := <-done; ok {
+		t.Fatal("done not closed after its error")
+	}
+}
+
+// uncomparableComponent is a Component whose dynamic type can't be compared
+// with ==, which panics when two such interface values are compared.
+type uncomparableComponent struct {
+	*testComponent
+	tags []string
+}
+
+func TestSendDetailedUnselectedUncomparable(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	for _, id := range []ComponentID{"a", "b"} {
+		registerAs(t, r, id, uncomparableComponent{testComponent: &testComponent{}, tags: []string{"x"}})
+		addRoute(t, r, "src", id)
+	}
+	start(t, r)
+	if err := r.SetDeliveryMode("src", RoundRobin); err != nil {
+		t.Fatalf("SetDeliveryMode: %v", err)
+	}
+
+	results, err := r.SendDetailed(msgMsg{src: "src", payload: 1})
+	if err != nil {
+		t.Fatalf("SendDetailed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	delivered, skipped := 0, 0
+	for _, res := range results {
+		switch {
+		case res.Delivered:
+			delivered++
+		case res.Skipped == "not selected by delivery mode":
+			skipped++
+		}
+	}
+	if delivered != 1 || skipped != 1 {
+		t.Fatalf("results %+v, want one delivered and one unselected", results)
+	}
+}
@@ -0,0 +1,57 @@
+package msgrouter
+
+import "errors"
+
+// ErrSourceClosed is reported for messages from a source closed by
+// DrainSource.
+var ErrSourceClosed = errors.New("Source is closed")
+
+// DrainSource routes every message src enqueued before the call and then
+// closes src; messages it sends afterwards are dropped to the DLQ with
+// ErrSourceClosed. DrainSource returns once the queued messages have been
+// handed to delivery. Other sources keep flowing throughout. Unregistering
+// src through this router reopens it, so its ID can be registered again.
+//
+// A marker is queued behind src's pending messages on the shared message
+// channel, so DrainSource waits for buffer space rather than failing with
+// ErrBufferFull.
+func (r *GenericRouter) DrainSource(src ComponentID) error {
+	if !r.initialized() {
+		return opError("DrainSource", src, ZeroComponentID, ErrNotInitialized)
+	}
+
+	done := r.done
+	if isClosed(done) {
+		return opError("DrainSource", src, ZeroComponentID, ErrRouterClosed)
+	}
+	errc := make(chan error, 1)
+	r.msgMu.RLock()
+	select {
+	case r.externalMsgChan <- msgMsg{src: src, marker: DRAINSOURCE, errc: errc}:
+		r.msgMu.RUnlock()
+	case <-done:
+		r.msgMu.RUnlock()
+		return opError("DrainSource", src, ZeroComponentID, ErrRouterClosed)
+	}
+	return opError("DrainSource", src, ZeroComponentID, awaitErr(errc, done))
+}
+
+// handleMarker processes a control marker which travelled through the
+// message channel, so it is handled in order with the messages ahead of it.
+func (r *GenericRouter) handleMarker(m msgMsg) {
+
+	switch m.marker {
+	case DRAINSOURCE:
+		if !r.rc.has(m.src) {
+			m.errc <- ErrNotRegistered
+			return
+		}
+		r.closedSources[m.src] = true
+		m.errc <- nil
+	case FLUSH:
+		r.flush(m)
+	default:
+		r.invalidOp(m.marker, m.errc)
+	}
+
+}
@@ -0,0 +1,84 @@
+package msgrouter
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDrainSourceLeavesOthersFlowing(t *testing.T) {
+	dlq := make(chan DeadLetter, 1)
+	r := newRouter(t, WithDeadLetterQueue(dlq))
+	register(t, r, "drained")
+	register(t, r, "other")
+	dest := register(t, r, "dest")
+	addRoute(t, r, "drained", "dest")
+	addRoute(t, r, "other", "dest")
+	start(t, r)
+
+	if err := r.Send(msgMsg{src: "drained", payload: "d1"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := r.DrainSource("drained"); err != nil {
+		t.Fatalf("DrainSource: %v", err)
+	}
+	for _, m := range []msgMsg{
+		{src: "drained", payload: "d2"},
+		{src: "other", payload: "o1"},
+	} {
+		if err := r.Send(m); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+
+	if dl := <-dlq; dl.Payload != "d2" || !errors.Is(dl.Err, ErrSourceClosed) {
+		t.Fatalf("dead letter %+v, want d2 from the closed source", dl)
+	}
+	eventually(t, func() bool { return dest.count() == 2 })
+	got := map[interface{}]bool{}
+	for _, p := range dest.received() {
+		got[p] = true
+	}
+	if !got["d1"] || !got["o1"] {
+		t.Fatalf("delivered %v, want d1 and o1", dest.received())
+	}
+}
+
+func TestDrainSourceClosesUntilUnregistered(t *testing.T) {
+	dlq := make(chan DeadLetter, 4)
+	// Inline, so payloads 1 and 3 arrive in order
+	r := newRouter(t, WithDeadLetterQueue(dlq), WithInlineDelivery())
+	src := register(t, r, "src")
+	dest := register(t, r, "dest")
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	if err := r.Send(msgMsg{src: "src", payload: 1}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := r.DrainSource("src"); err != nil {
+		t.Fatalf("DrainSource: %v", err)
+	}
+	if err := r.Send(msgMsg{src: "src", payload: 2}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if dl := <-dlq; dl.Payload != 2 || !errors.Is(dl.Err, ErrSourceClosed) {
+		t.Fatalf("dead letter %+v, want payload 2 with ErrSourceClosed", dl)
+	}
+
+	// A fresh registration under the same ID is open again
+	if err := r.UnregisterComponent(msgReg{c: src}); err != nil {
+		t.Fatalf("UnregisterComponent: %v", err)
+	}
+	if err := r.RegisterWithID("src", &testComponent{}); err != nil {
+		t.Fatalf("RegisterWithID: %v", err)
+	}
+	if err := r.Send(msgMsg{src: "src", payload: 3}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	r.Flush(context.Background())
+	got := dest.received()
+	if len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Fatalf("delivered %v, want [1 3]", got)
+	}
+}
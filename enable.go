@@ -0,0 +1,114 @@
+package msgrouter
+
+import "errors"
+
+// ErrNoRoute is returned when an operation references a route which does not
+// exist.
+var ErrNoRoute = errors.New("Route does not exist")
+
+// DisableRoute mutes the route from src to dest without removing it. The
+// route keeps its labels and other settings and resumes delivery once
+// EnableRoute is called.
+func (r *GenericRouter) DisableRoute(src, dest ComponentID) error {
+	if !r.initialized() {
+		return opError("DisableRoute", src, dest, ErrNotInitialized)
+	}
+
+	return opError("DisableRoute", src, dest, r.exec(msgRt{op: DISABLEROUTE, src: src, dest: dest}))
+}
+
+// EnableRoute resumes delivery on a route muted by DisableRoute.
+func (r *GenericRouter) EnableRoute(src, dest ComponentID) error {
+	if !r.initialized() {
+		return opError("EnableRoute", src, dest, ErrNotInitialized)
+	}
+
+	return opError("EnableRoute", src, dest, r.exec(msgRt{op: ENABLEROUTE, src: src, dest: dest}))
+}
+
+// setRouteDisabled flips the disabled flag on a route.
+func (r *GenericRouter) setRouteDisabled(m msgRt, disabled bool) error {
+
+	rte, err := r.findRoute(m.src, m.dest)
+	if err != nil {
+		return err
+	}
+
+	rte.disabled = disabled
+	return nil
+
+}
+
+// TagRoute adds the route from src to dest to the group named tag. A route may
+// carry any number of tags. Tagged routes are toggled together with
+// SetTagEnabled.
+func (r *GenericRouter) TagRoute(src, dest ComponentID, tag string) error {
+	if !r.initialized() {
+		return opError("TagRoute", src, dest, ErrNotInitialized)
+	}
+
+	return opError("TagRoute", src, dest, r.exec(msgRt{op: TAGROUTE, src: src, dest: dest, tag: tag}))
+}
+
+// SetTagEnabled enables or disables every route carrying tag in a single
+// operation, so no message is routed while only part of the group has been
+// flipped. It overrides any earlier DisableRoute or EnableRoute on those
+// routes. Tagging no routes is not an error.
+func (r *GenericRouter) SetTagEnabled(tag string, enabled bool) error {
+	if !r.initialized() {
+		return opError("SetTagEnabled", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+
+	op := DISABLETAG
+	if enabled {
+		op = ENABLETAG
+	}
+
+	return opError("SetTagEnabled", ZeroComponentID, ZeroComponentID, r.exec(msgRt{op: op, tag: tag}))
+}
+
+// tagRoute adds a tag to a route.
+func (r *GenericRouter) tagRoute(m msgRt) error {
+
+	rte, err := r.findRoute(m.src, m.dest)
+	if err != nil {
+		return err
+	}
+
+	if rte.tags == nil {
+		rte.tags = make(map[string]struct{})
+	}
+	rte.tags[m.tag] = struct{}{}
+	return nil
+
+}
+
+// setTagDisabled flips the disabled flag on every route carrying a tag.
+func (r *GenericRouter) setTagDisabled(m msgRt, disabled bool) error {
+
+	for _, routes := range r.rt {
+		for _, rte := range routes {
+			if _, ok := rte.tags[m.tag]; ok {
+				rte.disabled = disabled
+			}
+		}
+	}
+	return nil
+
+}
+
+// findRoute looks up the route from src to dest.
+func (r *GenericRouter) findRoute(src, dest ComponentID) (*route, error) {
+
+	if !r.rc.has(src) || !r.rc.has(dest) {
+		return nil, ErrNotRegistered
+	}
+
+	for _, rte := range r.rt[src] {
+		if r.matchDest(rte.dest, dest) {
+			return rte, nil
+		}
+	}
+
+	return nil, ErrNoRoute
+}
@@ -0,0 +1,75 @@
+package msgrouter
+
+import "testing"
+
+func TestDisableRoute(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	dest := register(t, r, "dest")
+	r.addRoute(msgRt{src: "src", dest: "dest", labels: map[string]string{"team": "ops"}})
+	// probe sees every message once the consume loop has routed it
+	probe := register(t, r, "probe")
+	addRoute(t, r, "src", "probe")
+	start(t, r)
+
+	if err := r.DisableRoute("src", "dest"); err != nil {
+		t.Fatalf("DisableRoute: %v", err)
+	}
+	if err := r.Send(msgMsg{src: "src", payload: 1}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	eventually(t, func() bool { return probe.count() == 1 })
+
+	if err := r.EnableRoute("src", "dest"); err != nil {
+		t.Fatalf("EnableRoute: %v", err)
+	}
+	if err := r.Send(msgMsg{src: "src", payload: 2}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	eventually(t, func() bool { return dest.count() == 1 })
+	if got := dest.received(); got[0] != 2 {
+		t.Fatalf("re-enabled route delivered %v, want [2]", got)
+	}
+
+	// The route kept its labels
+	infos, err := r.ListRoutesByLabel("team", "ops")
+	if err != nil || len(infos) != 1 {
+		t.Fatalf("ListRoutesByLabel = %v, %v, want the re-enabled route", infos, err)
+	}
+}
+
+func TestSetTagEnabled(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	a := register(t, r, "a")
+	b := register(t, r, "b")
+	untagged := register(t, r, "untagged")
+	for _, dest := range []ComponentID{"a", "b", "untagged"} {
+		addRoute(t, r, "src", dest)
+	}
+	start(t, r)
+	for _, dest := range []ComponentID{"a", "b"} {
+		if err := r.TagRoute("src", dest, "canary"); err != nil {
+			t.Fatalf("TagRoute: %v", err)
+		}
+	}
+
+	if err := r.SetTagEnabled("canary", false); err != nil {
+		t.Fatalf("SetTagEnabled: %v", err)
+	}
+	if err := r.Send(msgMsg{src: "src", payload: 1}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	eventually(t, func() bool { return untagged.count() == 1 })
+
+	if err := r.SetTagEnabled("canary", true); err != nil {
+		t.Fatalf("SetTagEnabled: %v", err)
+	}
+	if err := r.Send(msgMsg{src: "src", payload: 2}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	eventually(t, func() bool { return untagged.count() == 2 && a.count() == 1 && b.count() == 1 })
+	if a.received()[0] != 2 || b.received()[0] != 2 {
+		t.Fatalf("tagged routes delivered %v and %v, want [2] each", a.received(), b.received())
+	}
+}
@@ -0,0 +1,115 @@
+package msgrouter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RouterError is the error returned by router operations. It names the failed
+// operation and the components involved and wraps the cause, so callers can
+// both inspect the context with errors.As and test the cause with errors.Is.
+type RouterError struct {
+	// Op is the name of the method which failed, e.g. "AddRoute".
+	Op   string
+	Src  ComponentID
+	Dest ComponentID
+	Err  error
+}
+
+func (e *RouterError) Error() string {
+	msg := e.Op
+	if !e.Src.IsZero() {
+		msg += " " + string(e.Src)
+	}
+	if !e.Dest.IsZero() {
+		msg += " -> " + string(e.Dest)
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+// Unwrap returns the cause of the error.
+func (e *RouterError) Unwrap() error {
+	return e.Err
+}
+
+// opError wraps err in a RouterError. A nil err stays nil.
+func opError(op string, src, dest ComponentID, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RouterError{Op: op, Src: src, Dest: dest, Err: err}
+}
+
+// ItemError is the failure of a single item of a batch operation.
+type ItemError struct {
+	// Index is the item's position in the batch.
+	Index int
+	Err   error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("Op %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the cause of the error.
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError is returned by batch operations such as Apply when items fail.
+// It lists every failed item, in batch order. errors.Is and errors.As look
+// through all of them.
+type BatchError struct {
+	Items []*ItemError
+}
+
+func (e *BatchError) Error() string {
+	msgs := make([]string, len(e.Items))
+	for i, item := range e.Items {
+		msgs[i] = item.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the failed items.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Items))
+	for i, item := range e.Items {
+		errs[i] = item
+	}
+	return errs
+}
+
+// fail records the failure of item i.
+func (e *BatchError) fail(i int, err error) {
+	e.Items = append(e.Items, &ItemError{Index: i, Err: err})
+}
+
+// ErrBufferFull is returned when the router's message buffer has no room for
+// another message.
+var ErrBufferFull = errors.New("Could not send message to router")
+
+// ErrNotInitialized is returned by router methods called on a router which
+// was not created with NewGenericRouter, e.g. a zero value GenericRouter{}.
+var ErrNotInitialized = errors.New("Router not initialized, use NewGenericRouter")
+
+// ErrNotRegistered is returned when an operation references a component ID
+// which is not registered with the router.
+var ErrNotRegistered = errors.New("Component not registered")
+
+// ErrSendTimeout is reported when a destination's Send does not return within
+// the router's delivery timeout.
+var ErrSendTimeout = errors.New("Timed out delivering to component")
+
+// ErrInvalidOp is reported when the consume loop receives an operation with
+// an unknown op code.
+var ErrInvalidOp = errors.New("Invalid op code")
+
+// ErrRouterClosed is returned by operations on a router whose consume loop
+// has been stopped.
+var ErrRouterClosed = errors.New("Router is closed")
+
+// ErrNotRunning is returned by Stop when the router's consume loop isn't
+// running.
+var ErrNotRunning = errors.New("Router is not running")
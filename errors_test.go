@@ -0,0 +1,99 @@
+package msgrouter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestZeroRouterSend(t *testing.T) {
+	var r GenericRouter
+	within(t, "Consume on a zero router", r.Consume)
+
+	err := r.Send(msgMsg{src: "a", payload: 1})
+	if !errors.Is(err, ErrNotInitialized) || !strings.Contains(err.Error(), "NewGenericRouter") {
+		t.Fatalf("Send on a zero router = %v, want ErrNotInitialized", err)
+	}
+}
+
+func TestAddRouteErrorCarriesContext(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	start(t, r)
+
+	_, err := r.AddRoute(msgRt{src: "src", dest: "missing"})
+	var rerr *RouterError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("AddRoute to an unregistered destination = %v, want a RouterError", err)
+	}
+	if rerr.Op != "AddRoute" || rerr.Src != "src" || rerr.Dest != "missing" || !errors.Is(err, ErrNotRegistered) {
+		t.Fatalf("got %+v, want AddRoute src -> missing failing with ErrNotRegistered", rerr)
+	}
+	if !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("error %q doesn't name the destination", err)
+	}
+}
+
+func TestBatchErrorUnwrapsItems(t *testing.T) {
+	var batch BatchError
+	batch.fail(0, ErrNotRegistered)
+	batch.fail(2, ErrNoRoute)
+
+	var err error = &batch
+	if !errors.Is(err, ErrNotRegistered) || !errors.Is(err, ErrNoRoute) {
+		t.Fatalf("%v does not match its items' errors", err)
+	}
+	var item *ItemError
+	if !errors.As(err, &item) || item.Index != 0 {
+		t.Fatalf("errors.As found %v, want item 0", item)
+	}
+}
+
+func TestZeroRouterErrorsAreRouterErrors(t *testing.T) {
+	var r GenericRouter
+
+	ops := map[string]func() error{
+		"Send":    func() error { return r.Send(msgMsg{src: "a"}) },
+		"Stop":    r.Stop,
+		"Restart": r.Restart,
+		"Flush":   func() error { return r.Flush(context.Background()) },
+		"AddRoute": func() error {
+			_, err := r.AddRoute(msgRt{src: "a", dest: "b"})
+			return err
+		},
+		"RemoveRoute":         func() error { return r.RemoveRoute(msgRt{src: "a", dest: "b"}) },
+		"RegisterWithID":      func() error { return r.RegisterWithID("a", &testComponent{}) },
+		"UnregisterComponent": func() error { return r.UnregisterComponent(msgReg{c: &testComponent{}}) },
+		"ListComponents": func() error {
+			_, err := r.ListComponents()
+			return err
+		},
+		"ListRoutesByLabel": func() error {
+			_, err := r.ListRoutesByLabel("k", "v")
+			return err
+		},
+		"Apply": func() error { return r.Apply(nil) },
+		"DialComponent": func() error {
+			_, err := r.DialComponent(nil, stringCodec{}, RedialPolicy{})
+			return err
+		},
+	}
+	for name, op := range ops {
+		err := op()
+		var rerr *RouterError
+		if !errors.As(err, &rerr) || rerr.Op != name || !errors.Is(err, ErrNotInitialized) {
+			t.Errorf("%s on a zero router = %v, want a RouterError wrapping ErrNotInitialized", name, err)
+		}
+	}
+}
+
+func TestListRoutesByLabelEmptyKey(t *testing.T) {
+	r := newRouter(t)
+	start(t, r)
+	_, err := r.ListRoutesByLabel("", "v")
+	var rerr *RouterError
+	if !errors.As(err, &rerr) || rerr.Op != "ListRoutesByLabel" {
+		t.Fatalf("ListRoutesByLabel with an empty key = %v, want a RouterError", err)
+	}
+}
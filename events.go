@@ -0,0 +1,72 @@
+package msgrouter
+
+import "time"
+
+// EventType identifies a router lifecycle event.
+type EventType int
+
+const (
+	// RouteExpired is emitted when a route added with a TTL is removed by
+	// the router.
+	RouteExpired EventType = iota
+	// DeliveryComplete is emitted after each successful delivery with the
+	// time taken from enqueue to the destination's Send returning.
+	DeliveryComplete
+	// SheddingStarted is emitted when the message buffer passes the high
+	// watermark and Send starts shedding low priority messages.
+	SheddingStarted
+	// SheddingStopped is emitted when the message buffer falls below the
+	// low watermark and shedding ends.
+	SheddingStopped
+	// SourceQuarantined is emitted when a source is quarantined after
+	// repeated delivery failures.
+	SourceQuarantined
+	// SourceReleased is emitted when a quarantined source is released.
+	SourceReleased
+	// ConnDisconnected is emitted when a ConnComponent created by
+	// DialComponent loses its connection. Dest is the component.
+	ConnDisconnected
+	// ConnReconnected is emitted when such a ConnComponent has dialed a new
+	// connection after losing one.
+	ConnReconnected
+	// BreakerOpened is emitted when a destination's circuit breaker opens
+	// after repeated delivery failures. Dest is the destination.
+	BreakerOpened
+	// BreakerClosed is emitted when an open circuit breaker closes after a
+	// successful delivery.
+	BreakerClosed
+)
+
+// Event describes a change in the router's state which happened without an
+// explicit API call, such as a route expiring, or a completed delivery.
+type Event struct {
+	Type    EventType
+	Src     ComponentID
+	Dest    ComponentID
+	Time    time.Time
+	Latency time.Duration
+}
+
+// WithEvents makes the router publish lifecycle events onto events.
+// Publishing never blocks the router; if events is full the event is
+// dropped.
+func WithEvents(events chan<- Event) Option {
+	return func(r *GenericRouter) {
+		r.events = events
+	}
+}
+
+// emit publishes an event if an event channel is configured.
+func (r *GenericRouter) emit(e Event) {
+
+	if r.events == nil {
+		return
+	}
+
+	e.Time = r.clock.Now()
+	select {
+	case r.events <- e:
+	default:
+	}
+
+}
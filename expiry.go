@@ -0,0 +1,79 @@
+package msgrouter
+
+import "time"
+
+// expireRoutes removes every route whose TTL has passed, emitting a
+// RouteExpired event for each. It runs in the consume loop when the expiry
+// timer fires.
+func (r *GenericRouter) expireRoutes() {
+
+	now := r.clock.Now()
+	for _, rt := range r.tables() {
+		r.expireTable(rt, now)
+	}
+
+	r.resetExpiryTimer()
+
+}
+
+// expireTable removes the routes of rt whose TTL has passed.
+func (r *GenericRouter) expireTable(rt routingTable, now time.Time) {
+
+	for src, routesArray := range rt {
+		kept := routesArray[:0]
+		for _, rte := range routesArray {
+			if rte.expires.IsZero() || rte.expires.After(now) {
+				kept = append(kept, rte)
+				continue
+			}
+			rte.stop()
+			dest, _ := rte.dest.GetID()
+			r.emit(Event{Type: RouteExpired, Src: src, Dest: dest})
+		}
+		// Clear the tail so expired routes can be collected
+		for i := len(kept); i < len(routesArray); i++ {
+			routesArray[i] = nil
+		}
+		rt[src] = kept
+	}
+
+}
+
+// resetExpiryTimer arms the expiry timer for the earliest route expiry, or
+// disarms it when no route has a TTL.
+func (r *GenericRouter) resetExpiryTimer() {
+
+	if r.expiryTimer != nil {
+		r.expiryTimer.Stop()
+		r.expiryTimer = nil
+	}
+
+	var next time.Time
+	for _, rt := range r.tables() {
+		for _, routesArray := range rt {
+			for _, rte := range routesArray {
+				if rte.expires.IsZero() {
+					continue
+				}
+				if next.IsZero() || rte.expires.Before(next) {
+					next = rte.expires
+				}
+			}
+		}
+	}
+
+	if next.IsZero() {
+		return
+	}
+	r.expiryTimer = r.clock.NewTimer(next.Sub(r.clock.Now()))
+
+}
+
+// expiryC returns the channel of the expiry timer, or nil when no route has
+// a TTL.
+func (r *GenericRouter) expiryC() <-chan time.Time {
+	if r.expiryTimer == nil {
+		return nil
+	}
+	return r.expiryTimer.C()
+}
@@ -0,0 +1,36 @@
+package msgrouter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRouteTTLExpires(t *testing.T) {
+	events := make(chan Event, 8)
+	r := newRouter(t, WithEvents(events))
+	register(t, r, "src")
+	register(t, r, "dest")
+	register(t, r, "kept")
+	addRoute(t, r, "src", "kept")
+	start(t, r)
+	if _, err := r.AddRoute(msgRt{src: "src", dest: "dest", ttl: 50 * time.Millisecond}); err != nil {
+		t.Fatalf("AddRoute: %v", err)
+	}
+
+	select {
+	case ev := <-events:
+		if ev.Type != RouteExpired || ev.Src != "src" || ev.Dest != "dest" {
+			t.Fatalf("event %+v, want src -> dest expiring", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("route did not expire")
+	}
+
+	dests, err := r.GetRoutes("src")
+	if err != nil {
+		t.Fatalf("GetRoutes: %v", err)
+	}
+	if len(dests) != 1 || dests[0] != "kept" {
+		t.Fatalf("GetRoutes = %v, want [kept]", dests)
+	}
+}
@@ -0,0 +1,37 @@
+package msgrouter
+
+// sendExplicit delivers m to the destinations it names, bypassing the
+// routing table, rules, virtual routes and delivery mode of its source. Taps
+// and wildcard routes still observe it. Every destination must be registered;
+// otherwise the message is dead lettered with ErrNotRegistered and delivered
+// nowhere.
+func (r *GenericRouter) sendExplicit(m msgMsg, health *sourceHealth) {
+
+	routes := make([]route, 0, len(m.dests))
+	for _, id := range m.dests {
+		c, ok := r.rc.get(id)
+		if !ok {
+			r.dropPayloads(m, NoRoute, ErrNotRegistered)
+			notifyDropped(m, ErrNotRegistered)
+			return
+		}
+		routes = append(routes, route{
+			dest:      c,
+			inflight:  r.inflightCounter(id),
+			delivered: r.routeCounter(m.src, id),
+		})
+	}
+
+	d := delivery{
+		src:      m.src,
+		mode:     Fanout,
+		routes:   routes,
+		taps:     append([]Component(nil), r.taps[m.src]...),
+		wildcard: r.wildcardRoutes(m.src),
+		detail:   m.detail,
+		done:     m.done,
+		health:   health,
+	}
+	r.dispatch(d, r.stampPayloads(m))
+
+}
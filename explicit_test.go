@@ -0,0 +1,37 @@
+package msgrouter
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExplicitDestinations(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	routed := register(t, r, "routed")
+	a := register(t, r, "a")
+	b := register(t, r, "b")
+	addRoute(t, r, "src", "routed")
+	start(t, r)
+
+	if _, err := r.SendDetailed(msgMsg{src: "src", payload: 1, dests: []ComponentID{"a", "b"}}); err != nil {
+		t.Fatalf("SendDetailed: %v", err)
+	}
+	// One unregistered destination keeps the message from all of them
+	done := make(chan error, 1)
+	if err := r.Send(msgMsg{src: "src", payload: 2, dests: []ComponentID{"a", "unknown"}, done: done}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := <-done; !errors.Is(err, ErrNotRegistered) {
+		t.Fatalf("send to an unregistered destination completed with %v, want ErrNotRegistered", err)
+	}
+
+	for id, c := range map[ComponentID]*testComponent{"a": a, "b": b} {
+		if got := c.received(); len(got) != 1 || got[0] != 1 {
+			t.Fatalf("%v received %v, want [1]", id, got)
+		}
+	}
+	if n := routed.count(); n != 0 {
+		t.Fatalf("the routing table's destination received %d messages, want none", n)
+	}
+}
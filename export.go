@@ -0,0 +1,133 @@
+package msgrouter
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ExportDOT renders the router's topology as a Graphviz digraph with one node
+// per registered component and one edge per route. The snapshot is taken by
+// the consume loop so it is consistent with concurrent updates.
+func (r *GenericRouter) ExportDOT() (string, error) {
+	if !r.initialized() {
+		return "", opError("ExportDOT", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+
+	v, err := r.query(msgRt{op: EXPORTDOT})
+	if err != nil {
+		return "", opError("ExportDOT", ZeroComponentID, ZeroComponentID, err)
+	}
+	return v.(string), nil
+}
+
+// exportDOT builds the DOT graph. Nodes and edges are sorted so output is
+// stable across calls.
+func (r *GenericRouter) exportDOT(m msgRt) {
+
+	var buf bytes.Buffer
+	buf.WriteString("digraph msgrouter {\n")
+
+	components := r.rc.snapshot()
+	for _, id := range sortedIDs(components) {
+		fmt.Fprintf(&buf, "\t%q [label=%q];\n", string(id), displayName(id, components[id]))
+	}
+
+	for _, src := range r.sortedSources() {
+		for _, rte := range r.rt[src] {
+			dest, _ := rte.dest.GetID()
+			fmt.Fprintf(&buf, "\t%q -> %q;\n", string(src), string(dest))
+		}
+	}
+
+	buf.WriteString("}\n")
+	m.reply <- buf.String()
+}
+
+// ListRoutes returns a human readable listing of the routing table, one line
+// per source. Components are shown by name when they implement
+// NamedComponent.
+func (r *GenericRouter) ListRoutes() (string, error) {
+	if !r.initialized() {
+		return "", opError("ListRoutes", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+
+	v, err := r.query(msgRt{op: LISTROUTES})
+	if err != nil {
+		return "", opError("ListRoutes", ZeroComponentID, ZeroComponentID, err)
+	}
+	return v.(string), nil
+}
+
+// listRoutes builds the ListRoutes output, sorted by source ID.
+func (r *GenericRouter) listRoutes(m msgRt) {
+
+	var buf bytes.Buffer
+	for _, src := range r.sortedSources() {
+		routesArray := r.rt[src]
+		if len(routesArray) == 0 {
+			continue
+		}
+
+		dests := make([]string, len(routesArray))
+		for i, rte := range routesArray {
+			dest, _ := rte.dest.GetID()
+			dests[i] = displayName(dest, rte.dest)
+		}
+		srcComp, _ := r.rc.get(src)
+		fmt.Fprintf(&buf, "%s -> %s\n", displayName(src, srcComp), strings.Join(dests, ", "))
+	}
+
+	m.reply <- buf.String()
+}
+
+// ListRoutesLines returns the routing table in a line protocol meant for
+// other tools: one "src_id -> dest_id" line per route, using IDs rather than
+// names, sorted.
+func (r *GenericRouter) ListRoutesLines() ([]string, error) {
+	if !r.initialized() {
+		return nil, opError("ListRoutesLines", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+
+	v, err := r.query(msgRt{op: LISTROUTESLINES})
+	if err != nil {
+		return nil, opError("ListRoutesLines", ZeroComponentID, ZeroComponentID, err)
+	}
+	return v.([]string), nil
+}
+
+// listRoutesLines builds the ListRoutesLines output.
+func (r *GenericRouter) listRoutesLines(m msgRt) {
+
+	var lines []string
+	for src, routesArray := range r.rt {
+		for _, rte := range routesArray {
+			dest, _ := rte.dest.GetID()
+			lines = append(lines, fmt.Sprintf("%s -> %s", src, dest))
+		}
+	}
+	sort.Strings(lines)
+
+	m.reply <- lines
+}
+
+// sortedSources returns the sources in the routing table in sorted order.
+func (r *GenericRouter) sortedSources() []ComponentID {
+	srcs := make([]ComponentID, 0, len(r.rt))
+	for src := range r.rt {
+		srcs = append(srcs, src)
+	}
+	sort.Slice(srcs, func(i, j int) bool { return srcs[i] < srcs[j] })
+	return srcs
+}
+
+// sortedIDs returns the IDs of the registered components in sorted order.
+func sortedIDs(rc map[ComponentID]Component) []ComponentID {
+	ids := make([]ComponentID, 0, len(rc))
+	for id := range rc {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
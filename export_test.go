@@ -0,0 +1,82 @@
+package msgrouter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExportDOT(t *testing.T) {
+	r := newRouter(t)
+	for _, id := range []ComponentID{"a", "b", "c"} {
+		register(t, r, id)
+	}
+	addRoute(t, r, "a", "b")
+	addRoute(t, r, "a", "c")
+	start(t, r)
+
+	dot, err := r.ExportDOT()
+	if err != nil {
+		t.Fatalf("ExportDOT: %v", err)
+	}
+	want := []string{
+		"digraph msgrouter {",
+		`	"a" [label="a"];`,
+		`	"b" [label="b"];`,
+		`	"c" [label="c"];`,
+		`	"a" -> "b";`,
+		`	"a" -> "c";`,
+		"}",
+	}
+	if dot != strings.Join(want, "\n")+"\n" {
+		t.Fatalf("ExportDOT =\n%swant\n%s", dot, strings.Join(want, "\n"))
+	}
+}
+
+// namedComponent is a testComponent with a name.
+type namedComponent struct {
+	testComponent
+	name string
+}
+
+func (c *namedComponent) Name() string {
+	return c.name
+}
+
+func TestListRoutesShowsNames(t *testing.T) {
+	r := newRouter(t)
+	registerAs(t, r, "src", &namedComponent{name: "ingest"})
+	register(t, r, "a")
+	registerAs(t, r, "b", &namedComponent{name: "archive"})
+	addRoute(t, r, "src", "a")
+	addRoute(t, r, "src", "b")
+	start(t, r)
+
+	routes, err := r.ListRoutes()
+	if err != nil {
+		t.Fatalf("ListRoutes: %v", err)
+	}
+	if want := "ingest -> a, archive\n"; routes != want {
+		t.Fatalf("ListRoutes = %q, want %q", routes, want)
+	}
+}
+
+func TestListRoutesLines(t *testing.T) {
+	r := newRouter(t)
+	for _, id := range []ComponentID{"a", "b", "c"} {
+		register(t, r, id)
+	}
+	// Added out of order, the lines come back sorted
+	addRoute(t, r, "b", "a")
+	addRoute(t, r, "a", "c")
+	addRoute(t, r, "a", "b")
+	start(t, r)
+
+	lines, err := r.ListRoutesLines()
+	if err != nil {
+		t.Fatalf("ListRoutesLines: %v", err)
+	}
+	want := []string{"a -> b", "a -> c", "b -> a"}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("ListRoutesLines = %q, want %q", lines, want)
+	}
+}
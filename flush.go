@@ -0,0 +1,76 @@
+package msgrouter
+
+import (
+	"context"
+	"sync"
+)
+
+// Flush returns once every message enqueued before the call has been
+// delivered, while the router keeps accepting and routing new messages. A
+// marker is queued behind the buffered messages; once the consume loop
+// reaches it, Flush waits for the deliveries started for the messages ahead
+// of it. Payloads held by a coalescing route or parked for a pending
+// destination are not waited for. Flush returns ctx's error if ctx ends
+// first.
+func (r *GenericRouter) Flush(ctx context.Context) error {
+	if !r.initialized() {
+		return opError("Flush", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+
+	done := r.done
+	if isClosed(done) {
+		return opError("Flush", ZeroComponentID, ZeroComponentID, ErrRouterClosed)
+	}
+	errc := make(chan error, 1)
+	r.msgMu.RLock()
+	select {
+	case r.externalMsgChan <- msgMsg{marker: FLUSH, errc: errc}:
+		r.msgMu.RUnlock()
+	case <-ctx.Done():
+		r.msgMu.RUnlock()
+		return opError("Flush", ZeroComponentID, ZeroComponentID, ctx.Err())
+	case <-done:
+		r.msgMu.RUnlock()
+		return opError("Flush", ZeroComponentID, ZeroComponentID, ErrRouterClosed)
+	}
+
+	select {
+	case err := <-errc:
+		return opError("Flush", ZeroComponentID, ZeroComponentID, err)
+	case <-ctx.Done():
+		return opError("Flush", ZeroComponentID, ZeroComponentID, ctx.Err())
+	case <-done:
+		return opError("Flush", ZeroComponentID, ZeroComponentID, awaitErr(errc, done))
+	}
+}
+
+// trackDelivery counts a delivery go routine in the current flush epoch. The
+// returned func must be called once the delivery has finished.
+func (r *GenericRouter) trackDelivery() func() {
+	wg := r.flushEpoch
+	wg.Add(1)
+	return wg.Done
+}
+
+// flush starts a new flush epoch and acknowledges a FLUSH marker once the
+// deliveries of the previous epochs have finished.
+func (r *GenericRouter) flush(m msgMsg) {
+
+	epoch := r.flushEpoch
+	r.flushEpoch = new(sync.WaitGroup)
+
+	// Earlier epochs are covered by the previous flush
+	prev := r.lastFlush
+	flushed := make(chan struct{})
+	r.lastFlush = flushed
+
+	go func() {
+		epoch.Wait()
+		if prev != nil {
+			<-prev
+		}
+		close(flushed)
+		m.errc <- nil
+	}()
+
+}
@@ -0,0 +1,43 @@
+package msgrouter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFlushWaitsForDelivery(t *testing.T) {
+	// Ordered, so only the first delivery is held at the gate
+	r := newRouter(t, WithOrderedDelivery())
+	register(t, r, "src")
+	dest := &gateComponent{entered: make(chan struct{}), gate: make(chan struct{})}
+	registerAs(t, r, "dest", dest)
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	for i := 0; i < 10; i++ {
+		if err := r.Send(msgMsg{src: "src", payload: i}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	<-dest.entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := r.Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Flush with a delivery held = %v, want DeadlineExceeded", err)
+	}
+	// The router still takes messages while a Flush waits
+	if err := r.Send(msgMsg{src: "src", payload: 10}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	close(dest.gate)
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if n := dest.count(); n != 11 {
+		t.Fatalf("Flush returned after %d of 11 deliveries", n)
+	}
+}
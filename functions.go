@@ -2,8 +2,13 @@ package msgrouter
 
 import (
 	"crypto/rand"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	mrand "math/rand"
+	"sync"
+	"time"
 )
 
 // UUID is a complex string
@@ -14,7 +19,7 @@ func newUUID() (ComponentID, error) {
 	uuid := make([]byte, 16)
 	n, err := io.ReadFull(rand.Reader, uuid)
 	if n != len(uuid) || err != nil {
-		return "", err
+		return ZeroComponentID, err
 	}
 	// variant bits; see section 4.1.1
 	uuid[8] = uuid[8]&^0xc0 | 0x80
@@ -22,3 +27,82 @@ func newUUID() (ComponentID, error) {
 	uuid[6] = uuid[6]&^0xf0 | 0x40
 	return ComponentID(fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])), nil
 }
+
+// newInsecureUUIDGen returns a generator of version 4 UUIDs drawn from a
+// math/rand source seeded with the current time. The generator never fails,
+// but its output is predictable and NOT cryptographically secure.
+func newInsecureUUIDGen() func() (ComponentID, error) {
+	var mu sync.Mutex
+	rng := mrand.New(mrand.NewSource(time.Now().UnixNano()))
+
+	return func() (ComponentID, error) {
+		uuid := make([]byte, 16)
+		mu.Lock()
+		binary.BigEndian.PutUint64(uuid[:8], rng.Uint64())
+		binary.BigEndian.PutUint64(uuid[8:], rng.Uint64())
+		mu.Unlock()
+		// variant bits; see section 4.1.1
+		uuid[8] = uuid[8]&^0xc0 | 0x80
+		// version 4 (pseudo-random); see section 4.1.3
+		uuid[6] = uuid[6]&^0xf0 | 0x40
+		return ComponentID(fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])), nil
+	}
+}
+
+// uuidLen is the length of a UUID in its canonical textual form.
+const uuidLen = 36
+
+// ErrInvalidComponentID is returned by ParseComponentID for strings which
+// are not generated ComponentIDs.
+var ErrInvalidComponentID = errors.New("Invalid component ID")
+
+// ParseComponentID parses a ComponentID generated by a router: a UUID,
+// optionally preceded by a prefix and a dash as set with WithIDPrefix. IDs
+// supplied to RegisterWithID need not parse.
+func ParseComponentID(s string) (ComponentID, error) {
+
+	if len(s) < uuidLen || !isUUID(s[len(s)-uuidLen:]) {
+		return ZeroComponentID, fmt.Errorf("%w: %q", ErrInvalidComponentID, s)
+	}
+	if len(s) > uuidLen && (len(s) == uuidLen+1 || s[len(s)-uuidLen-1] != '-') {
+		return ZeroComponentID, fmt.Errorf("%w: %q", ErrInvalidComponentID, s)
+	}
+	return ComponentID(s), nil
+
+}
+
+// String returns the ID, including its prefix.
+func (id ComponentID) String() string {
+	return string(id)
+}
+
+// Prefix returns the prefix of a generated ID, see WithIDPrefix, or an empty
+// string if it has none.
+func (id ComponentID) Prefix() string {
+	s := string(id)
+	if len(s) <= uuidLen+1 || !isUUID(s[len(s)-uuidLen:]) || s[len(s)-uuidLen-1] != '-' {
+		return ""
+	}
+	return s[:len(s)-uuidLen-1]
+}
+
+// isUUID reports whether s is a UUID in canonical lower case form.
+func isUUID(s string) bool {
+
+	if len(s) != uuidLen {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if s[i] != '-' {
+				return false
+			}
+		case '0' <= s[i] && s[i] <= '9', 'a' <= s[i] && s[i] <= 'f':
+		default:
+			return false
+		}
+	}
+	return true
+
+}
@@ -0,0 +1,76 @@
+package msgrouter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInsecureIDGen(t *testing.T) {
+	r := newRouter(t, WithInsecureIDGen())
+
+	seen := make(map[ComponentID]bool)
+	for i := 0; i < 100; i++ {
+		c := &testComponent{}
+		if err := r.registerComponent(msgReg{c: c}); err != nil {
+			t.Fatalf("registerComponent: %v", err)
+		}
+		id, _ := c.GetID()
+		if len(id) != 36 || id[14] != '4' {
+			t.Fatalf("generated ID %q is not a version 4 UUID", id)
+		}
+		if seen[id] {
+			t.Fatalf("generated ID %q twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestParseComponentID(t *testing.T) {
+	id, err := newUUID()
+	if err != nil {
+		t.Fatalf("newUUID: %v", err)
+	}
+	for _, s := range []string{string(id), "worker-" + string(id)} {
+		if _, err := ParseComponentID(s); err != nil {
+			t.Errorf("ParseComponentID(%q): %v", s, err)
+		}
+	}
+	for _, s := range []string{"", "worker", "-" + string(id), string(id) + "x"} {
+		if _, err := ParseComponentID(s); err == nil {
+			t.Errorf("ParseComponentID(%q) succeeded", s)
+		}
+	}
+	if p := ComponentID("worker-" + string(id)).Prefix(); p != "worker" {
+		t.Errorf("Prefix = %q, want worker", p)
+	}
+}
+
+func TestIDPrefix(t *testing.T) {
+	r := newRouter(t, WithIDPrefix("billing"))
+	register(t, r, "dest")
+	start(t, r)
+
+	c := &testComponent{}
+	if err := r.RegisterComponent(msgReg{c: c}); err != nil {
+		t.Fatalf("RegisterComponent: %v", err)
+	}
+	id, _ := c.GetID()
+	if !strings.HasPrefix(string(id), "billing-") {
+		t.Fatalf("generated ID %q, want the billing- prefix", id)
+	}
+	parsed, err := ParseComponentID(string(id))
+	if err != nil || parsed != id {
+		t.Fatalf("ParseComponentID(%q) = %q, %v, want it back", id, parsed, err)
+	}
+	if p := id.Prefix(); p != "billing" {
+		t.Fatalf("Prefix = %q, want billing", p)
+	}
+
+	// Prefixed IDs work like any other
+	if _, err := r.AddRoute(msgRt{src: id, dest: "dest"}); err != nil {
+		t.Fatalf("AddRoute: %v", err)
+	}
+	if dests, _ := r.GetRoutes(id); len(dests) != 1 || dests[0] != "dest" {
+		t.Fatalf("routes of %v = %v, want [dest]", id, dests)
+	}
+}
@@ -0,0 +1,32 @@
+package msgrouter
+
+// HighWater records the greatest depth each of the router's buffers has
+// reached, sampled by the consume loop as it takes items off them.
+type HighWater struct {
+	Messages      int
+	RouteOps      int
+	Registrations int
+}
+
+// ResetHighWater clears the router's high water marks.
+func (r *GenericRouter) ResetHighWater() error {
+	if !r.initialized() {
+		return opError("ResetHighWater", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+
+	return opError("ResetHighWater", ZeroComponentID, ZeroComponentID, r.exec(msgRt{op: RESETHIGHWATER}))
+}
+
+// resetHighWater clears the high water marks.
+func (r *GenericRouter) resetHighWater(m msgRt) {
+	r.highWater = HighWater{}
+	m.errc <- nil
+}
+
+// observeDepth raises mark to depth if depth is greater. Called by the
+// consume loop with a buffer's depth before it took an item off.
+func observeDepth(mark *int, depth int) {
+	if depth > *mark {
+		*mark = depth
+	}
+}
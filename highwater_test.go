@@ -0,0 +1,38 @@
+package msgrouter
+
+import "testing"
+
+func TestHighWaterRecordsBurst(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	dest := register(t, r, "dest")
+	addRoute(t, r, "src", "dest")
+
+	// Buffer a burst of six before the consume loop starts
+	for i := 0; i < 6; i++ {
+		if err := r.Send(msgMsg{src: "src", payload: i}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	start(t, r)
+	eventually(t, func() bool { return dest.count() == 6 })
+
+	stats, err := r.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if stats.HighWater.Messages != 6 {
+		t.Fatalf("message high water mark %d, want 6", stats.HighWater.Messages)
+	}
+
+	if err := r.ResetHighWater(); err != nil {
+		t.Fatalf("ResetHighWater: %v", err)
+	}
+	if _, err := r.SendDetailed(msgMsg{src: "src", payload: 6}); err != nil {
+		t.Fatalf("SendDetailed: %v", err)
+	}
+	stats, _ = r.Stats()
+	if stats.HighWater.Messages != 1 {
+		t.Fatalf("message high water mark %d after reset, want 1", stats.HighWater.Messages)
+	}
+}
@@ -0,0 +1,105 @@
+package msgrouter
+
+import (
+	"errors"
+	"hash/fnv"
+)
+
+// KeyExtractor pulls a routing key out of a payload. It returns false when
+// the payload carries no key.
+type KeyExtractor func(payload interface{}) (string, bool)
+
+// KeyFallback decides what happens to a Keyed source's message when no key
+// can be extracted from it.
+type KeyFallback int
+
+const (
+	// FallbackFanout delivers the message to all of the source's
+	// destinations. This is the default.
+	FallbackFanout KeyFallback = iota
+	// FallbackDeadLetter drops the message to the dead letter queue with
+	// ErrNoKey.
+	FallbackDeadLetter
+)
+
+// ErrNoKey is reported when a Keyed source's message has no extractable key
+// and the router is configured with FallbackDeadLetter.
+var ErrNoKey = errors.New("Could not extract routing key from payload")
+
+// WithKeyExtractor sets the function used to pull routing keys out of
+// payloads sent by sources in Keyed mode.
+func WithKeyExtractor(fn KeyExtractor) Option {
+	return func(r *GenericRouter) {
+		r.keyExtractor = fn
+	}
+}
+
+// Hasher hashes a byte slice to a 64 bit value.
+type Hasher func([]byte) uint64
+
+// WithHasher sets the hash function used for Keyed destination selection.
+// Defaults to FNV-1a.
+func WithHasher(h Hasher) Option {
+	return func(r *GenericRouter) {
+		if h != nil {
+			r.hasher = h
+		}
+	}
+}
+
+// fnvHash is the default Hasher.
+func fnvHash(b []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(b)
+	return h.Sum64()
+}
+
+// WithKeyFallback sets what happens to Keyed messages without a key.
+func WithKeyFallback(f KeyFallback) Option {
+	return func(r *GenericRouter) {
+		r.keyFallback = f
+	}
+}
+
+// selectKeyed chooses the single route a keyed message is delivered to. It
+// uses rendezvous hashing, so a key keeps mapping to the same destination and
+// adding or removing a destination only moves the keys that destination owns.
+func (r *GenericRouter) selectKeyed(routes []route, m msgMsg) ([]route, error) {
+
+	var key string
+	var ok bool
+	if r.keyExtractor != nil {
+		key, ok = r.keyExtractor(m.payload)
+	}
+	if !ok {
+		if r.keyFallback == FallbackDeadLetter {
+			return nil, ErrNoKey
+		}
+		return routes, nil
+	}
+
+	if len(routes) == 0 {
+		return nil, nil
+	}
+
+	best, bestScore := 0, uint64(0)
+	for i, rte := range routes {
+		dest, _ := rte.dest.GetID()
+		score := r.hashKey(key, dest)
+		if i == 0 || score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+
+	return routes[best : best+1], nil
+}
+
+// hashKey scores a key against a destination for rendezvous hashing using
+// the router's Hasher.
+func (r *GenericRouter) hashKey(key string, dest ComponentID) uint64 {
+	b := make([]byte, 0, len(key)+1+len(dest))
+	b = append(b, key...)
+	b = append(b, 0)
+	b = append(b, dest...)
+	return r.hasher(b)
+}
@@ -0,0 +1,107 @@
+package msgrouter
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// order is a payload keyed by its Customer field.
+type order struct {
+	Customer string
+	N        int
+}
+
+func customerKey(payload interface{}) (string, bool) {
+	o, ok := payload.(order)
+	if !ok {
+		return "", false
+	}
+	return o.Customer, true
+}
+
+func TestKeyedRoutesByExtractedField(t *testing.T) {
+	dlq := make(chan DeadLetter, 1)
+	r := newRouter(t, WithKeyExtractor(customerKey), WithKeyFallback(FallbackDeadLetter), WithDeadLetterQueue(dlq))
+	register(t, r, "src")
+	dests := map[ComponentID]*testComponent{}
+	for _, id := range []ComponentID{"a", "b", "c"} {
+		dests[id] = register(t, r, id)
+		addRoute(t, r, "src", id)
+	}
+	start(t, r)
+	if err := r.SetDeliveryMode("src", Keyed); err != nil {
+		t.Fatalf("SetDeliveryMode: %v", err)
+	}
+
+	for i := 0; i < 30; i++ {
+		o := order{Customer: fmt.Sprintf("customer-%d", i%5), N: i}
+		if err := r.Send(msgMsg{src: "src", payload: o}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	eventually(t, func() bool {
+		total := 0
+		for _, dest := range dests {
+			total += dest.count()
+		}
+		return total == 30
+	})
+
+	// Each message is delivered once, and a customer's orders all go to
+	// the same destination
+	owner := map[string]ComponentID{}
+	total := 0
+	for id, dest := range dests {
+		for _, p := range dest.received() {
+			total++
+			customer := p.(order).Customer
+			if prev, ok := owner[customer]; ok && prev != id {
+				t.Fatalf("%s's orders went to %s and %s", customer, prev, id)
+			}
+			owner[customer] = id
+		}
+	}
+	if total != 30 {
+		t.Fatalf("delivered %d messages, want 30", total)
+	}
+
+	// A payload without a key is dead lettered
+	if err := r.Send(msgMsg{src: "src", payload: "no key"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if dl := <-dlq; !errors.Is(dl.Err, ErrNoKey) {
+		t.Fatalf("dead letter %+v, want ErrNoKey", dl)
+	}
+}
+
+func TestHasherDecidesKeyedSelection(t *testing.T) {
+	// The stub scores destination b highest for every key
+	stub := func(b []byte) uint64 {
+		if b[len(b)-1] == 'b' {
+			return 1
+		}
+		return 0
+	}
+	r := newRouter(t, WithKeyExtractor(customerKey), WithHasher(stub))
+	register(t, r, "src")
+	a := register(t, r, "a")
+	b := register(t, r, "b")
+	addRoute(t, r, "src", "a")
+	addRoute(t, r, "src", "b")
+	start(t, r)
+	if err := r.SetDeliveryMode("src", Keyed); err != nil {
+		t.Fatalf("SetDeliveryMode: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		o := order{Customer: fmt.Sprintf("customer-%d", i), N: i}
+		if err := r.Send(msgMsg{src: "src", payload: o}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	eventually(t, func() bool { return b.count() == 5 })
+	if a.count() != 0 {
+		t.Fatalf("a received %d and b %d messages, want all 5 at b", a.count(), b.count())
+	}
+}
@@ -0,0 +1,90 @@
+package msgrouter
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds of the delivery latency histogram. A
+// final overflow bucket counts deliveries slower than the last bound.
+var LatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// LatencyHistogram summarizes delivery latency, measured from the moment a
+// message is enqueued by Send until the destination's Send returns.
+// Counts[i] is the number of deliveries at or below LatencyBuckets[i], with
+// the last entry counting everything slower.
+type LatencyHistogram struct {
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+// Mean returns the average delivery latency.
+func (h LatencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// latencyCounters are updated atomically from delivery go routines.
+type latencyCounters struct {
+	count   uint64
+	sum     uint64
+	buckets []uint64
+}
+
+func newLatencyCounters() *latencyCounters {
+	return &latencyCounters{buckets: make([]uint64, len(LatencyBuckets)+1)}
+}
+
+// observe records a single delivery latency.
+func (l *latencyCounters) observe(d time.Duration) {
+	i := 0
+	for i < len(LatencyBuckets) && d > LatencyBuckets[i] {
+		i++
+	}
+	atomic.AddUint64(&l.buckets[i], 1)
+	atomic.AddUint64(&l.count, 1)
+	atomic.AddUint64(&l.sum, uint64(d))
+}
+
+// snapshot copies the counters into a LatencyHistogram.
+func (l *latencyCounters) snapshot() LatencyHistogram {
+	h := LatencyHistogram{
+		Counts: make([]uint64, len(l.buckets)),
+		Count:  atomic.LoadUint64(&l.count),
+		Sum:    time.Duration(atomic.LoadUint64(&l.sum)),
+	}
+	for i := range l.buckets {
+		h.Counts[i] = atomic.LoadUint64(&l.buckets[i])
+	}
+	return h
+}
+
+// recordDelivery counts a successful delivery, records its latency and emits
+// a DeliveryComplete event.
+func (r *GenericRouter) recordDelivery(src ComponentID, comp Component, m msgMsg) {
+
+	r.countDelivered(1)
+
+	if m.enqueued.IsZero() {
+		return
+	}
+
+	latency := r.clock.Now().Sub(m.enqueued)
+	r.latency.observe(latency)
+
+	dest, _ := comp.GetID()
+	r.emit(Event{Type: DeliveryComplete, Src: src, Dest: dest, Latency: latency})
+
+}
@@ -0,0 +1,50 @@
+package msgrouter
+
+import (
+	"testing"
+	"time"
+)
+
+// slowComponent takes delay to Send.
+type slowComponent struct {
+	testComponent
+	delay time.Duration
+}
+
+func (c *slowComponent) Send(payload interface{}) error {
+	time.Sleep(c.delay)
+	return c.testComponent.Send(payload)
+}
+
+func TestDeliveryLatency(t *testing.T) {
+	events := make(chan Event, 1)
+	r := newRouter(t, WithEvents(events))
+	register(t, r, "src")
+	registerAs(t, r, "dest", &slowComponent{delay: 30 * time.Millisecond})
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	if err := r.Send(msgMsg{src: "src", payload: 1}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	ev := <-events
+	if ev.Type != DeliveryComplete || ev.Src != "src" || ev.Dest != "dest" || ev.Latency < 30*time.Millisecond {
+		t.Fatalf("event %+v, want a delivery of at least 30ms from src to dest", ev)
+	}
+
+	stats, err := r.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	h := stats.Latency
+	if h.Count != 1 || h.Mean() != ev.Latency {
+		t.Fatalf("histogram has %d deliveries averaging %v, want one of %v", h.Count, h.Mean(), ev.Latency)
+	}
+	bucket := 0
+	for bucket < len(LatencyBuckets) && ev.Latency > LatencyBuckets[bucket] {
+		bucket++
+	}
+	if h.Counts[bucket] != 1 {
+		t.Fatalf("bucket counts %v, want the delivery in bucket %d", h.Counts, bucket)
+	}
+}
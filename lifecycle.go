@@ -0,0 +1,232 @@
+package msgrouter
+
+import "errors"
+
+// Stop ends the consume loop. Messages still buffered are left in place and
+// are delivered if the router is restarted with Restart. Stop returns
+// ErrRouterClosed if the router is already stopped. If Consume isn't running
+// Stop still marks the router stopped, so a later Consume returns at once,
+// and returns ErrNotRunning.
+func (r *GenericRouter) Stop() error {
+	if !r.initialized() {
+		return opError("Stop", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+
+	r.lifeMu.Lock()
+	if isClosed(r.done) {
+		r.lifeMu.Unlock()
+		return opError("Stop", ZeroComponentID, ZeroComponentID, ErrRouterClosed)
+	}
+	if !r.consuming {
+		close(r.done)
+		r.lifeMu.Unlock()
+		return opError("Stop", ZeroComponentID, ZeroComponentID, ErrNotRunning)
+	}
+	r.lifeMu.Unlock()
+
+	errc := make(chan error, 1)
+	select {
+	case r.externalRtChan <- msgRt{op: STOP, errc: errc}:
+	case <-r.done:
+		return opError("Stop", ZeroComponentID, ZeroComponentID, ErrRouterClosed)
+	}
+
+	// Another Stop may win the race, in which case our op is never handled
+	<-r.exited
+	select {
+	case err := <-errc:
+		return opError("Stop", ZeroComponentID, ZeroComponentID, err)
+	default:
+		return opError("Stop", ZeroComponentID, ZeroComponentID, ErrRouterClosed)
+	}
+}
+
+// Restart stops the consume loop if it is running, recreates the router's
+// internal channels and launches a fresh consume loop. Registrations, routes
+// and all other router state are preserved, as are messages still buffered in
+// the old channels. Operations still buffered are failed with ErrRouterClosed,
+// as their callers were already told the router is closed. No other router
+// method may be called concurrently with Restart.
+func (r *GenericRouter) Restart() error {
+	if !r.initialized() {
+		return opError("Restart", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+
+	select {
+	case <-r.done:
+	default:
+		err := r.Stop()
+		if err != nil && !errors.Is(err, ErrRouterClosed) && !errors.Is(err, ErrNotRunning) {
+			return err
+		}
+	}
+
+	// Recreate channels, migrating buffered messages and failing buffered ops
+	msgChan := make(chan msgMsg, cap(r.internalMsgChan))
+	rtChan := make(chan msgRt, cap(r.internalRtChan))
+	cmpChan := make(chan msgReg, cap(r.internalRegChan))
+	for len(r.internalMsgChan) > 0 {
+		msgChan <- <-r.internalMsgChan
+	}
+	for len(r.internalRtChan) > 0 {
+		m := <-r.internalRtChan
+		failOp(m.errc, m.reply)
+	}
+	for len(r.internalRegChan) > 0 {
+		m := <-r.internalRegChan
+		failOp(m.errc, m.reply)
+	}
+
+	r.externalMsgChan, r.internalMsgChan = msgChan, msgChan
+	r.externalRtChan, r.internalRtChan = rtChan, rtChan
+	r.externalRegChan, r.internalRegChan = cmpChan, cmpChan
+	r.done = make(chan struct{})
+	r.exited = make(chan struct{})
+
+	go r.Consume()
+	return nil
+}
+
+// stop marks the router stopped and acknowledges the Stop call. The consume
+// loop returns right after.
+func (r *GenericRouter) stop(m msgRt) {
+	close(r.done)
+	m.errc <- nil
+}
+
+// failOp fails an op the consume loop will never handle with ErrRouterClosed.
+// Its caller may have given up on it already, so neither send blocks.
+func failOp(errc chan error, reply chan interface{}) {
+	select {
+	case errc <- ErrRouterClosed:
+	default:
+	}
+	select {
+	case reply <- ErrRouterClosed:
+	default:
+	}
+}
+
+// isClosed reports whether done has been closed. Ops check it before sending
+// to the consume loop, as a select with both the send and done ready picks
+// either one at random.
+func isClosed(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// query sends m to the consume loop and waits for its reply, which it returns
+// as the error if it is one. It returns ErrRouterClosed instead if the router
+// is stopped before replying.
+func (r *GenericRouter) query(m msgRt) (interface{}, error) {
+
+	done := r.done
+	m.reply = make(chan interface{}, 1)
+	if isClosed(done) {
+		return nil, ErrRouterClosed
+	}
+	select {
+	case r.externalRtChan <- m:
+	case <-done:
+		return nil, ErrRouterClosed
+	}
+	return awaitReply(m.reply, done)
+
+}
+
+// queryReg is query for registry operations.
+func (r *GenericRouter) queryReg(m msgReg) (interface{}, error) {
+
+	done := r.done
+	m.reply = make(chan interface{}, 1)
+	if isClosed(done) {
+		return nil, ErrRouterClosed
+	}
+	select {
+	case r.externalRegChan <- m:
+	case <-done:
+		return nil, ErrRouterClosed
+	}
+	return awaitReply(m.reply, done)
+
+}
+
+// exec sends m to the consume loop and waits for the error it acknowledges
+// m with. It returns ErrRouterClosed instead if the router is stopped before
+// acknowledging.
+func (r *GenericRouter) exec(m msgRt) error {
+
+	done := r.done
+	m.errc = make(chan error, 1)
+	if isClosed(done) {
+		return ErrRouterClosed
+	}
+	select {
+	case r.externalRtChan <- m:
+	case <-done:
+		return ErrRouterClosed
+	}
+	return awaitErr(m.errc, done)
+
+}
+
+// execReg is exec for registry operations.
+func (r *GenericRouter) execReg(m msgReg) error {
+
+	done := r.done
+	m.errc = make(chan error, 1)
+	if isClosed(done) {
+		return ErrRouterClosed
+	}
+	select {
+	case r.externalRegChan <- m:
+	case <-done:
+		return ErrRouterClosed
+	}
+	return awaitErr(m.errc, done)
+
+}
+
+// awaitErr waits for an op's acknowledgement or for done to close.
+func awaitErr(errc <-chan error, done <-chan struct{}) error {
+
+	select {
+	case err := <-errc:
+		return err
+	case <-done:
+		// The loop may have acknowledged just before stopping
+		select {
+		case err := <-errc:
+			return err
+		default:
+			return ErrRouterClosed
+		}
+	}
+
+}
+
+// awaitReply waits for a query's reply or for done to close.
+func awaitReply(reply <-chan interface{}, done <-chan struct{}) (interface{}, error) {
+
+	var v interface{}
+	select {
+	case v = <-reply:
+	case <-done:
+		// The loop may have replied just before stopping
+		select {
+		case v = <-reply:
+		default:
+			return nil, ErrRouterClosed
+		}
+	}
+
+	if err, ok := v.(error); ok {
+		return nil, err
+	}
+	return v, nil
+
+}
@@ -0,0 +1,214 @@
+package msgrouter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRestartKeepsRoutes(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	dest := register(t, r, "dest")
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	if err := r.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	// Buffered while stopped, delivered after the restart
+	if err := r.Send(msgMsg{src: "src", payload: 1}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := r.Restart(); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	if err := r.Send(msgMsg{src: "src", payload: 2}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	eventually(t, func() bool { return dest.count() == 2 })
+}
+
+func TestStopWithoutConsume(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "a")
+	register(t, r, "b")
+
+	// Buffered before the consume loop ever runs
+	errc := make(chan error, 1)
+	go func() {
+		_, err := r.AddRoute(msgRt{src: "a", dest: "b"})
+		errc <- err
+	}()
+	eventually(t, func() bool { return len(r.internalRtChan) == 1 })
+
+	within(t, "Stop", func() {
+		if err := r.Stop(); !errors.Is(err, ErrNotRunning) {
+			t.Errorf("Stop = %v, want ErrNotRunning", err)
+		}
+	})
+	if err := <-errc; !errors.Is(err, ErrRouterClosed) {
+		t.Fatalf("AddRoute = %v, want ErrRouterClosed", err)
+	}
+	if err := r.Stop(); !errors.Is(err, ErrRouterClosed) {
+		t.Fatalf("second Stop = %v, want ErrRouterClosed", err)
+	}
+
+	// Stopped, so ops fail without reaching the buffer
+	if _, err := r.AddRoute(msgRt{src: "a", dest: "b"}); !errors.Is(err, ErrRouterClosed) {
+		t.Fatalf("AddRoute after Stop = %v, want ErrRouterClosed", err)
+	}
+	if n := len(r.internalRtChan); n != 1 {
+		t.Fatalf("%d ops buffered after Stop, want 1", n)
+	}
+	within(t, "Consume on a stopped router", r.Consume)
+}
+
+func TestRestartFailsBufferedOps(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "a")
+	register(t, r, "b")
+
+	// Buffered before the consume loop ever runs, then failed by Stop
+	errc := make(chan error, 1)
+	go func() {
+		_, err := r.AddRoute(msgRt{src: "a", dest: "b"})
+		errc <- err
+	}()
+	eventually(t, func() bool { return len(r.internalRtChan) == 1 })
+	r.Stop()
+	if err := <-errc; !errors.Is(err, ErrRouterClosed) {
+		t.Fatalf("AddRoute = %v, want ErrRouterClosed", err)
+	}
+
+	// The new loop must not apply an op its caller saw fail
+	if err := r.Restart(); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	t.Cleanup(func() { r.Stop() })
+	if dests, err := r.GetRoutes("a"); err != nil || len(dests) != 0 {
+		t.Fatalf("GetRoutes = %v, %v after Restart, want no routes", dests, err)
+	}
+}
+
+func TestQueriesRacingStop(t *testing.T) {
+	r := NewGenericRouter(4)
+	register(t, r, "a")
+	register(t, r, "b")
+	addRoute(t, r, "a", "b")
+	start(t, r)
+
+	queries := map[string]func() error{
+		"GetRoutes": func() error {
+			_, err := r.GetRoutes("a")
+			return err
+		},
+		"ListRoutes": func() error {
+			_, err := r.ListRoutes()
+			return err
+		},
+		"Stats": func() error {
+			_, err := r.Stats()
+			return err
+		},
+		"RouteCount": func() error {
+			_, _, err := r.RouteCount()
+			return err
+		},
+		"Snapshot": func() error {
+			_, err := r.Snapshot()
+			return err
+		},
+	}
+
+	// Every query runs until Stop makes it fail, which must be with
+	// ErrRouterClosed rather than a hang
+	var wg sync.WaitGroup
+	errs := make(chan error, len(queries))
+	for name, query := range queries {
+		wg.Add(1)
+		go func(name string, query func() error) {
+			defer wg.Done()
+			for {
+				err := query()
+				if err == nil {
+					continue
+				}
+				if !errors.Is(err, ErrRouterClosed) {
+					errs <- fmt.Errorf("%s racing Stop: got %v, want ErrRouterClosed", name, err)
+				}
+				return
+			}
+		}(name, query)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if err := r.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	within(t, "queries", wg.Wait)
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestOpsAfterStopReturnErrRouterClosed(t *testing.T) {
+	r := newRouter(t)
+	c := register(t, r, "a")
+	register(t, r, "b")
+	start(t, r)
+	if err := r.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	ops := map[string]func() error{
+		"RegisterComponent":   func() error { return r.RegisterComponent(msgReg{c: &testComponent{}}) },
+		"RegisterWithID":      func() error { return r.RegisterWithID("c", &testComponent{}) },
+		"UnregisterComponent": func() error { return r.UnregisterComponent(msgReg{c: c}) },
+		"ForEachComponent": func() error {
+			return r.ForEachComponent(func(ComponentID, Component) bool { return true })
+		},
+		"RemoveRoute":  func() error { return r.RemoveRoute(msgRt{src: "a", dest: "b"}) },
+		"Apply":        func() error { return r.Apply([]Op{{Type: OpAddRoute, Src: "a", Dest: "b"}}) },
+		"InstallTable": func() error { return r.InstallTable(RouterSnapshot{}) },
+		"Resize":       func() error { return r.Resize(8) },
+		"DrainSource":  func() error { return r.DrainSource("a") },
+		"Flush":        func() error { return r.Flush(context.Background()) },
+		"MoveRoutes":   func() error { return r.MoveRoutes("a", "b") },
+		"AddTap":       func() error { return r.AddTap("a", "b") },
+		"RemoveTap":    func() error { return r.RemoveTap("a", "b") },
+		"AddRule": func() error {
+			return r.AddRule("a", Rule{Predicate: func(interface{}) bool { return true }, Dest: "b"})
+		},
+		"ClearRules":      func() error { return r.ClearRules("a") },
+		"DisableRoute":    func() error { return r.DisableRoute("a", "b") },
+		"EnableRoute":     func() error { return r.EnableRoute("a", "b") },
+		"TagRoute":        func() error { return r.TagRoute("a", "b", "t") },
+		"SetTagEnabled":   func() error { return r.SetTagEnabled("t", false) },
+		"SetDeliveryMode": func() error { return r.SetDeliveryMode("a", RoundRobin) },
+		"SetRouteWeight":  func() error { return r.SetRouteWeight("a", "b", 2) },
+		"Release":         func() error { return r.Release("a") },
+		"ResetHighWater":  func() error { return r.ResetHighWater() },
+		"CancelScheduled": func() error { return r.CancelScheduled(1) },
+		"ListRoutes": func() error {
+			_, err := r.ListRoutes()
+			return err
+		},
+		"AddRoute": func() error {
+			_, err := r.AddRoute(msgRt{src: "a", dest: "b"})
+			return err
+		},
+	}
+
+	for name, op := range ops {
+		var err error
+		within(t, name, func() { err = op() })
+		if !errors.Is(err, ErrRouterClosed) {
+			t.Errorf("%s after Stop: got %v, want ErrRouterClosed", name, err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package msgrouter
+
+import "time"
+
+// Message is the envelope a payload travels in. Components implementing
+// MessageComponent receive it whole; others receive just the Payload.
+type Message struct {
+	Src     ComponentID
+	Headers map[string]string
+	Payload interface{}
+	// Timestamp is when the message was accepted by Send.
+	Timestamp time.Time
+}
+
+// MessageComponent is an optional interface for components which want the
+// whole Message envelope, including the headers the router attaches, instead
+// of just its payload. When a destination implements it the router calls
+// SendMessage instead of Send or SendWithHeaders.
+type MessageComponent interface {
+	Component
+	SendMessage(m Message) error
+}
+
+// SendMessage sends the payload in m from m.Src, carrying m.Headers along.
+// The router adds its own headers, such as "seq", and sets the Timestamp
+// destinations see; m itself is not modified.
+func (r *GenericRouter) SendMessage(m *Message) error {
+	return r.Send(msgMsg{src: m.Src, payload: m.Payload, headers: copyStringMap(m.Headers)})
+}
+
+// message builds the envelope delivered to a MessageComponent.
+func (m msgMsg) message() Message {
+	return Message{
+		Src:       m.src,
+		Headers:   copyStringMap(m.headers),
+		Payload:   m.payload,
+		Timestamp: m.enqueued,
+	}
+}
@@ -0,0 +1,43 @@
+package msgrouter
+
+import (
+	"testing"
+	"time"
+)
+
+// envelopeRecorder records every Message envelope sent to it.
+type envelopeRecorder struct {
+	testComponent
+	msgs chan Message
+}
+
+func (c *envelopeRecorder) SendMessage(m Message) error {
+	c.msgs <- m
+	return nil
+}
+
+func TestSendMessageEnvelope(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	dest := &envelopeRecorder{msgs: make(chan Message, 1)}
+	registerAs(t, r, "dest", dest)
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	in := &Message{Src: "src", Payload: "p", Headers: map[string]string{"trace": "abc"}}
+	before := time.Now()
+	if err := r.SendMessage(in); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	after := time.Now()
+	m := <-dest.msgs
+	if m.Src != "src" || m.Payload != "p" || m.Headers["trace"] != "abc" || m.Headers[SeqHeader] != "0" {
+		t.Fatalf("delivered %+v, want the payload with trace and seq headers", m)
+	}
+	if m.Timestamp.Before(before) || m.Timestamp.After(after) {
+		t.Fatalf("Timestamp %v, want the send time between %v and %v", m.Timestamp, before, after)
+	}
+	if _, ok := in.Headers[SeqHeader]; ok || !in.Timestamp.IsZero() {
+		t.Fatalf("SendMessage modified the caller's message: %+v", in)
+	}
+}
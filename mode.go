@@ -0,0 +1,106 @@
+package msgrouter
+
+// Mode selects how a source's messages are distributed among its routes.
+type Mode int
+
+const (
+	// Fanout delivers every message to all of the source's destinations.
+	// This is the default.
+	Fanout Mode = iota
+	// Keyed delivers each message to a single destination chosen by
+	// consistent hashing of the key pulled from the payload by the router's
+	// key extractor. See WithKeyExtractor.
+	Keyed
+	// LeastOutstanding delivers each message to the single destination with
+	// the fewest deliveries in flight, balancing load across workers.
+	LeastOutstanding
+	// RoundRobin delivers each message to a single destination, cycling
+	// through the source's routes in order.
+	RoundRobin
+	// ParallelFanout delivers every message to all of the source's
+	// destinations concurrently, moving on to the next message once every
+	// destination has returned. A slow destination then delays a message by
+	// its own delivery time rather than the sum of all of them.
+	ParallelFanout
+	// Weighted delivers each message to a single destination chosen at
+	// random in proportion to the route weights set with SetRouteWeight.
+	Weighted
+)
+
+// SetDeliveryMode sets the delivery mode used for messages from src. Any
+// mode specific state, such as the round robin position, is reset even when
+// the mode doesn't change.
+func (r *GenericRouter) SetDeliveryMode(src ComponentID, mode Mode) error {
+	if !r.initialized() {
+		return opError("SetDeliveryMode", src, ZeroComponentID, ErrNotInitialized)
+	}
+
+	return opError("SetDeliveryMode", src, ZeroComponentID, r.exec(msgRt{op: SETMODE, src: src, mode: mode}))
+}
+
+// GetDeliveryMode returns the delivery mode used for messages from src.
+func (r *GenericRouter) GetDeliveryMode(src ComponentID) (Mode, error) {
+	if !r.initialized() {
+		return Fanout, opError("GetDeliveryMode", src, ZeroComponentID, ErrNotInitialized)
+	}
+
+	v, err := r.query(msgRt{op: GETMODE, src: src})
+	if err != nil {
+		return Fanout, opError("GetDeliveryMode", src, ZeroComponentID, err)
+	}
+	return v.(Mode), nil
+}
+
+// setDeliveryMode records a source's delivery mode. Fanout is stored as the
+// absence of an entry.
+func (r *GenericRouter) setDeliveryMode(m msgRt) error {
+
+	if !r.rc.has(m.src) {
+		return ErrNotRegistered
+	}
+
+	// Reset mode specific state
+	delete(r.rrIndex, m.src)
+
+	if m.mode == Fanout {
+		delete(r.modes, m.src)
+		return nil
+	}
+	r.modes[m.src] = m.mode
+	return nil
+
+}
+
+// getDeliveryMode answers a GetDeliveryMode query.
+func (r *GenericRouter) getDeliveryMode(m msgRt) {
+
+	if !r.rc.has(m.src) {
+		m.reply <- ErrNotRegistered
+		return
+	}
+
+	m.reply <- r.modes[m.src]
+}
+
+// selectRoutes picks which of a source's routes receive the i'th message of
+// a delivery according to the source's delivery mode.
+func (r *GenericRouter) selectRoutes(d delivery, i int, m msgMsg) ([]route, error) {
+
+	switch d.mode {
+	case Keyed:
+		return r.selectKeyed(d.routes, m)
+	case LeastOutstanding:
+		return selectLeastOutstanding(d.routes), nil
+	case Weighted:
+		return selectWeighted(d.routes), nil
+	case RoundRobin:
+		if len(d.routes) == 0 {
+			return nil, nil
+		}
+		j := (d.rrStart + i) % len(d.routes)
+		return d.routes[j : j+1], nil
+	default:
+		return d.routes, nil
+	}
+
+}
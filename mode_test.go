@@ -0,0 +1,97 @@
+package msgrouter
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDeliveryModeResetsRoundRobin(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	a := register(t, r, "a")
+	b := register(t, r, "b")
+	addRoute(t, r, "src", "a")
+	addRoute(t, r, "src", "b")
+	start(t, r)
+	send := func(payload int) {
+		t.Helper()
+		if err := r.Send(msgMsg{src: "src", payload: payload}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		eventually(t, func() bool { return a.count()+b.count() == payload })
+	}
+
+	if mode, err := r.GetDeliveryMode("src"); err != nil || mode != Fanout {
+		t.Fatalf("GetDeliveryMode = %v, %v, want Fanout", mode, err)
+	}
+	if err := r.SetDeliveryMode("src", RoundRobin); err != nil {
+		t.Fatalf("SetDeliveryMode: %v", err)
+	}
+	if mode, err := r.GetDeliveryMode("src"); err != nil || mode != RoundRobin {
+		t.Fatalf("GetDeliveryMode = %v, %v, want RoundRobin", mode, err)
+	}
+
+	send(1)
+	// Setting the mode again starts over at the first route
+	if err := r.SetDeliveryMode("src", RoundRobin); err != nil {
+		t.Fatalf("SetDeliveryMode: %v", err)
+	}
+	send(2)
+	send(3)
+
+	if got := a.received(); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Fatalf("a received %v, want [1 2]", got)
+	}
+	if got := b.received(); len(got) != 1 || got[0] != 3 {
+		t.Fatalf("b received %v, want [3]", got)
+	}
+}
+
+// barrierComponent's Send waits until every component sharing its barrier
+// is inside Send, failing if that takes longer than a second.
+type barrierComponent struct {
+	testComponent
+	barrier *sync.WaitGroup
+}
+
+func (c *barrierComponent) Send(payload interface{}) error {
+	c.barrier.Done()
+	met := make(chan struct{})
+	go func() {
+		c.barrier.Wait()
+		close(met)
+	}()
+	select {
+	case <-met:
+		return c.testComponent.Send(payload)
+	case <-time.After(time.Second):
+		return errors.New("delivered alone")
+	}
+}
+
+func TestParallelFanoutDeliversConcurrently(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	var barrier sync.WaitGroup
+	barrier.Add(2)
+	for _, id := range []ComponentID{"a", "b"} {
+		registerAs(t, r, id, &barrierComponent{barrier: &barrier})
+		addRoute(t, r, "src", id)
+	}
+	r.modes["src"] = ParallelFanout
+	start(t, r)
+
+	// Sequential delivery would leave each destination waiting for the
+	// other, taking the sum of their delays
+	results, err := r.SendDetailed(msgMsg{src: "src", payload: 1})
+	if err != nil {
+		t.Fatalf("SendDetailed: %v", err)
+	}
+	for _, res := range results {
+		if !res.Delivered {
+			t.Fatalf("%s: %+v, want both destinations delivered together", res.Dest, res)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package msgrouter
+
+// MoveRoutes reassigns all of from's routes to to in one operation, e.g. when
+// a component is replaced under a new ID. Routes are appended after to's own
+// routes in their original order; a route to a destination to already routes
+// to is dropped rather than duplicated. Both IDs must be registered.
+func (r *GenericRouter) MoveRoutes(from, to ComponentID) error {
+	if !r.initialized() {
+		return opError("MoveRoutes", from, to, ErrNotInitialized)
+	}
+
+	return opError("MoveRoutes", from, to, r.exec(msgRt{op: MOVEROUTES, src: from, dest: to}))
+}
+
+// moveRoutes merges the routes of m.src into those of m.dest.
+func (r *GenericRouter) moveRoutes(m msgRt) error {
+
+	if !r.rc.has(m.src) || !r.rc.has(m.dest) {
+		return ErrNotRegistered
+	}
+	if m.src == m.dest {
+		return nil
+	}
+
+	for _, rte := range r.rt[m.src] {
+		dest, _ := rte.dest.GetID()
+
+		dup := false
+		for _, existing := range r.rt[m.dest] {
+			if r.matchDest(existing.dest, dest) {
+				dup = true
+				break
+			}
+		}
+		if dup {
+			rte.stop()
+			continue
+		}
+
+		// Delivery counts and coalesced batches belong to the new edge
+		rte.delivered = r.routeCounter(m.dest, dest)
+		if c := rte.coalescer; c != nil {
+			c.stop()
+			rte.coalescer = newCoalescer(r.clock, c.window, c.max, r.coalesceFlush(m.dest, rte.dest, rte.delivered))
+		}
+		r.insertRoute(r.rt, m.dest, rte, 0)
+	}
+	delete(r.rt, m.src)
+
+	return nil
+
+}
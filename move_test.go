@@ -0,0 +1,38 @@
+package msgrouter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMoveRoutes(t *testing.T) {
+	r := newRouter(t)
+	for _, id := range []ComponentID{"a", "b", "c1", "c2", "c3", "c4"} {
+		register(t, r, id)
+	}
+	for _, dest := range []ComponentID{"c1", "c2", "c3"} {
+		addRoute(t, r, "a", dest)
+	}
+	addRoute(t, r, "b", "c3")
+	addRoute(t, r, "b", "c4")
+	start(t, r)
+
+	if err := r.MoveRoutes("a", "b"); err != nil {
+		t.Fatalf("MoveRoutes: %v", err)
+	}
+	if dests, _ := r.GetRoutes("a"); len(dests) != 0 {
+		t.Fatalf("a routes to %v after the move, want nothing", dests)
+	}
+	dests, err := r.GetRoutes("b")
+	if err != nil {
+		t.Fatalf("GetRoutes: %v", err)
+	}
+	if want := []ComponentID{"c3", "c4", "c1", "c2"}; !reflect.DeepEqual(dests, want) {
+		t.Fatalf("b routes to %v, want %v", dests, want)
+	}
+
+	if err := r.MoveRoutes("b", "unknown"); !errors.Is(err, ErrNotRegistered) {
+		t.Fatalf("MoveRoutes to an unregistered ID = %v, want ErrNotRegistered", err)
+	}
+}
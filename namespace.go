@@ -0,0 +1,95 @@
+package msgrouter
+
+// DefaultNamespace is the namespace of routes added, and messages sent,
+// without one.
+//
+// A namespace is a separate routing table, letting the same components take
+// part in several route sets, e.g. one per tenant. AddRoute and RemoveRoute
+// act on the namespace set on the msgRt, and Send routes a message by the
+// routes of the namespace set on the msgMsg. Rules, taps, virtual and
+// wildcard routes and delivery modes are per source and apply in every
+// namespace. Pending routes and every other route operation, such as
+// listing, exporting or disabling routes, only cover the default namespace.
+//
+// A message can be routed by several namespaces at once by setting
+// namespaces on the msgMsg. It is then delivered over the union of the
+// source's routes in those namespaces, once to each destination.
+const DefaultNamespace = ""
+
+// table returns the routing table of namespace ns. The table of a namespace
+// without routes is nil, which reads as empty.
+func (r *GenericRouter) table(ns string) routingTable {
+	if ns == DefaultNamespace {
+		return r.rt
+	}
+	return r.namespaces[ns]
+}
+
+// tableFor returns the routing table of namespace ns, creating it on first
+// use.
+func (r *GenericRouter) tableFor(ns string) routingTable {
+	rt := r.table(ns)
+	if rt == nil {
+		rt = routingTable{}
+		r.namespaces[ns] = rt
+	}
+	return rt
+}
+
+// tables returns the routing tables of every namespace.
+func (r *GenericRouter) tables() []routingTable {
+	tables := make([]routingTable, 0, len(r.namespaces)+1)
+	tables = append(tables, r.rt)
+	for _, rt := range r.namespaces {
+		tables = append(tables, rt)
+	}
+	return tables
+}
+
+// routedBy reports whether m is routed by the routes of namespace ns.
+func (m msgMsg) routedBy(ns string) bool {
+	if m.namespaces == nil {
+		return m.namespace == ns
+	}
+	for _, n := range m.namespaces {
+		if n == ns {
+			return true
+		}
+	}
+	return false
+}
+
+// routesFor returns the routes of m's source in the namespaces m is routed
+// by. A destination routed to in several namespaces is included once, by its
+// first enabled route, or its first route if all are disabled.
+func (r *GenericRouter) routesFor(m msgMsg) []*route {
+
+	if m.namespaces == nil {
+		return r.table(m.namespace)[m.src]
+	}
+
+	// Disabled routes and duplicates wait for the enabled routes
+	var routes, rest []*route
+	seen := make(map[ComponentID]bool)
+	for _, ns := range m.namespaces {
+		for _, rte := range r.table(ns)[m.src] {
+			dest, _ := rte.dest.GetID()
+			if rte.disabled || seen[dest] {
+				rest = append(rest, rte)
+				continue
+			}
+			seen[dest] = true
+			routes = append(routes, rte)
+		}
+	}
+
+	for _, rte := range rest {
+		dest, _ := rte.dest.GetID()
+		if !seen[dest] && rte.disabled {
+			seen[dest] = true
+			routes = append(routes, rte)
+		}
+	}
+	return routes
+
+}
@@ -0,0 +1,67 @@
+package msgrouter
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNamespaces(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	dests := map[string]*testComponent{
+		DefaultNamespace: register(t, r, "default"),
+		"tenant-a":       register(t, r, "a"),
+		"tenant-b":       register(t, r, "b"),
+	}
+	for ns, dest := range map[string]ComponentID{DefaultNamespace: "default", "tenant-a": "a", "tenant-b": "b"} {
+		r.addRoute(msgRt{src: "src", dest: dest, namespace: ns})
+	}
+	start(t, r)
+
+	for _, ns := range []string{DefaultNamespace, "tenant-a", "tenant-b"} {
+		if _, err := r.SendDetailed(msgMsg{src: "src", payload: ns, namespace: ns}); err != nil {
+			t.Fatalf("SendDetailed(%q): %v", ns, err)
+		}
+	}
+
+	for ns, dest := range dests {
+		if got := dest.received(); len(got) != 1 || got[0] != ns {
+			t.Fatalf("namespace %q destination received %v, want only its own message", ns, got)
+		}
+	}
+}
+
+func TestMultipleNamespacesDeliverOnce(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	a := register(t, r, "a")
+	b := register(t, r, "b")
+	shared := register(t, r, "shared")
+	other := register(t, r, "other")
+	for _, rt := range []msgRt{
+		{src: "src", dest: "a", namespace: "tenant-a"},
+		{src: "src", dest: "shared", namespace: "tenant-a"},
+		{src: "src", dest: "b", namespace: "tenant-b"},
+		{src: "src", dest: "shared", namespace: "tenant-b"},
+		{src: "src", dest: "other", namespace: "tenant-c"},
+	} {
+		r.addRoute(rt)
+	}
+	start(t, r)
+
+	if err := r.Send(msgMsg{src: "src", payload: 1, namespaces: []string{"tenant-a", "tenant-b"}}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	for id, c := range map[ComponentID]*testComponent{"a": a, "b": b, "shared": shared} {
+		if n := c.count(); n != 1 {
+			t.Fatalf("%v received %d messages, want 1", id, n)
+		}
+	}
+	if n := other.count(); n != 0 {
+		t.Fatalf("other received %d messages from a namespace not sent to, want none", n)
+	}
+}
@@ -0,0 +1,228 @@
+package msgrouter
+
+import "time"
+
+// Option configures optional behavior of a GenericRouter. Options are passed
+// to NewGenericRouter and applied after the router's channels and tables have
+// been created.
+type Option func(*GenericRouter)
+
+// SendErrorFunc is called when delivering a payload to a destination
+// component fails. src is the sending component, dest the destination whose
+// Send failed.
+type SendErrorFunc func(src ComponentID, dest ComponentID, payload interface{}, err error)
+
+// WithOnSendError registers a callback which is invoked for every failed
+// delivery to a destination component.
+func WithOnSendError(fn SendErrorFunc) Option {
+	return func(r *GenericRouter) {
+		r.onSendError = fn
+	}
+}
+
+// WithRecoverSends makes the router recover from panics raised inside a
+// component's Send. The panic is converted into an error and reported through
+// the OnSendError callback and dead letter queue. Without this option a panicking component crashes
+// the program.
+func WithRecoverSends() Option {
+	return func(r *GenericRouter) {
+		r.recoverSends = true
+	}
+}
+
+// OverflowPolicy decides which message is dropped when Send finds the
+// router's message buffer full.
+type OverflowPolicy int
+
+const (
+	// DropNewest rejects the message being sent with ErrBufferFull. This is
+	// the default.
+	DropNewest OverflowPolicy = iota
+	// DropOldest evicts the oldest buffered message to make room for the
+	// message being sent, keeping the freshest data. The markers queued by
+	// DrainSource and Flush are never evicted and keep their place ahead of
+	// the messages sent after them.
+	DropOldest
+)
+
+// WithOverflowPolicy sets the policy used when the message buffer is full.
+func WithOverflowPolicy(p OverflowPolicy) Option {
+	return func(r *GenericRouter) {
+		r.overflowPolicy = p
+	}
+}
+
+// WithDeliveryTimeout bounds how long the router waits for a destination's
+// Send to return. On timeout the delivery is abandoned and reported with
+// ErrSendTimeout. The abandoned Send keeps running in its own go routine, so a
+// Send which truly never returns leaks that go routine.
+func WithDeliveryTimeout(d time.Duration) Option {
+	return func(r *GenericRouter) {
+		r.deliveryTimeout = d
+	}
+}
+
+// WithSendTimeout makes Send block up to d for buffer space before giving up
+// with ErrBufferFull (or evicting under DropOldest). A zero duration keeps the
+// default non-blocking behavior.
+func WithSendTimeout(d time.Duration) Option {
+	return func(r *GenericRouter) {
+		r.sendTimeout = d
+	}
+}
+
+// GuardFunc vets a component before it is registered or unregistered. A
+// non-nil error vetoes the operation and is returned to its caller.
+type GuardFunc func(c Component) error
+
+// WithRegisterGuard makes the router call guard before registering a
+// component, through RegisterComponent, RegisterWithID or Apply, and reject
+// the registration if guard returns an error.
+func WithRegisterGuard(guard GuardFunc) Option {
+	return func(r *GenericRouter) {
+		r.registerGuard = guard
+	}
+}
+
+// WithUnregisterGuard makes the router call guard before unregistering a
+// component and keep it registered if guard returns an error.
+func WithUnregisterGuard(guard GuardFunc) Option {
+	return func(r *GenericRouter) {
+		r.unregisterGuard = guard
+	}
+}
+
+// WithInsecureIDGen makes the router generate ComponentIDs from math/rand
+// instead of crypto/rand, for tests and embedded environments where
+// crypto/rand is unavailable. The IDs are predictable and NOT
+// cryptographically secure; don't use them where IDs must be unguessable.
+func WithInsecureIDGen() Option {
+	return func(r *GenericRouter) {
+		r.idGen = newInsecureUUIDGen()
+	}
+}
+
+// WithIDPrefix makes the router prepend prefix and a dash to the ComponentIDs
+// it generates, e.g. "billing-<uuid>", so IDs can be told apart in logs. The
+// UUID keeps IDs unique; see ParseComponentID and ComponentID.Prefix.
+func WithIDPrefix(prefix string) Option {
+	return func(r *GenericRouter) {
+		r.idPrefix = prefix
+	}
+}
+
+// generateID creates a ComponentID for a new registration.
+func (r *GenericRouter) generateID() (ComponentID, error) {
+	id, err := r.idGen()
+	if err != nil || r.idPrefix == "" {
+		return id, err
+	}
+	return ComponentID(r.idPrefix + "-" + string(id)), nil
+}
+
+// WithOnQueueWait registers a callback which the consume loop calls with the
+// time each message spent in the message buffer between Send and being
+// picked up for routing. The callback runs on the consume loop, so it must be
+// quick.
+func WithOnQueueWait(fn func(d time.Duration)) Option {
+	return func(r *GenericRouter) {
+		r.onQueueWait = fn
+	}
+}
+
+// WithInlineDelivery makes the consume loop deliver each message itself,
+// finishing one message before it handles the next message or operation,
+// instead of handing delivery to a new go routine. Delivery is then strictly
+// ordered and single threaded, but a slow destination stalls the whole
+// router. Delivery timeouts and the ParallelFanout mode still use go
+// routines.
+func WithInlineDelivery() Option {
+	return func(r *GenericRouter) {
+		r.inlineDelivery = true
+	}
+}
+
+// DestMatcher reports whether the component c is the one identified by id.
+type DestMatcher func(c Component, id ComponentID) bool
+
+// WithDestMatcher replaces how the router decides which route or tap an
+// operation naming a destination ID refers to. By default a component matches
+// id if its GetID returns id, so a component re-registered under the same ID,
+// e.g. in a new wrapper, still matches.
+func WithDestMatcher(fn DestMatcher) Option {
+	return func(r *GenericRouter) {
+		r.matchDest = fn
+	}
+}
+
+// matchID is the default DestMatcher, comparing the component's ID.
+func matchID(c Component, id ComponentID) bool {
+	cid, err := c.GetID()
+	return err == nil && cid == id
+}
+
+// WithErrors makes the router publish errors which have no caller to return
+// to, such as an unknown op code on a fire-and-forget operation, onto errs.
+// Publishing never blocks the router; if errs is full the error is dropped.
+func WithErrors(errs chan<- error) Option {
+	return func(r *GenericRouter) {
+		r.errs = errs
+	}
+}
+
+// WithPayloadCloner makes the router hand every route, rule and tap
+// destination its own copy of a payload, made by clone, so a destination
+// mutating its payload can't affect the others. Without it all destinations
+// of a message share the same payload.
+func WithPayloadCloner(clone func(interface{}) interface{}) Option {
+	return func(r *GenericRouter) {
+		r.cloner = clone
+	}
+}
+
+// clonePayload returns m carrying a copy of its payload if a cloner is set.
+func (r *GenericRouter) clonePayload(m msgMsg) msgMsg {
+	if r.cloner != nil {
+		m.payload = r.cloner(m.payload)
+	}
+	return m
+}
+
+// RegisterFunc is invoked with the ID and component of every registration.
+type RegisterFunc func(id ComponentID, c Component)
+
+// UnregisterFunc is invoked with the ID of every unregistered component.
+type UnregisterFunc func(id ComponentID)
+
+// WithOnRegister registers a callback which is invoked after each successful
+// registration, including those made by Apply, e.g. to mirror the registry
+// into a discovery service. It runs in the consume loop, which is blocked
+// until it returns, so it must be fast and must not call back into the
+// router; hand slow work to a go routine.
+func WithOnRegister(fn RegisterFunc) Option {
+	return func(r *GenericRouter) {
+		r.onRegister = fn
+	}
+}
+
+// WithOnUnregister registers a callback which is invoked after each
+// successful unregistration, with the same constraints as WithOnRegister.
+func WithOnUnregister(fn UnregisterFunc) Option {
+	return func(r *GenericRouter) {
+		r.onUnregister = fn
+	}
+}
+
+// notifyRegister calls the OnRegister callback if one is set.
+func (r *GenericRouter) notifyRegister(id ComponentID, c Component) {
+	if r.onRegister != nil {
+		r.onRegister(id, c)
+	}
+}
+
+// notifyUnregister calls the OnUnregister callback if one is set.
+func (r *GenericRouter) notifyUnregister(id ComponentID) {
+	if r.onUnregister != nil {
+		r.onUnregister(id)
+	}
+}
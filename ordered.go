@@ -0,0 +1,36 @@
+package msgrouter
+
+// WithOrderedDelivery guarantees that messages from a source reach each
+// destination in the order the source sent them, including across delivery
+// mode changes. Deliveries of a source are serialized: a message's delivery
+// go routine waits for the one before it to finish, so a slow destination
+// delays its source's later messages, though not other sources'. Retries
+// made under AtLeastOnce happen within a message's delivery, so a message
+// being retried holds back the source's later messages until it is
+// delivered or dead lettered. Messages parked for pending destinations are
+// delivered outside this order.
+func WithOrderedDelivery() Option {
+	return func(r *GenericRouter) {
+		r.ordered = make(map[ComponentID]chan struct{})
+	}
+}
+
+// orderedFanout delivers msgs once the source's previous delivery has
+// finished. It is called in the consume loop, which chains each delivery to
+// the last one for the source. finish is called once msgs are delivered.
+func (r *GenericRouter) orderedFanout(d delivery, msgs []msgMsg, finish func()) {
+
+	prev := r.ordered[d.src]
+	finished := make(chan struct{})
+	r.ordered[d.src] = finished
+
+	go func() {
+		defer finish()
+		defer close(finished)
+		if prev != nil {
+			<-prev
+		}
+		r.fanout(d, msgs)
+	}()
+
+}
@@ -0,0 +1,34 @@
+package msgrouter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOrderedDelivery(t *testing.T) {
+	// The send timeout lets Send wait for buffer space rather than fail
+	r := newRouter(t, WithOrderedDelivery(), WithSendTimeout(time.Second))
+	register(t, r, "src")
+	dest := register(t, r, "dest")
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	const n = 1000
+	for i := 0; i < n; i++ {
+		if i == n/2 {
+			if err := r.SetDeliveryMode("src", RoundRobin); err != nil {
+				t.Fatalf("SetDeliveryMode: %v", err)
+			}
+		}
+		if err := r.Send(msgMsg{src: "src", payload: i}); err != nil {
+			t.Fatalf("Send(%d): %v", i, err)
+		}
+	}
+	eventually(t, func() bool { return dest.count() == n })
+
+	for i, p := range dest.received() {
+		if p != i {
+			t.Fatalf("message %d arrived as %v, want a strictly increasing sequence", i, p)
+		}
+	}
+}
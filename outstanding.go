@@ -0,0 +1,33 @@
+package msgrouter
+
+import "sync/atomic"
+
+// inflightCounter returns the outstanding delivery counter for a
+// destination, creating it on first use. Routes to the same destination share
+// one counter.
+func (r *GenericRouter) inflightCounter(dest ComponentID) *int64 {
+	c, ok := r.inflight[dest]
+	if !ok {
+		c = new(int64)
+		r.inflight[dest] = c
+	}
+	return c
+}
+
+// selectLeastOutstanding chooses the route whose destination has the fewest
+// deliveries in flight. Ties go to the route added first.
+func selectLeastOutstanding(routes []route) []route {
+
+	if len(routes) == 0 {
+		return nil
+	}
+
+	best, bestCount := 0, atomic.LoadInt64(routes[0].inflight)
+	for i := 1; i < len(routes); i++ {
+		if n := atomic.LoadInt64(routes[i].inflight); n < bestCount {
+			best, bestCount = i, n
+		}
+	}
+
+	return routes[best : best+1]
+}
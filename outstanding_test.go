@@ -0,0 +1,37 @@
+package msgrouter
+
+import "testing"
+
+func TestLeastOutstandingPrefersIdleWorker(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	slow := &gateComponent{entered: make(chan struct{}), gate: make(chan struct{})}
+	registerAs(t, r, "slow", slow)
+	fast := register(t, r, "fast")
+	addRoute(t, r, "src", "slow")
+	addRoute(t, r, "src", "fast")
+	start(t, r)
+	if err := r.SetDeliveryMode("src", LeastOutstanding); err != nil {
+		t.Fatalf("SetDeliveryMode: %v", err)
+	}
+
+	// Ties go to the first route, so the slow worker takes the first message
+	// and holds on to it
+	if err := r.Send(msgMsg{src: "src", payload: 0}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	<-slow.entered
+
+	for i := 1; i <= 10; i++ {
+		if err := r.Send(msgMsg{src: "src", payload: i}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		eventually(t, func() bool { return fast.count() == i })
+	}
+	close(slow.gate)
+
+	eventually(t, func() bool { return slow.count() == 1 })
+	if fast.count() != 10 {
+		t.Fatalf("fast worker received %d messages, want 10", fast.count())
+	}
+}
@@ -0,0 +1,226 @@
+package msgrouter
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrPendingFull is the dead letter error of a message which could not be
+// parked for an unregistered destination because its pending buffer is full.
+var ErrPendingFull = errors.New("Pending buffer full")
+
+// ErrPendingExpired is the dead letter error of a parked message whose
+// destination did not register within the pending TTL.
+var ErrPendingExpired = errors.New("Pending message expired")
+
+// pendingDest holds the routes waiting for an unregistered destination and
+// the messages parked for it.
+type pendingDest struct {
+	srcs   []ComponentID
+	parked []parkedMsg
+}
+
+// parkedMsg is a message held for a destination which isn't registered yet.
+type parkedMsg struct {
+	msg msgMsg
+	at  time.Time
+}
+
+// WithPendingDelivery lets routes be added to destinations which are not
+// registered yet. Messages for such a destination are parked, up to max per
+// destination, and delivered once a component registers under its ID with
+// RegisterWithID or Apply. Messages parked for longer than ttl are dead
+// lettered with ErrPendingExpired instead, and messages arriving at a full
+// buffer with ErrPendingFull.
+func WithPendingDelivery(max int, ttl time.Duration) Option {
+	return func(r *GenericRouter) {
+		r.pendingMax = max
+		r.pendingTTL = ttl
+	}
+}
+
+// addPendingRoute records a route from a registered source to an
+// unregistered destination, if pending delivery is enabled. It reports
+// whether the route is new.
+func (r *GenericRouter) addPendingRoute(m msgRt) bool {
+
+	if r.pendingMax <= 0 {
+		return false
+	}
+
+	p, ok := r.pending[m.dest]
+	if !ok {
+		p = &pendingDest{}
+		r.pending[m.dest] = p
+	}
+	for _, src := range p.srcs {
+		if src == m.src {
+			return false
+		}
+	}
+	p.srcs = append(p.srcs, m.src)
+	return true
+
+}
+
+// removePendingRoute removes a route waiting for an unregistered destination,
+// dead lettering its parked messages.
+func (r *GenericRouter) removePendingRoute(m msgRt) {
+
+	p, ok := r.pending[m.dest]
+	if !ok {
+		return
+	}
+
+	for i, src := range p.srcs {
+		if src != m.src {
+			continue
+		}
+		p.srcs = append(p.srcs[:i:i], p.srcs[i+1:]...)
+
+		kept := p.parked[:0]
+		for _, pm := range p.parked {
+			if pm.msg.src == m.src {
+				r.dropMessage(pm.msg.src, pm.msg.payload, NoRoute, ErrNoRoute)
+				continue
+			}
+			kept = append(kept, pm)
+		}
+		p.parked = kept
+
+		if len(p.srcs) == 0 {
+			delete(r.pending, m.dest)
+		}
+		return
+	}
+
+}
+
+// hasPending reports whether src has routes waiting for a destination.
+func (r *GenericRouter) hasPending(src ComponentID) bool {
+
+	for _, p := range r.pending {
+		for _, s := range p.srcs {
+			if s == src {
+				return true
+			}
+		}
+	}
+	return false
+
+}
+
+// pendingFor reports whether m's source routes to an unregistered
+// destination which m's predicate, if any, selects.
+func (r *GenericRouter) pendingFor(m msgMsg) bool {
+
+	for dest, p := range r.pending {
+		if p.waiting(m, dest) {
+			return true
+		}
+	}
+	return false
+
+}
+
+// waiting reports whether m is to be parked for dest.
+func (p *pendingDest) waiting(m msgMsg, dest ComponentID) bool {
+
+	if m.where != nil && !m.where(dest) {
+		return false
+	}
+	for _, s := range p.srcs {
+		if s == m.src {
+			return true
+		}
+	}
+	return false
+
+}
+
+// park holds a copy of msgs for every unregistered destination m's source
+// routes to.
+func (r *GenericRouter) park(m msgMsg, msgs []msgMsg) {
+
+	src := m.src
+	now := r.clock.Now()
+	for dest, p := range r.pending {
+		if !p.waiting(m, dest) {
+			continue
+		}
+
+		p.expire(r, now)
+		for _, pm := range msgs {
+			if len(p.parked) >= r.pendingMax {
+				r.dropMessage(src, pm.payload, PendingFull, ErrPendingFull)
+				continue
+			}
+			p.parked = append(p.parked, parkedMsg{msg: pm, at: now})
+		}
+	}
+
+}
+
+// expire dead letters the parked messages older than the pending TTL.
+func (p *pendingDest) expire(r *GenericRouter, now time.Time) {
+
+	if r.pendingTTL <= 0 {
+		return
+	}
+
+	kept := p.parked[:0]
+	for _, pm := range p.parked {
+		if now.Sub(pm.at) > r.pendingTTL {
+			r.dropMessage(pm.msg.src, pm.msg.payload, Expired, ErrPendingExpired)
+			continue
+		}
+		kept = append(kept, pm)
+	}
+	p.parked = kept
+
+}
+
+// flushPending adds the routes waiting for a newly registered destination
+// and delivers the messages parked for it, in the order they were sent.
+func (r *GenericRouter) flushPending(dest ComponentID) {
+
+	p, ok := r.pending[dest]
+	if !ok {
+		return
+	}
+	delete(r.pending, dest)
+
+	for _, src := range p.srcs {
+		r.addRoute(msgRt{src: src, dest: dest})
+	}
+
+	p.expire(r, r.clock.Now())
+	deliveries := make([]delivery, 0, len(p.parked))
+	msgs := make([]msgMsg, 0, len(p.parked))
+	for _, pm := range p.parked {
+		rte, err := r.findRoute(pm.msg.src, dest)
+		if err != nil {
+			r.dropMessage(pm.msg.src, pm.msg.payload, NoRoute, err)
+			continue
+		}
+		deliveries = append(deliveries, delivery{src: pm.msg.src, routes: []route{*rte}})
+		msgs = append(msgs, pm.msg)
+	}
+
+	// One go routine keeps the parked messages in order
+	flush := func() {
+		for i, d := range deliveries {
+			r.fanout(d, msgs[i:i+1])
+		}
+	}
+	if r.inlineDelivery {
+		flush()
+		return
+	}
+	finish := r.startDelivery()
+	go func() {
+		defer finish()
+		flush()
+	}()
+
+}
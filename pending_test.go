@@ -0,0 +1,33 @@
+package msgrouter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPendingDeliveryWaitsForRegistration(t *testing.T) {
+	r := newRouter(t, WithPendingDelivery(8, time.Minute))
+	register(t, r, "src")
+	addRoute(t, r, "src", "late")
+	start(t, r)
+
+	if _, err := r.SendDetailed(msgMsg{src: "src", payload: "parked"}); err != nil {
+		t.Fatalf("SendDetailed: %v", err)
+	}
+	late := &testComponent{}
+	if err := r.RegisterWithID("late", late); err != nil {
+		t.Fatalf("RegisterWithID: %v", err)
+	}
+	eventually(t, func() bool { return late.count() == 1 })
+	if got := late.received(); got[0] != "parked" {
+		t.Fatalf("late destination received %v, want [parked]", got)
+	}
+
+	// The route now delivers directly
+	if _, err := r.SendDetailed(msgMsg{src: "src", payload: "direct"}); err != nil {
+		t.Fatalf("SendDetailed: %v", err)
+	}
+	if got := late.received(); len(got) != 2 || got[1] != "direct" {
+		t.Fatalf("late destination received %v, want [parked direct]", got)
+	}
+}
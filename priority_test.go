@@ -0,0 +1,73 @@
+package msgrouter
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSourcePriorityJumpsDestinationQueue(t *testing.T) {
+	r := newRouter(t, WithDestinationQueues(64, 1))
+	register(t, r, "low")
+	register(t, r, "high")
+	dest := &gateComponent{entered: make(chan struct{}), gate: make(chan struct{})}
+	registerAs(t, r, "dest", dest)
+	addRoute(t, r, "low", "dest")
+	addRoute(t, r, "high", "dest")
+	start(t, r)
+	if err := r.SetSourcePriority("high", 5); err != nil {
+		t.Fatalf("SetSourcePriority: %v", err)
+	}
+
+	// Hold the first delivery, then queue two more behind it
+	send := func(src ComponentID, payload interface{}) {
+		t.Helper()
+		if err := r.Send(msgMsg{src: src, payload: payload}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	send("low", 0)
+	<-dest.entered
+	send("low", 1)
+	send("low", 2)
+	send("high", "boosted")
+	eventually(t, func() bool { return r.ComponentBacklog()["dest"] == 3 })
+
+	close(dest.gate)
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got, want := dest.received(), []interface{}{0, "boosted", 1, 2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("dest received %v, want the boosted message ahead of the queue %v", got, want)
+	}
+}
+
+func TestSourcePriorityOvertakesBufferedBacklog(t *testing.T) {
+	r := newRouter(t, WithInlineDelivery())
+	register(t, r, "low")
+	register(t, r, "high")
+	dest := register(t, r, "dest")
+	addRoute(t, r, "low", "dest")
+	addRoute(t, r, "high", "dest")
+	if err := r.SetSourcePriority("high", 5); err != nil {
+		t.Fatalf("SetSourcePriority: %v", err)
+	}
+
+	// Buffer a backlog before the consume loop runs
+	for i := 0; i < 3; i++ {
+		if err := r.Send(msgMsg{src: "low", payload: i}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	if err := r.Send(msgMsg{src: "high", payload: "boosted"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	start(t, r)
+
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got, want := dest.received(), []interface{}{"boosted", 0, 1, 2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("dest received %v, want the boosted message ahead of the backlog %v", got, want)
+	}
+}
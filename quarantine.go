@@ -0,0 +1,96 @@
+package msgrouter
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrQuarantined is the dead letter error of messages from a quarantined
+// source.
+var ErrQuarantined = errors.New("Source is quarantined")
+
+// sourceHealth tracks a source's consecutive delivery failures. It is shared
+// with delivery go routines, so it is updated atomically.
+type sourceHealth struct {
+	failures    int64
+	quarantined int32
+}
+
+// WithQuarantine makes the router quarantine a source once n deliveries of
+// its messages in a row have failed. A delivery to an AckingComponent which
+// is NACKed, or still not ACKed, once its retries are exhausted counts as
+// failed, like an error returned by Send. Messages from a quarantined source
+// are dead lettered with ErrQuarantined instead of being routed until the
+// source is released with Release. SourceQuarantined and SourceReleased
+// events mark the transitions.
+func WithQuarantine(n int) Option {
+	return func(r *GenericRouter) {
+		r.quarantineAfter = n
+	}
+}
+
+// Release lifts the quarantine of src and resets its failure count. Releasing
+// a source which is not quarantined does nothing.
+func (r *GenericRouter) Release(src ComponentID) error {
+	if !r.initialized() {
+		return opError("Release", src, ZeroComponentID, ErrNotInitialized)
+	}
+
+	return opError("Release", src, ZeroComponentID, r.exec(msgRt{op: RELEASE, src: src}))
+}
+
+// release lifts a source's quarantine.
+func (r *GenericRouter) release(m msgRt) error {
+
+	if !r.rc.has(m.src) {
+		return ErrNotRegistered
+	}
+
+	health, ok := r.health[m.src]
+	if !ok {
+		return nil
+	}
+
+	atomic.StoreInt64(&health.failures, 0)
+	if atomic.CompareAndSwapInt32(&health.quarantined, 1, 0) {
+		r.emit(Event{Type: SourceReleased, Src: m.src})
+	}
+	return nil
+
+}
+
+// sourceHealth returns the health of src, or nil if quarantine is disabled.
+func (r *GenericRouter) sourceHealth(src ComponentID) *sourceHealth {
+
+	if r.quarantineAfter <= 0 {
+		return nil
+	}
+
+	health, ok := r.health[src]
+	if !ok {
+		health = &sourceHealth{}
+		r.health[src] = health
+	}
+	return health
+
+}
+
+// trackHealth records the outcome of a delivery of one of d's messages,
+// quarantining its source once too many deliveries failed in a row.
+func (r *GenericRouter) trackHealth(d *delivery, err error) {
+
+	if d.health == nil {
+		return
+	}
+
+	if err == nil {
+		atomic.StoreInt64(&d.health.failures, 0)
+		return
+	}
+
+	if atomic.AddInt64(&d.health.failures, 1) >= int64(r.quarantineAfter) &&
+		atomic.CompareAndSwapInt32(&d.health.quarantined, 0, 1) {
+		r.emit(Event{Type: SourceQuarantined, Src: d.src})
+	}
+
+}
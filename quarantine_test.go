@@ -0,0 +1,93 @@
+package msgrouter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQuarantineAndRelease(t *testing.T) {
+	dlq := make(chan DeadLetter, 4)
+	r := newRouter(t, WithQuarantine(2), WithDeadLetterQueue(dlq))
+	register(t, r, "src")
+	dest := register(t, r, "dest")
+	dest.err = errors.New("failed")
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	// Two failed deliveries, then the third message is quarantined
+	for i := 0; i < 2; i++ {
+		if _, err := r.SendDetailed(msgMsg{src: "src", payload: i}); err != nil {
+			t.Fatalf("SendDetailed: %v", err)
+		}
+		if dl := <-dlq; errors.Is(dl.Err, ErrQuarantined) {
+			t.Fatalf("dead letter %+v, want a send error", dl)
+		}
+	}
+	if _, err := r.SendDetailed(msgMsg{src: "src", payload: 2}); !errors.Is(err, ErrQuarantined) {
+		t.Fatalf("SendDetailed = %v, want ErrQuarantined", err)
+	}
+	if dl := <-dlq; dl.Payload != 2 || !errors.Is(dl.Err, ErrQuarantined) {
+		t.Fatalf("dead letter %+v, want payload 2 quarantined", dl)
+	}
+
+	dest.mu.Lock()
+	dest.err = nil
+	dest.mu.Unlock()
+	if err := r.Release("src"); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if _, err := r.SendDetailed(msgMsg{src: "src", payload: 3}); err != nil {
+		t.Fatalf("SendDetailed: %v", err)
+	}
+	if got := dest.received(); len(got) != 3 || got[2] != 3 {
+		t.Fatalf("received %v, want payload 3 delivered after Release", got)
+	}
+}
+
+// silentComponent never acknowledges its deliveries.
+type silentComponent struct {
+	testComponent
+}
+
+func (c *silentComponent) SendAck(payload interface{}) (Ack, error) {
+	c.Send(payload)
+	return make(chan bool), nil
+}
+
+func TestAckTimeoutsQuarantine(t *testing.T) {
+	dlq := make(chan DeadLetter, 4)
+	r := newRouter(t,
+		WithQuarantine(2),
+		WithRetryPolicy(RetryPolicy{AckTimeout: 5 * time.Millisecond}),
+		WithDeadLetterQueue(dlq),
+		WithInlineDelivery(),
+	)
+	register(t, r, "src")
+	dest := &silentComponent{}
+	registerAs(t, r, "dest", dest)
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	for i := 0; i < 3; i++ {
+		if err := r.Send(msgMsg{src: "src", payload: i}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if dl := <-dlq; !errors.Is(dl.Err, ErrAckTimeout) {
+			t.Fatalf("dead letter %+v, want an ACK timeout", dl)
+		}
+	}
+	if dl := <-dlq; dl.Payload != 2 || dl.Reason != Quarantined {
+		t.Fatalf("dead letter %+v, want payload 2 quarantined", dl)
+	}
+	if n := dest.count(); n != 2 {
+		t.Fatalf("dest received %d deliveries, want 2 before the quarantine", n)
+	}
+}
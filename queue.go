@@ -0,0 +1,224 @@
+package msgrouter
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrQueueFull is reported when a delivery can't be queued because the
+// destination's queue is full, see WithDestinationQueues.
+var ErrQueueFull = errors.New("Destination queue full")
+
+// WithDestinationQueues gives every destination its own delivery queue of up
+// to depth deliveries, drained by a single worker, so each destination
+// receives its messages one at a time, in the order they were routed among
+// messages of equal priority and higher priorities first, while different
+// destinations are delivered to in parallel. At most workers
+// destinations are delivered to at once; the others wait for a free worker
+// and keep queueing meanwhile. A slow destination therefore only delays its
+// own queue. Deliveries which find their queue full fail with ErrQueueFull
+// and are dead lettered. Routes, rules, wildcard routes and taps are all
+// queued, and the delivery mode of ParallelFanout sources is served by the
+// queues. WithInlineDelivery takes precedence; WithOrderedDelivery is
+// redundant, as the queues keep each source's order per destination for
+// messages of equal priority.
+func WithDestinationQueues(depth, workers int) Option {
+	return func(r *GenericRouter) {
+		if depth > 0 && workers > 0 {
+			r.queues = &destQueues{
+				depth:   depth,
+				workers: make(chan struct{}, workers),
+				queues:  make(map[ComponentID]*destQueue),
+			}
+		}
+	}
+}
+
+// destQueues holds the per destination queues. Jobs are added by the consume
+// loop and taken by the workers, so unlike the router's tables it is guarded
+// by a mutex.
+type destQueues struct {
+	mu     sync.Mutex
+	depth  int
+	queues map[ComponentID]*destQueue
+	// workers is a semaphore bounding the running workers. A worker go
+	// routine is only started once it holds a slot.
+	workers chan struct{}
+	// ready lists the queues waiting for a free worker, in the order they
+	// were first queued to
+	ready []ComponentID
+}
+
+// destQueue is a destination's queue of deliveries, highest priority first
+// and in routing order among equal priorities. running is set while a worker
+// drains it or it waits for one.
+type destQueue struct {
+	jobs    []queuedJob
+	running bool
+}
+
+// queuedJob is a queued delivery and the priority of its message.
+type queuedJob struct {
+	run      func()
+	priority int
+}
+
+// push queues a job behind every job of at least its priority.
+func (q *destQueue) push(job queuedJob) {
+	i := len(q.jobs)
+	for i > 0 && q.jobs[i-1].priority < job.priority {
+		i--
+	}
+	q.jobs = append(q.jobs, queuedJob{})
+	copy(q.jobs[i+1:], q.jobs[i:])
+	q.jobs[i] = job
+}
+
+// queueDelivery adds job, a delivery of a message with the given priority to
+// dest, to dest's queue, starting a worker for the queue if none is running
+// and a worker slot is free. Otherwise the queue waits for the next worker to
+// finish its queue. It reports false if the queue is full. The router's flush
+// epoch covers the job.
+func (r *GenericRouter) queueDelivery(dest Component, priority int, job func()) bool {
+
+	id, _ := dest.GetID()
+	qs := r.queues
+
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	q, ok := qs.queues[id]
+	if !ok {
+		q = &destQueue{}
+		qs.queues[id] = q
+	}
+	if len(q.jobs) >= qs.depth {
+		return false
+	}
+
+	finish := r.trackDelivery()
+	q.push(queuedJob{
+		priority: priority,
+		run: func() {
+			defer finish()
+			job()
+		},
+	})
+	if !q.running {
+		q.running = true
+		select {
+		case qs.workers <- struct{}{}:
+			atomic.AddInt64(&r.counters.active, 1)
+			go func() {
+				defer atomic.AddInt64(&r.counters.active, -1)
+				qs.work(id, q)
+			}()
+		default:
+			qs.ready = append(qs.ready, id)
+		}
+	}
+	return true
+
+}
+
+// work drains q, then the queues waiting for a worker, one after the other,
+// and gives up its worker slot once none is left. A queue is removed once
+// empty, so queues of unregistered destinations don't pile up.
+func (qs *destQueues) work(id ComponentID, q *destQueue) {
+
+	for {
+		qs.mu.Lock()
+		if len(q.jobs) == 0 {
+			q.running = false
+			delete(qs.queues, id)
+			if len(qs.ready) == 0 {
+				// Released under the lock, so queueDelivery either sees the
+				// slot free or queues behind this worker
+				<-qs.workers
+				qs.mu.Unlock()
+				return
+			}
+			id, qs.ready = qs.ready[0], qs.ready[1:]
+			q = qs.queues[id]
+			qs.mu.Unlock()
+			continue
+		}
+		job := q.jobs[0]
+		q.jobs[0] = queuedJob{}
+		q.jobs = q.jobs[1:]
+		qs.mu.Unlock()
+
+		job.run()
+	}
+
+}
+
+// routeQueued queues the delivery of m over rte, failing it with
+// ErrQueueFull if the destination's queue is full.
+func (r *GenericRouter) routeQueued(d *delivery, rte route, m msgMsg, rec *detailRecorder) {
+
+	rec.hold()
+	queued := r.queueDelivery(rte.dest, m.priority, func() {
+		defer rec.release()
+		r.deliverRoute(d, rte, m, rec)
+	})
+	if !queued {
+		rec.release()
+		r.queueFull(d, rte.dest, m, rec)
+	}
+
+}
+
+// ruleQueued queues the delivery of m to a matching rule's destination.
+func (r *GenericRouter) ruleQueued(d *delivery, rl rule, m msgMsg, rec *detailRecorder) {
+
+	rec.hold()
+	queued := r.queueDelivery(rl.dest, m.priority, func() {
+		defer rec.release()
+		r.deliverRule(d, rl, m, rec)
+	})
+	if !queued {
+		rec.release()
+		r.queueFull(d, rl.dest, m, rec)
+	}
+
+}
+
+// tapQueued queues the delivery of m to a tap. Taps that can't keep up miss
+// messages, as they would on a failing Send.
+func (r *GenericRouter) tapQueued(observer Component, m msgMsg) {
+
+	r.queueDelivery(observer, m.priority, func() {
+		r.deliverTap(observer, r.clonePayload(m))
+	})
+
+}
+
+// queueFull fails a delivery which found its destination's queue full.
+func (r *GenericRouter) queueFull(d *delivery, dest Component, m msgMsg, rec *detailRecorder) {
+
+	r.sendError(d.src, dest, m.payload, ErrQueueFull)
+	rec.record(dest, ErrQueueFull)
+	r.trackHealth(d, ErrQueueFull)
+
+}
+
+// fanoutQueued queues m's deliveries to the selected routes, matching rules,
+// wildcard routes and taps.
+func (r *GenericRouter) fanoutQueued(d *delivery, selected []route, m msgMsg, rec *detailRecorder) {
+
+	for _, rte := range selected {
+		r.routeQueued(d, rte, m, rec)
+	}
+	for _, rl := range matchRules(d.rules, m.payload) {
+		r.ruleQueued(d, rl, m, rec)
+	}
+	for _, rte := range d.wildcard {
+		r.routeQueued(d, rte, m, rec)
+	}
+	for _, observer := range d.taps {
+		r.tapQueued(observer, m)
+	}
+
+}
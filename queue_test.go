@@ -0,0 +1,90 @@
+package msgrouter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestDestinationQueuesIsolateSlowDestination(t *testing.T) {
+	r := newRouter(t, WithDestinationQueues(64, 2))
+	register(t, r, "src")
+	slow := &gateComponent{entered: make(chan struct{}), gate: make(chan struct{})}
+	registerAs(t, r, "slow", slow)
+	fast := register(t, r, "fast")
+	addRoute(t, r, "src", "slow")
+	addRoute(t, r, "src", "fast")
+	start(t, r)
+
+	const n = 20
+	for i := 0; i < n; i++ {
+		if err := r.Send(msgMsg{src: "src", payload: i}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+
+	// slow holds its first message while fast receives them all
+	<-slow.entered
+	eventually(t, func() bool { return fast.count() == n })
+	if m := slow.count(); m != 0 {
+		t.Fatalf("slow received %d messages while held, want none", m)
+	}
+
+	close(slow.gate)
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	for name, c := range map[string]*testComponent{"slow": &slow.testComponent, "fast": fast} {
+		got := c.received()
+		if len(got) != n {
+			t.Fatalf("%s received %d messages, want %d", name, len(got), n)
+		}
+		for i, p := range got {
+			if p != i {
+				t.Fatalf("%s received message %d as %v, want them in order", name, i, p)
+			}
+		}
+	}
+}
+
+func TestDestinationQueuesWaitWithoutGoroutines(t *testing.T) {
+	r := newRouter(t, WithDestinationQueues(64, 1))
+	register(t, r, "src")
+	held := &gateComponent{entered: make(chan struct{}), gate: make(chan struct{})}
+	registerAs(t, r, "held", held)
+	addRoute(t, r, "src", "held")
+	const n = 30
+	dests := make([]*testComponent, n)
+	for i := range dests {
+		id := ComponentID(fmt.Sprintf("dest%d", i))
+		dests[i] = register(t, r, id)
+		addRoute(t, r, "src", id)
+	}
+	start(t, r)
+
+	if err := r.Send(msgMsg{src: "src", payload: 1}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	// The only worker is held, so the other queues wait without one
+	<-held.entered
+	eventually(t, func() bool {
+		r.queues.mu.Lock()
+		defer r.queues.mu.Unlock()
+		return len(r.queues.ready) == n
+	})
+	if active := r.ActiveDeliveries(); active != 1 {
+		t.Fatalf("%d delivery go routines running, want the one worker", active)
+	}
+
+	close(held.gate)
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	for i, dest := range dests {
+		if dest.count() != 1 {
+			t.Fatalf("dest%d received %d messages, want 1", i, dest.count())
+		}
+	}
+	eventually(t, func() bool { return r.ActiveDeliveries() == 0 })
+}
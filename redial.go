@@ -0,0 +1,189 @@
+package msgrouter
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// ErrOutboxFull is returned by the Send of a ConnComponent created by
+// DialComponent when its outbox has no room, e.g. during a long outage. The
+// router dead letters the payload like any failed delivery.
+var ErrOutboxFull = errors.New("Connection outbox full")
+
+// RedialPolicy controls how a ConnComponent created by DialComponent buffers
+// and reconnects.
+type RedialPolicy struct {
+	// Outbox is the number of frames buffered for writing, including while
+	// the connection is down.
+	Outbox int
+	// MinBackoff is the pause after the first failed dial. It doubles with
+	// every further failure, up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Heartbeat is the interval at which an empty frame is written while
+	// idle, so a dead connection is noticed before the next payload. It also
+	// bounds every write. Zero disables heartbeats.
+	Heartbeat time.Duration
+}
+
+// DefaultRedialPolicy supplies the values of zero Outbox, MinBackoff and
+// MaxBackoff fields.
+var DefaultRedialPolicy = RedialPolicy{
+	Outbox:     1024,
+	MinBackoff: 100 * time.Millisecond,
+	MaxBackoff: 10 * time.Second,
+}
+
+// heartbeat is the frame written by idle components, an empty payload.
+var heartbeat = make([]byte, 4)
+
+// DialComponent creates a ConnComponent which connects through dial and
+// reconnects with backoff whenever a write fails. Send queues frames on a
+// bounded outbox which a writer go routine drains, so payloads sent during an
+// outage are written once a new connection is up; a frame whose write failed
+// is retried on the new connection, so the peer may see it twice. Send returns
+// ErrOutboxFull once the outbox is full. The router emits ConnDisconnected and
+// ConnReconnected events for the component. Close stops the writer.
+func (r *GenericRouter) DialComponent(dial func() (net.Conn, error), codec Codec, p RedialPolicy) (*ConnComponent, error) {
+	if !r.initialized() {
+		return nil, opError("DialComponent", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+
+	if p.Outbox <= 0 {
+		p.Outbox = DefaultRedialPolicy.Outbox
+	}
+	if p.MinBackoff <= 0 {
+		p.MinBackoff = DefaultRedialPolicy.MinBackoff
+	}
+	if p.MaxBackoff < p.MinBackoff {
+		p.MaxBackoff = DefaultRedialPolicy.MaxBackoff
+	}
+
+	c := &ConnComponent{
+		codec:  codec,
+		outbox: make(chan []byte, p.Outbox),
+		closed: make(chan struct{}),
+	}
+	go c.run(r, dial, p)
+	return c, nil
+
+}
+
+// Close stops a ConnComponent created by DialComponent and closes its
+// connection. Frames still in the outbox are discarded. Other ConnComponents
+// close their connection.
+func (c *ConnComponent) Close() error {
+	if c.closed == nil {
+		return c.conn.Close()
+	}
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
+}
+
+// Backlog returns the number of frames waiting in the outbox of a
+// ConnComponent created by DialComponent, and 0 for other ConnComponents.
+func (c *ConnComponent) Backlog() int {
+	return len(c.outbox)
+}
+
+// queue adds a frame to the outbox.
+func (c *ConnComponent) queue(frame []byte) error {
+
+	select {
+	case <-c.closed:
+		return net.ErrClosed
+	default:
+	}
+
+	select {
+	case c.outbox <- frame:
+		return nil
+	default:
+		return ErrOutboxFull
+	}
+
+}
+
+// run writes the outbox onto the connection, dialing whenever there is none.
+func (c *ConnComponent) run(r *GenericRouter, dial func() (net.Conn, error), p RedialPolicy) {
+
+	var tick <-chan time.Time
+	var beats Timer
+	if p.Heartbeat > 0 {
+		beats = r.clock.NewTimer(p.Heartbeat)
+		defer beats.Stop()
+		tick = beats.C()
+	}
+
+	var conn net.Conn
+	var retry []byte
+	connected := false
+	for {
+		if conn == nil {
+			conn = c.redial(r.clock, dial, p)
+			if conn == nil {
+				return
+			}
+			if connected {
+				id, _ := c.GetID()
+				r.emit(Event{Type: ConnReconnected, Dest: id})
+			}
+			connected = true
+		}
+
+		frame, beat := retry, false
+		if frame == nil {
+			select {
+			case frame = <-c.outbox:
+			case <-tick:
+				frame, beat = heartbeat, true
+				beats.Reset(p.Heartbeat)
+			case <-c.closed:
+				conn.Close()
+				return
+			}
+		}
+
+		if p.Heartbeat > 0 {
+			conn.SetWriteDeadline(time.Now().Add(p.Heartbeat))
+		}
+		if _, err := conn.Write(frame); err != nil {
+			conn.Close()
+			conn = nil
+			retry = nil
+			if !beat {
+				retry = frame
+			}
+			id, _ := c.GetID()
+			r.emit(Event{Type: ConnDisconnected, Dest: id})
+			continue
+		}
+		retry = nil
+	}
+
+}
+
+// redial dials until it succeeds, backing off between failures. It returns
+// nil if the component is closed meanwhile.
+func (c *ConnComponent) redial(clock Clock, dial func() (net.Conn, error), p RedialPolicy) net.Conn {
+
+	backoff := p.MinBackoff
+	for {
+		conn, err := dial()
+		if err == nil {
+			return conn
+		}
+
+		select {
+		case <-clock.After(backoff):
+		case <-c.closed:
+			return nil
+		}
+		backoff *= 2
+		if backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+
+}
@@ -0,0 +1,96 @@
+package msgrouter
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// readFrame reads a frame written by a ConnComponent, as a string.
+func readFrame(t *testing.T, conn net.Conn) string {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var size [4]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		t.Fatalf("reading frame: %v", err)
+	}
+	b := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(conn, b); err != nil {
+		t.Fatalf("reading frame: %v", err)
+	}
+	return string(b)
+}
+
+func TestDialComponentReconnects(t *testing.T) {
+	events := make(chan Event, 16)
+	r := newRouter(t, WithEvents(events))
+	start(t, r)
+
+	// dial hands out the connections queued on conns, failing while there
+	// are none
+	conns := make(chan net.Conn, 1)
+	dial := func() (net.Conn, error) {
+		select {
+		case conn := <-conns:
+			return conn, nil
+		default:
+			return nil, errors.New("down")
+		}
+	}
+	client, server := net.Pipe()
+	conns <- client
+
+	c, err := r.DialComponent(dial, stringCodec{}, RedialPolicy{Outbox: 2, MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("DialComponent: %v", err)
+	}
+	defer c.Close()
+	if err := r.RegisterWithID("remote", c); err != nil {
+		t.Fatalf("RegisterWithID: %v", err)
+	}
+
+	if err := c.Send("a"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got := readFrame(t, server); got != "a" {
+		t.Fatalf("read %q, want a", got)
+	}
+
+	// Drop the connection. The writer fails on b and holds it for retry,
+	// leaving the outbox to buffer c and d.
+	server.Close()
+	if err := c.Send("b"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if ev := nextEvent(t, events, DeliveryComplete); ev.Type != ConnDisconnected || ev.Dest != "remote" {
+		t.Fatalf("event %+v, want remote disconnected", ev)
+	}
+	eventually(t, func() bool { return c.Backlog() == 0 })
+	for _, p := range []string{"c", "d"} {
+		if err := c.Send(p); err != nil {
+			t.Fatalf("Send(%s) during the outage: %v", p, err)
+		}
+	}
+	if n := c.Backlog(); n != 2 {
+		t.Fatalf("Backlog = %d during the outage, want 2", n)
+	}
+	if err := c.Send("e"); !errors.Is(err, ErrOutboxFull) {
+		t.Fatalf("Send to a full outbox = %v, want ErrOutboxFull", err)
+	}
+
+	// Restore the connection, the buffered frames follow in order
+	client, server = net.Pipe()
+	defer server.Close()
+	conns <- client
+	for _, want := range []string{"b", "c", "d"} {
+		if got := readFrame(t, server); got != want {
+			t.Fatalf("read %q after reconnecting, want %q", got, want)
+		}
+	}
+	if ev := nextEvent(t, events, DeliveryComplete); ev.Type != ConnReconnected || ev.Dest != "remote" {
+		t.Fatalf("event %+v, want remote reconnected", ev)
+	}
+}
@@ -0,0 +1,87 @@
+package msgrouter
+
+import "sync"
+
+// Registry holds registered components. Every GenericRouter has one; routers
+// created with the same Registry through WithRegistry share their components,
+// so a component registered through one router can be routed by all of them.
+// Routes stay per router. Unregistering a component removes it from every
+// router sharing the Registry; as with a registry of its own, the routes to
+// it are left in place in every router until removed with RemoveRoute.
+type Registry struct {
+	mu         sync.RWMutex
+	components map[ComponentID]Component
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{components: make(map[ComponentID]Component)}
+}
+
+// WithRegistry makes the router keep its components in reg instead of a
+// registry of its own.
+func WithRegistry(reg *Registry) Option {
+	return func(r *GenericRouter) {
+		r.rc = reg
+	}
+}
+
+// get looks up the component registered under id.
+func (reg *Registry) get(id ComponentID) (Component, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	c, ok := reg.components[id]
+	return c, ok
+}
+
+// has reports whether a component is registered under id.
+func (reg *Registry) has(id ComponentID) bool {
+	_, ok := reg.get(id)
+	return ok
+}
+
+// add registers c under id. It reports false, leaving the registry unchanged,
+// if id is taken.
+func (reg *Registry) add(id ComponentID, c Component) bool {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if _, ok := reg.components[id]; ok {
+		return false
+	}
+	reg.components[id] = c
+	return true
+}
+
+// remove unregisters id. It reports false if id was not registered.
+func (reg *Registry) remove(id ComponentID) bool {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if _, ok := reg.components[id]; !ok {
+		return false
+	}
+	delete(reg.components, id)
+	return true
+}
+
+// snapshot returns a copy of the registered components.
+func (reg *Registry) snapshot() map[ComponentID]Component {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	components := make(map[ComponentID]Component, len(reg.components))
+	for id, c := range reg.components {
+		components[id] = c
+	}
+	return components
+}
+
+// each calls fn for every registered component until fn returns false. The
+// registry is read locked meanwhile.
+func (reg *Registry) each(fn func(ComponentID, Component) bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	for id, c := range reg.components {
+		if !fn(id, c) {
+			return
+		}
+	}
+}
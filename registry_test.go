@@ -0,0 +1,55 @@
+package msgrouter
+
+import "testing"
+
+func TestSharedRegistryRoutesThroughBothRouters(t *testing.T) {
+	reg := NewRegistry()
+	r1 := newRouter(t, WithRegistry(reg))
+	r2 := newRouter(t, WithRegistry(reg))
+	// Registered once, through r1 only
+	register(t, r1, "src")
+	a := register(t, r1, "a")
+	b := register(t, r1, "b")
+	addRoute(t, r1, "src", "a")
+	addRoute(t, r2, "src", "b")
+	start(t, r1)
+	start(t, r2)
+
+	if err := r1.Send(msgMsg{src: "src", payload: 1}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := r2.Send(msgMsg{src: "src", payload: 2}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	eventually(t, func() bool { return a.count() == 1 && b.count() == 1 })
+	if got := a.received(); got[0] != 1 {
+		t.Fatalf("a received %v, want [1]", got)
+	}
+	if got := b.received(); got[0] != 2 {
+		t.Fatalf("b received %v, want [2]", got)
+	}
+}
+
+func TestSharedRegistryUnregisterLeavesRoutes(t *testing.T) {
+	reg := NewRegistry()
+	r1 := newRouter(t, WithRegistry(reg))
+	r2 := newRouter(t, WithRegistry(reg))
+	register(t, r1, "src")
+	dest := register(t, r1, "dest")
+	addRoute(t, r1, "src", "dest")
+	addRoute(t, r2, "src", "dest")
+	start(t, r1)
+	start(t, r2)
+
+	if err := r1.UnregisterComponent(msgReg{c: dest}); err != nil {
+		t.Fatalf("UnregisterComponent: %v", err)
+	}
+	for _, r := range []*GenericRouter{r1, r2} {
+		if ids, _ := r.ListComponents(); len(ids) != 1 {
+			t.Fatalf("registered %v, want only src", ids)
+		}
+		if dests, _ := r.GetRoutes("src"); len(dests) != 1 {
+			t.Fatalf("routes %v after unregister, want the route left in place", dests)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package msgrouter
+
+// RegisterAndRoute registers c and adds a route from src to it in a single
+// consume loop operation, so no message from src is routed while c is
+// registered but not yet wired. It returns c's ID. If the route can't be
+// added, e.g. because src is not registered, c is left unregistered. A c
+// which is already registered is only routed to.
+func (r *GenericRouter) RegisterAndRoute(src ComponentID, c Component) (ComponentID, error) {
+	if !r.initialized() {
+		return ZeroComponentID, opError("RegisterAndRoute", src, ZeroComponentID, ErrNotInitialized)
+	}
+
+	v, err := r.query(msgRt{op: REGISTERANDROUTE, src: src, c: c})
+	if err != nil {
+		return ZeroComponentID, opError("RegisterAndRoute", src, ZeroComponentID, err)
+	}
+	return v.(ComponentID), nil
+}
+
+// registerAndRoute registers m.c and routes m.src to it, rolling back the
+// registration if the route fails.
+func (r *GenericRouter) registerAndRoute(m msgRt) {
+
+	if !r.routableSource(m.src) {
+		m.reply <- ErrNotRegistered
+		return
+	}
+
+	existed := false
+	if id, err := m.c.GetID(); err == nil {
+		c, ok := r.rc.get(id)
+		existed = ok && sameComponent(c, m.c)
+	}
+
+	if err := r.registerComponent(msgReg{c: m.c}); err != nil {
+		m.reply <- err
+		return
+	}
+	id, _ := m.c.GetID()
+
+	if _, err := r.addRoute(msgRt{src: m.src, dest: id}); err != nil {
+		if !existed {
+			r.rc.remove(id)
+			r.notifyUnregister(id)
+		}
+		m.reply <- err
+		return
+	}
+
+	m.reply <- id
+}
@@ -0,0 +1,55 @@
+package msgrouter
+
+import "testing"
+
+func TestRegisterAndRoute(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	start(t, r)
+
+	c := &testComponent{}
+	id, err := r.RegisterAndRoute("src", c)
+	if err != nil {
+		t.Fatalf("RegisterAndRoute: %v", err)
+	}
+	if got, _ := c.GetID(); got != id || id == ZeroComponentID {
+		t.Fatalf("RegisterAndRoute returned %v, component has ID %v", id, got)
+	}
+	if dests, _ := r.GetRoutes("src"); len(dests) != 1 || dests[0] != id {
+		t.Fatalf("src routes to %v, want [%v]", dests, id)
+	}
+
+	// An unknown source rolls the registration back
+	if _, err := r.RegisterAndRoute("unknown", &testComponent{}); err == nil {
+		t.Fatal("RegisterAndRoute from an unknown source succeeded")
+	}
+	if ids, _ := r.ListComponents(); len(ids) != 2 {
+		t.Fatalf("registered %v, want only src and %v", ids, id)
+	}
+	if sources, edges, _ := r.RouteCount(); sources != 1 || edges != 1 {
+		t.Fatalf("RouteCount = %d, %d after the failed call, want 1, 1", sources, edges)
+	}
+}
+
+func TestRegisterAndRouteUncomparableComponent(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	c := uncomparableComponent{testComponent: &testComponent{}, tags: []string{"x"}}
+	registerAs(t, r, "dest", c)
+	start(t, r)
+
+	// Already registered, so it is only routed to
+	id, err := r.RegisterAndRoute("src", c)
+	if err != nil {
+		t.Fatalf("RegisterAndRoute: %v", err)
+	}
+	if id != "dest" {
+		t.Fatalf("RegisterAndRoute returned %v, want dest", id)
+	}
+	if ids, _ := r.ListComponents(); len(ids) != 2 {
+		t.Fatalf("registered %v, want src and dest", ids)
+	}
+	if dests, _ := r.GetRoutes("src"); len(dests) != 1 || dests[0] != "dest" {
+		t.Fatalf("routes %v, want [dest]", dests)
+	}
+}
@@ -0,0 +1,57 @@
+package msgrouter
+
+// WithReplay makes the router retain the last n messages sent by topic and
+// replay them to every destination subscribing to it afterwards. The router
+// has no separate notion of topics: a topic is the ID of the source
+// publishing to it, and a destination subscribes by adding a route from it.
+// Replayed messages are handed to delivery when the route is added, before
+// any message the source sends later, so with WithOrderedDelivery or
+// WithInlineDelivery they arrive ahead of live messages. Messages sent while
+// the topic has no subscribers are retained rather than dead lettered with
+// ErrNoRoute. Messages sent with explicit destinations are not retained.
+func WithReplay(topic ComponentID, n int) Option {
+	return func(r *GenericRouter) {
+		if n > 0 {
+			r.replay[topic] = &replayBuffer{max: n}
+		}
+	}
+}
+
+// replayBuffer holds the most recent messages of a topic, oldest first.
+type replayBuffer struct {
+	msgs []msgMsg
+	max  int
+}
+
+// retain records msgs in src's replay buffer, if it has one, evicting the
+// oldest messages beyond its size.
+func (r *GenericRouter) retain(src ComponentID, msgs []msgMsg) {
+
+	buf, ok := r.replay[src]
+	if !ok {
+		return
+	}
+
+	for _, m := range msgs {
+		// A replay may happen long after the sender stopped caring
+		m.ctx = nil
+		buf.msgs = append(buf.msgs, m)
+	}
+	if over := len(buf.msgs) - buf.max; over > 0 {
+		buf.msgs = append(buf.msgs[:0], buf.msgs[over:]...)
+	}
+
+}
+
+// replayTo delivers src's retained messages over a newly added route.
+func (r *GenericRouter) replayTo(src ComponentID, rte *route) {
+
+	buf, ok := r.replay[src]
+	if !ok || len(buf.msgs) == 0 {
+		return
+	}
+
+	msgs := append([]msgMsg(nil), buf.msgs...)
+	r.dispatch(delivery{src: src, routes: []route{*rte}}, msgs)
+
+}
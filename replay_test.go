@@ -0,0 +1,30 @@
+package msgrouter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReplayToLateSubscriber(t *testing.T) {
+	r := newRouter(t, WithReplay("topic", 2), WithInlineDelivery())
+	register(t, r, "topic")
+	sub := register(t, r, "sub")
+	start(t, r)
+
+	// Published before anyone subscribes
+	for i := 1; i <= 3; i++ {
+		if _, err := r.SendDetailed(msgMsg{src: "topic", payload: i}); err != nil {
+			t.Fatalf("SendDetailed: %v", err)
+		}
+	}
+	if _, err := r.AddRoute(msgRt{src: "topic", dest: "sub"}); err != nil {
+		t.Fatalf("AddRoute: %v", err)
+	}
+	if _, err := r.SendDetailed(msgMsg{src: "topic", payload: 4}); err != nil {
+		t.Fatalf("SendDetailed: %v", err)
+	}
+
+	if got, want := sub.received(), []interface{}{2, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("subscriber received %v, want the two most recent then live %v", got, want)
+	}
+}
@@ -0,0 +1,61 @@
+package msgrouter
+
+import "errors"
+
+// Resize changes the capacity of the message buffer without recreating the
+// router. Buffered messages are moved to the new buffer in order; if it is
+// smaller than the number of messages buffered, the oldest are routed right
+// away instead. Sends are held off while the buffer is swapped, and Resize
+// waits for Sends blocked on a full buffer (see WithSendTimeout) to finish.
+func (r *GenericRouter) Resize(msgBuf int) error {
+	if !r.initialized() {
+		return opError("Resize", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+	if msgBuf < 0 {
+		return opError("Resize", ZeroComponentID, ZeroComponentID, errors.New("Buffer size must not be negative"))
+	}
+
+	return opError("Resize", ZeroComponentID, ZeroComponentID, r.exec(msgRt{op: RESIZE, size: msgBuf}))
+}
+
+// resize swaps in a message channel of the requested capacity. It runs in
+// the consume loop, routing messages while it waits for senders to release
+// the channel so blocked senders can finish. The lock is taken by a helper go
+// routine, which hands it over once it holds it.
+func (r *GenericRouter) resize(m msgRt) {
+
+	locked := make(chan struct{})
+	go func() {
+		r.msgMu.Lock()
+		close(locked)
+	}()
+	for waiting := true; waiting; {
+		select {
+		case msg := <-r.internalMsgChan:
+			r.receiveMsg(msg)
+		case <-locked:
+			waiting = false
+		}
+	}
+
+	// The oldest messages may not fit, route them once the lock is released
+	// so destinations sending back into the router don't deadlock
+	var overflow []msgMsg
+	for len(r.internalMsgChan) > m.size {
+		overflow = append(overflow, <-r.internalMsgChan)
+	}
+
+	msgChan := make(chan msgMsg, m.size)
+	for len(r.internalMsgChan) > 0 {
+		msgChan <- <-r.internalMsgChan
+	}
+	r.externalMsgChan, r.internalMsgChan = msgChan, msgChan
+	r.msgMu.Unlock()
+
+	r.handleEvicted()
+	for _, msg := range overflow {
+		r.handleMsg(msg)
+	}
+	m.errc <- nil
+
+}
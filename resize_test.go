@@ -0,0 +1,60 @@
+package msgrouter
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResizeUnderLoad(t *testing.T) {
+	// Inline, so lost or reordered messages show in the received order
+	r := newRouter(t, WithInlineDelivery(), WithSendTimeout(time.Second))
+	register(t, r, "src")
+	dest := register(t, r, "dest")
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	const n = 500
+	sent := make(chan error, 1)
+	go func() {
+		for i := 0; i < n; i++ {
+			if err := r.Send(msgMsg{src: "src", payload: i}); err != nil {
+				sent <- err
+				return
+			}
+		}
+		sent <- nil
+	}()
+
+	for _, size := range []int{8, 256, 1, 0, 64} {
+		if err := r.Resize(size); err != nil {
+			t.Fatalf("Resize(%d): %v", size, err)
+		}
+	}
+	if err := <-sent; err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	eventually(t, func() bool { return dest.count() == n })
+
+	for i, p := range dest.received() {
+		if p != i {
+			t.Fatalf("message %d arrived as %v, want them in order", i, p)
+		}
+	}
+}
+
+func TestResizeErrorsAreRouterErrors(t *testing.T) {
+	r := newRouter(t)
+	start(t, r)
+
+	var rerr *RouterError
+	if err := r.Resize(-1); !errors.As(err, &rerr) || rerr.Op != "Resize" {
+		t.Fatalf("Resize(-1) = %v, want a RouterError for Resize", err)
+	}
+
+	r.Stop()
+	err := r.Resize(8)
+	if !errors.As(err, &rerr) || rerr.Op != "Resize" || !errors.Is(err, ErrRouterClosed) {
+		t.Fatalf("Resize after Stop = %v, want a RouterError wrapping ErrRouterClosed", err)
+	}
+}
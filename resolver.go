@@ -0,0 +1,79 @@
+package msgrouter
+
+// Resolver returns the concrete destinations of a virtual destination at
+// delivery time, e.g. the currently healthy replicas of a service.
+type Resolver func() []ComponentID
+
+// virtualRoute is a route whose destinations are resolved per delivery.
+type virtualRoute struct {
+	name     ComponentID
+	resolver Resolver
+}
+
+// addVirtualRoute stores a route from a registered source to a virtual
+// destination. The virtual name is the msgRt's dest and need not be
+// registered; re-adding a name replaces its resolver.
+func (r *GenericRouter) addVirtualRoute(m msgRt) (bool, error) {
+
+	if !r.rc.has(m.src) {
+		return false, ErrNotRegistered
+	}
+
+	for i, vr := range r.virtual[m.src] {
+		if vr.name == m.dest {
+			r.virtual[m.src][i].resolver = m.resolver
+			return false, nil
+		}
+	}
+
+	r.virtual[m.src] = append(r.virtual[m.src], virtualRoute{name: m.dest, resolver: m.resolver})
+	return true, nil
+
+}
+
+// removeVirtualRoute removes the virtual route named by the msgRt's dest. It
+// reports whether one was found.
+func (r *GenericRouter) removeVirtualRoute(m msgRt) bool {
+
+	vrs := r.virtual[m.src]
+	for i, vr := range vrs {
+		if vr.name != m.dest {
+			continue
+		}
+		vrs = append(vrs[:i:i], vrs[i+1:]...)
+		if len(vrs) == 0 {
+			delete(r.virtual, m.src)
+		} else {
+			r.virtual[m.src] = vrs
+		}
+		return true
+	}
+
+	return false
+}
+
+// resolveRoutes calls each of a source's resolvers and returns routes to the
+// resolved destinations. IDs which aren't registered are skipped and each
+// destination is included once. Resolvers run in the consume loop and must
+// be fast.
+func (r *GenericRouter) resolveRoutes(src ComponentID) []route {
+
+	var routes []route
+	seen := make(map[ComponentID]bool)
+	for _, vr := range r.virtual[src] {
+		for _, id := range vr.resolver() {
+			c, ok := r.rc.get(id)
+			if !ok || seen[id] {
+				continue
+			}
+			seen[id] = true
+			routes = append(routes, route{
+				dest:      c,
+				inflight:  r.inflightCounter(id),
+				delivered: r.routeCounter(src, id),
+			})
+		}
+	}
+
+	return routes
+}
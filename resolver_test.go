@@ -0,0 +1,47 @@
+package msgrouter
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestResolverDecidesDestinations(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	a := register(t, r, "a")
+	b := register(t, r, "b")
+
+	var mu sync.Mutex
+	healthy := []ComponentID{"a"}
+	resolve := func() []ComponentID {
+		mu.Lock()
+		defer mu.Unlock()
+		return healthy
+	}
+	r.addRoute(msgRt{src: "src", dest: "service", resolver: resolve})
+	start(t, r)
+
+	send := func(payload int, delivered func() bool) {
+		t.Helper()
+		if err := r.Send(msgMsg{src: "src", payload: payload}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		eventually(t, delivered)
+	}
+	send(1, func() bool { return a.count() == 1 })
+	mu.Lock()
+	healthy = []ComponentID{"a", "b", "unregistered"}
+	mu.Unlock()
+	send(2, func() bool { return a.count() == 2 && b.count() == 1 })
+	mu.Lock()
+	healthy = []ComponentID{"b"}
+	mu.Unlock()
+	send(3, func() bool { return b.count() == 2 })
+
+	if got := a.received(); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Fatalf("a received %v, want [1 2]", got)
+	}
+	if got := b.received(); len(got) != 2 || got[0] != 2 || got[1] != 3 {
+		t.Fatalf("b received %v, want [2 3]", got)
+	}
+}
@@ -1,8 +1,14 @@
 package msgrouter
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Router allows synchronization and routing decisions to be made between
@@ -14,9 +20,9 @@ type Router interface {
 	Send(msg *interface{}) error
 	RegisterComponent(msgMsg) error
 	UnregisterComponent(msgMsg) error
-	AddRoute(msgRt) error
+	AddRoute(msgRt) (bool, error)
 	RemoveRoute(msgRt) error
-	// ListRoutes() (string, error)
+	ListRoutes() (string, error)
 	Consume()
 }
 
@@ -29,6 +35,18 @@ const REGISTER = 0
 // handler
 const UNREGISTER = 1
 
+// LISTCOMPONENTS is an op code for msgReg. Tells router to use listComponents
+// handler.
+const LISTCOMPONENTS = 2
+
+// REGISTERWITHID is an op code for msgReg. Tells router to use
+// registerWithID handler.
+const REGISTERWITHID = 3
+
+// FOREACHCOMPONENT is an op code for msgReg. Tells router to use
+// forEachComponent handler.
+const FOREACHCOMPONENT = 4
+
 // ADDROUTE is an op code for msgRt. Tells router to use addRoute handler.
 const ADDROUTE = 0
 
@@ -38,11 +56,177 @@ const REMOVEROUTE = 1
 // LISTROUTES is an op code for msgRt. Tells router to use listRoutes handler.
 const LISTROUTES = 2
 
+// LISTROUTESBYLABEL is an op code for msgRt. Tells router to use
+// listRoutesByLabel handler.
+const LISTROUTESBYLABEL = 3
+
+// ADDTAP is an op code for msgRt. Tells router to use addTap handler.
+const ADDTAP = 4
+
+// REMOVETAP is an op code for msgRt. Tells router to use removeTap handler.
+const REMOVETAP = 5
+
+// EXPORTDOT is an op code for msgRt. Tells router to use exportDOT handler.
+const EXPORTDOT = 6
+
+// SCHEDULE is an op code for msgRt. Tells router to use schedule handler.
+const SCHEDULE = 7
+
+// CANCELSCHEDULED is an op code for msgRt. Tells router to use
+// cancelScheduled handler.
+const CANCELSCHEDULED = 8
+
+// GETROUTES is an op code for msgRt. Tells router to use getRoutes handler.
+const GETROUTES = 9
+
+// STATS is an op code for msgRt. Tells router to use stats handler.
+const STATS = 10
+
+// SETMODE is an op code for msgRt. Tells router to use setDeliveryMode
+// handler.
+const SETMODE = 11
+
+// DISABLEROUTE is an op code for msgRt. Tells router to use setRouteDisabled
+// handler.
+const DISABLEROUTE = 12
+
+// ENABLEROUTE is an op code for msgRt. Tells router to use setRouteDisabled
+// handler.
+const ENABLEROUTE = 13
+
+// APPLY is an op code for msgRt. Tells router to use apply handler.
+const APPLY = 14
+
+// ADDRULE is an op code for msgRt. Tells router to use addRule handler.
+const ADDRULE = 15
+
+// CLEARRULES is an op code for msgRt. Tells router to use clearRules handler.
+const CLEARRULES = 16
+
+// STOP is an op code for msgRt. Tells router to use stop handler and end the
+// consume loop.
+const STOP = 17
+
+// GETMODE is an op code for msgRt. Tells router to use getDeliveryMode
+// handler.
+const GETMODE = 18
+
+// ROUTESTATS is an op code for msgRt. Tells router to use routeStats handler.
+const ROUTESTATS = 19
+
+// TAGROUTE is an op code for msgRt. Tells router to use tagRoute handler.
+const TAGROUTE = 20
+
+// ENABLETAG is an op code for msgRt. Tells router to use setTagDisabled
+// handler.
+const ENABLETAG = 21
+
+// DISABLETAG is an op code for msgRt. Tells router to use setTagDisabled
+// handler.
+const DISABLETAG = 22
+
+// COMPACT is an op code for msgRt. Tells router to use compact handler.
+const COMPACT = 23
+
+// RELEASE is an op code for msgRt. Tells router to use release handler.
+const RELEASE = 24
+
+// RESETHIGHWATER is an op code for msgRt. Tells router to use resetHighWater
+// handler.
+const RESETHIGHWATER = 25
+
+// ROUTESTO is an op code for msgRt. Tells router to use routesTo handler.
+const ROUTESTO = 26
+
+// LISTROUTESLINES is an op code for msgRt. Tells router to use
+// listRoutesLines handler.
+const LISTROUTESLINES = 27
+
+// MOVEROUTES is an op code for msgRt. Tells router to use moveRoutes handler.
+const MOVEROUTES = 28
+
+// ROUTECOUNT is an op code for msgRt. Tells router to use routeCount handler.
+const ROUTECOUNT = 29
+
+// SETWEIGHT is an op code for msgRt. Tells router to use setRouteWeight
+// handler.
+const SETWEIGHT = 30
+
+// RESIZE is an op code for msgRt. Tells router to use resize handler.
+const RESIZE = 31
+
+// SNAPSHOT is an op code for msgRt. Tells router to use snapshot handler.
+const SNAPSHOT = 32
+
+// REGISTERANDROUTE is an op code for msgRt. Tells router to use
+// registerAndRoute handler.
+const REGISTERANDROUTE = 33
+
+// INSTALLTABLE is an op code for msgRt. Tells router to use installTable
+// handler.
+const INSTALLTABLE = 34
+
+// DRAINSOURCE is a marker code for msgMsg. Tells router to close the marker's
+// source once the messages ahead of it are routed.
+const DRAINSOURCE = 1
+
+// FLUSH is a marker code for msgMsg. Tells router to acknowledge the marker
+// once the messages ahead of it are delivered.
+const FLUSH = 2
+
 // ComponentID is an ID used to select registered components
 type ComponentID UUID
 
+// ZeroComponentID is the empty ComponentID. It means "no ID yet": components
+// report it before they are registered.
+const ZeroComponentID ComponentID = ""
+
+// IsZero reports whether id is the empty ZeroComponentID.
+func (id ComponentID) IsZero() bool {
+	return id == ZeroComponentID
+}
+
 // Map which correlates source component to one or more destination components
-type routingTable map[ComponentID][]Component
+type routingTable map[ComponentID][]*route
+
+// route is a single destination entry in a source's routing table. Metadata
+// stored here describes the edge between source and destination and does not
+// affect the destination component itself.
+type route struct {
+	dest      Component
+	labels    map[string]string
+	expires   time.Time
+	coalescer *coalescer
+	disabled  bool
+	// tags group routes so they can be enabled or disabled together
+	tags map[string]struct{}
+	// inflight counts deliveries to dest which have not returned yet
+	inflight *int64
+	// delivered counts successful deliveries on this edge
+	delivered *uint64
+	// order is the route's insertion sequence. A source's routes are kept
+	// sorted by it, so fanout follows the order routes were added in.
+	order uint64
+	// weight is the route's share of messages in the Weighted mode, zero
+	// meaning the default of 1
+	weight int
+}
+
+// stop releases resources held by a route once it leaves the routing table.
+func (rte *route) stop() {
+	if rte.coalescer != nil {
+		rte.coalescer.stop()
+	}
+}
+
+// RouteInfo describes a single route for admin queries.
+type RouteInfo struct {
+	Src    ComponentID
+	Dest   ComponentID
+	Labels map[string]string
+	// Order is the route's insertion sequence; see AddRoute.
+	Order uint64
+}
 
 // GenericRouter is an implementation of a router. External channels are for
 // API access while internal channels are for consuming off of.
@@ -55,8 +239,119 @@ type GenericRouter struct {
 	internalRtChan  <-chan msgRt
 	externalRegChan chan<- msgReg
 	internalRegChan <-chan msgReg
+	// priorityChan is the lane for messages with a priority, which the
+	// consume loop takes ahead of the message channel
+	priorityChan    chan msgMsg
 	rt              routingTable
-	rc              map[ComponentID]Component
+	rc              *Registry
+	seq             map[ComponentID]uint64
+	taps            map[ComponentID][]Component
+	onSendError     SendErrorFunc
+	onDeadLetter    DeadLetterFunc
+	onRegister      RegisterFunc
+	onUnregister    UnregisterFunc
+	recoverSends    bool
+	overflowPolicy  OverflowPolicy
+	retry           RetryPolicy
+	semantics       DeliverySemantics
+	deliveryTimeout time.Duration
+	sendTimeout     time.Duration
+	dlq             chan<- DeadLetter
+	dlqEvict        <-chan DeadLetter
+	dlRing          *deadLetterRing
+	schedules       scheduleHeap
+	scheduleIndex   map[ScheduleID]*scheduled
+	scheduleTimer   Timer
+	nextScheduleID  ScheduleID
+	expiryTimer     Timer
+	events          chan<- Event
+	counters        *counters
+	latency         *latencyCounters
+	rateWindow      time.Duration
+	rates           []rateSample
+	modes           map[ComponentID]Mode
+	keyExtractor    KeyExtractor
+	keyFallback     KeyFallback
+	errs            chan<- error
+	rules           map[ComponentID][]rule
+	inflight        map[ComponentID]*int64
+	rrIndex         map[ComponentID]int
+	closedSources   map[ComponentID]bool
+	hasher          Hasher
+	virtual         map[ComponentID][]virtualRoute
+	routeCounters   map[RouteKey]*uint64
+	idGen           func() (ComponentID, error)
+	idPrefix        string
+	maxPayloadBytes int
+	routeOrder      uint64
+	registerGuard   GuardFunc
+	unregisterGuard GuardFunc
+	quarantineAfter int
+	breakerAfter    int
+	breakerCooldown time.Duration
+	breakerMu       sync.Mutex
+	breakers        map[ComponentID]*breaker
+	health          map[ComponentID]*sourceHealth
+	highWater       HighWater
+	pending         map[ComponentID]*pendingDest
+	pendingMax      int
+	pendingTTL      time.Duration
+	onQueueWait     func(time.Duration)
+	inlineDelivery  bool
+	matchDest       DestMatcher
+	cloner          func(interface{}) interface{}
+	ordered         map[ComponentID]chan struct{}
+	priorityMu      sync.RWMutex
+	priorities      map[ComponentID]int
+	clock           Clock
+	deliverySlots   chan struct{}
+	queues          *destQueues
+	namespaces      map[string]routingTable
+	replay          map[ComponentID]*replayBuffer
+	flushEpoch      *sync.WaitGroup
+	lastFlush       chan struct{}
+	codec           Codec
+	remoteRegister  bool
+	shedHigh        int
+	shedLow         int
+	shedPriority    int
+	shedding        int32
+	// msgMu guards the message channel fields against Resize
+	msgMu sync.RWMutex
+	// evicted holds the markers DropOldest eviction took off the message
+	// channel, to be handled ahead of everything still buffered
+	evictMu sync.Mutex
+	evicted []msgMsg
+	// done is closed when the router is stopped, exited once Consume returns
+	done   chan struct{}
+	exited chan struct{}
+	// lifeMu guards consuming, which is set while Consume runs
+	lifeMu    sync.Mutex
+	consuming bool
+}
+
+// delivery is a snapshot of a source's routing state handed to a delivery
+// go routine, so delivery never reads the router's tables directly.
+type delivery struct {
+	src    ComponentID
+	mode   Mode
+	routes []route
+	taps   []Component
+	rules  []rule
+	// wildcard are the routes every source delivers to, see WildcardSource
+	wildcard []route
+	// rrStart is the round robin position of the first message
+	rrStart int
+	// detail receives the delivery's results if it was sent by SendDetailed
+	detail  chan sendDetail
+	skipped []DeliveryResult
+	// done is the message's done channel, see msgMsg
+	done chan error
+	// health tracks the source's delivery failures for quarantine
+	health *sourceHealth
+	// queued hands each delivery to its destination's queue, see
+	// WithDestinationQueues
+	queued bool
 }
 
 // msg* structs are used to package messages that will be sent on the
@@ -64,23 +359,89 @@ type GenericRouter struct {
 type msgMsg struct {
 	src     ComponentID
 	payload interface{}
+	batch   []interface{}
+	headers map[string]string
+	// enqueued is stamped by Send for latency measurement
+	enqueued time.Time
+	// priority decides which messages are shed under overload
+	priority int
+	// ctx is attached by SendCtx and handed to CtxComponent destinations
+	ctx context.Context
+	// detail is set by SendDetailed to receive per-destination results
+	detail chan sendDetail
+	// done, if set, receives the message's aggregated delivery error, if
+	// any, and is then closed once delivery completes. It should be buffered
+	// to hold the error; an error which doesn't fit is discarded but done is
+	// still closed. done is not used if Send returns an error.
+	done chan error
+	// namespace selects the routing table the message is routed by
+	namespace string
+	// namespaces, if set, replaces namespace: the message is routed by the
+	// routes of all of these namespaces, each destination receiving it once
+	namespaces []string
+	// dests, if set, are the only destinations the message is delivered to
+	dests []ComponentID
+	// where, if set, selects the destinations the message is delivered to
+	where func(ComponentID) bool
+	// marker and errc make this a control marker, handled in order with
+	// the messages ahead of it instead of being routed
+	marker int
+	errc   chan error
 }
 
 type msgRt struct {
-	op   int
-	src  ComponentID
-	dest ComponentID
+	op     int
+	src    ComponentID
+	dest   ComponentID
+	labels map[string]string
+	ttl    time.Duration
+	errc   chan error
+	reply  chan interface{}
+	// coalescing window and max batch size for ADDROUTE
+	coalesceWindow time.Duration
+	coalesceMax    int
+	// delivery mode for SETMODE
+	mode Mode
+	// operations for APPLY
+	ops []Op
+	// rule for ADDRULE
+	rule Rule
+	// resolver for ADDROUTE to a virtual destination named by dest
+	resolver Resolver
+	// tag for TAGROUTE, ENABLETAG and DISABLETAG
+	tag string
+	// insertion sequence for ADDROUTE, zero assigns the next one
+	order uint64
+	// message buffer size for RESIZE
+	size int
+	// component for REGISTERANDROUTE
+	c Component
+	// routing table namespace for ADDROUTE and REMOVEROUTE
+	namespace string
+	// route weight for SETWEIGHT
+	weight int
+	// topology for INSTALLTABLE
+	snap RouterSnapshot
+	// scheduling fields for SCHEDULE and CANCELSCHEDULED
+	msg        msgMsg
+	delay      time.Duration
+	scheduleID ScheduleID
 }
 
 type msgReg struct {
-	c  Component
-	op int
+	c     Component
+	op    int
+	id    ComponentID
+	errc  chan error
+	reply chan interface{}
+	// visit is called for each component by ForEachComponent
+	visit func(ComponentID, Component) bool
 }
 
 // NewGenericRouter is a constructor for a generic implementation of a Router
 // Channels should be buffered so that sending go routines do not block while
 // blocking operations occur on router
-func NewGenericRouter(bufferSize int) *GenericRouter {
+func NewGenericRouter(bufferSize int, opts ...Option) *GenericRouter {
 
 	// make channels
 	msgChan := make(chan msgMsg, bufferSize)
@@ -90,8 +451,8 @@ func NewGenericRouter(bufferSize int) *GenericRouter {
 	// create routing table
 	rt := routingTable{}
 
-	// create registeredComponents map
-	rc := make(map[ComponentID]Component)
+	// create registeredComponents registry, replaced by WithRegistry
+	rc := NewRegistry()
 
 	// construct router - same channel is used for each type but struct
 	// defines unidirectionality of channel.
@@ -102,72 +463,802 @@ func NewGenericRouter(bufferSize int) *GenericRouter {
 		internalRtChan:  rtChan,
 		externalRegChan: cmpChan,
 		internalRegChan: cmpChan,
+		priorityChan:    make(chan msgMsg, bufferSize),
 		rt:              rt,
 		rc:              rc,
+		seq:             make(map[ComponentID]uint64),
+		taps:            make(map[ComponentID][]Component),
+		retry:           DefaultRetryPolicy,
+		scheduleIndex:   make(map[ScheduleID]*scheduled),
+		counters:        new(counters),
+		latency:         newLatencyCounters(),
+		rateWindow:      DefaultRateWindow,
+		clock:           realClock{},
+		modes:           make(map[ComponentID]Mode),
+		rules:           make(map[ComponentID][]rule),
+		inflight:        make(map[ComponentID]*int64),
+		rrIndex:         make(map[ComponentID]int),
+		closedSources:   make(map[ComponentID]bool),
+		hasher:          fnvHash,
+		virtual:         make(map[ComponentID][]virtualRoute),
+		routeCounters:   make(map[RouteKey]*uint64),
+		idGen:           newUUID,
+		matchDest:       matchID,
+		health:          make(map[ComponentID]*sourceHealth),
+		breakers:        make(map[ComponentID]*breaker),
+		pending:         make(map[ComponentID]*pendingDest),
+		namespaces:      make(map[string]routingTable),
+		replay:          make(map[ComponentID]*replayBuffer),
+		priorities:      make(map[ComponentID]int),
+		flushEpoch:      new(sync.WaitGroup),
+		done:            make(chan struct{}),
+		exited:          make(chan struct{}),
+	}
+
+	// apply options
+	for _, opt := range opts {
+		opt(r)
 	}
 
 	return r
 }
 
+// initialized reports whether the router was constructed by NewGenericRouter.
+// A zero value GenericRouter has nil channels and maps.
+func (r *GenericRouter) initialized() bool {
+	return r != nil && r.rt != nil
+}
+
 // Consume is meant to be ran as a go routine. Consume will listen on all
 // internal message channels and run the appropriate function handler based on the
 // message received.
 func (r *GenericRouter) Consume() {
 
-	select {
-	case m := <-r.internalMsgChan:
-		go r.send(m)
-	case m := <-r.internalRtChan:
-		switch {
-		case m.op == ADDROUTE:
-			r.addRoute(m)
-		case m.op == REMOVEROUTE:
-			r.removeRoute(m)
-		case m.op == LISTROUTES:
-			fmt.Println()
+	// A zero value router has nil channels which would block forever
+	if !r.initialized() {
+		return
+	}
+
+	// A stopped router must be restarted with Restart
+	r.lifeMu.Lock()
+	if isClosed(r.done) {
+		r.lifeMu.Unlock()
+		return
+	}
+	r.consuming = true
+	r.lifeMu.Unlock()
+	defer func() {
+		r.lifeMu.Lock()
+		r.consuming = false
+		r.lifeMu.Unlock()
+		close(r.exited)
+	}()
+
+	// Sample counters across the rate window for Stats
+	r.rates = append(r.rates[:0], r.sample())
+	rateTimer := r.clock.NewTimer(r.rateWindow / rateSamples)
+	defer rateTimer.Stop()
+
+	// Markers may have been evicted while the router was stopped
+	r.handleEvicted()
+
+	for {
+		// Prioritised messages go ahead of everything else
+		select {
+		case m := <-r.priorityChan:
+			r.receiveMsg(m)
+			continue
+		default:
 		}
-	case m := <-r.internalRegChan:
-		switch {
-		case m.op == UNREGISTER:
-			r.unregisterComponent(m)
-		case m.op == REGISTER:
-			r.registerComponent(m)
+
+		select {
+		case m := <-r.priorityChan:
+			r.receiveMsg(m)
+		case m := <-r.internalMsgChan:
+			r.receiveMsg(m)
+		case m := <-r.internalRtChan:
+			observeDepth(&r.highWater.RouteOps, len(r.internalRtChan)+1)
+			switch {
+			case m.op == ADDROUTE:
+				r.replyAddRoute(m)
+			case m.op == REMOVEROUTE:
+				m.errc <- r.removeRoute(m)
+			case m.op == LISTROUTESLINES:
+				r.listRoutesLines(m)
+			case m.op == LISTROUTES:
+				r.listRoutes(m)
+			case m.op == LISTROUTESBYLABEL:
+				r.listRoutesByLabel(m)
+			case m.op == ADDTAP:
+				m.errc <- r.addTap(m)
+			case m.op == REMOVETAP:
+				m.errc <- r.removeTap(m)
+			case m.op == EXPORTDOT:
+				r.exportDOT(m)
+			case m.op == SNAPSHOT:
+				r.snapshot(m)
+			case m.op == INSTALLTABLE:
+				m.errc <- r.installTable(m)
+			case m.op == SCHEDULE:
+				r.schedule(m)
+			case m.op == CANCELSCHEDULED:
+				m.errc <- r.cancelScheduled(m)
+			case m.op == GETROUTES:
+				r.getRoutes(m)
+			case m.op == ROUTESTO:
+				r.routesTo(m)
+			case m.op == MOVEROUTES:
+				m.errc <- r.moveRoutes(m)
+			case m.op == STATS:
+				r.stats(m)
+			case m.op == SETMODE:
+				m.errc <- r.setDeliveryMode(m)
+			case m.op == GETMODE:
+				r.getDeliveryMode(m)
+			case m.op == ROUTESTATS:
+				r.routeStats(m)
+			case m.op == ROUTECOUNT:
+				r.routeCount(m)
+			case m.op == DISABLEROUTE:
+				m.errc <- r.setRouteDisabled(m, true)
+			case m.op == ENABLEROUTE:
+				m.errc <- r.setRouteDisabled(m, false)
+			case m.op == SETWEIGHT:
+				m.errc <- r.setRouteWeight(m)
+			case m.op == TAGROUTE:
+				m.errc <- r.tagRoute(m)
+			case m.op == ENABLETAG:
+				m.errc <- r.setTagDisabled(m, false)
+			case m.op == DISABLETAG:
+				m.errc <- r.setTagDisabled(m, true)
+			case m.op == COMPACT:
+				r.compact(m)
+			case m.op == RESIZE:
+				r.resize(m)
+			case m.op == RELEASE:
+				m.errc <- r.release(m)
+			case m.op == RESETHIGHWATER:
+				r.resetHighWater(m)
+			case m.op == APPLY:
+				m.errc <- r.apply(m)
+			case m.op == REGISTERANDROUTE:
+				r.registerAndRoute(m)
+			case m.op == ADDRULE:
+				m.errc <- r.addRule(m)
+			case m.op == CLEARRULES:
+				m.errc <- r.clearRules(m)
+			case m.op == STOP:
+				r.stop(m)
+				return
+			default:
+				r.invalidOp(m.op, m.errc)
+			}
+		case m := <-r.internalRegChan:
+			observeDepth(&r.highWater.Registrations, len(r.internalRegChan)+1)
+			switch {
+			case m.op == UNREGISTER:
+				m.errc <- r.unregisterComponent(m)
+			case m.op == REGISTER:
+				m.errc <- r.registerComponent(m)
+			case m.op == LISTCOMPONENTS:
+				r.listComponents(m)
+			case m.op == REGISTERWITHID:
+				m.errc <- r.registerWithID(m)
+			case m.op == FOREACHCOMPONENT:
+				r.forEachComponent(m)
+			default:
+				r.invalidOp(m.op, nil)
+			}
+		case <-r.scheduleC():
+			r.fireScheduled()
+		case <-r.expiryC():
+			r.expireRoutes()
+		case <-rateTimer.C():
+			r.sampleRates()
+			rateTimer.Reset(r.rateWindow / rateSamples)
 		}
 	}
 
 }
 
 // Send is a wrapper for external usage. Wrapping a send to the
-// external message channel of our router.
+// external message channel of our router. When the buffer is full Send waits
+// up to the router's send timeout for space, after which the router's
+// OverflowPolicy decides whether this message or the oldest buffered message
+// is dropped.
 func (r *GenericRouter) Send(m msgMsg) error {
 
+	if !r.initialized() {
+		return opError("Send", m.src, ZeroComponentID, ErrNotInitialized)
+	}
+
+	return opError("Send", m.src, ZeroComponentID, r.enqueue(m))
+
+}
+
+// receiveMsg handles m, just taken off the message channel, after the
+// markers evicted from ahead of it.
+func (r *GenericRouter) receiveMsg(m msgMsg) {
+	r.handleEvicted()
+	r.handleMsg(m)
+}
+
+// handleEvicted handles the markers DropOldest eviction took off the message
+// channel. They were ahead of every message still buffered.
+func (r *GenericRouter) handleEvicted() {
+
+	// Eviction holds evictMu from taking a marker until it is stored, so a
+	// message received after the marker was taken finds it here
+	r.evictMu.Lock()
+	markers := r.evicted
+	r.evicted = nil
+	r.evictMu.Unlock()
+
+	for _, m := range markers {
+		r.handleMsg(m)
+	}
+
+}
+
+// handleMsg routes a message taken off the message channel, or handles it if
+// it is a control marker.
+func (r *GenericRouter) handleMsg(m msgMsg) {
+
+	observeDepth(&r.highWater.Messages, len(r.internalMsgChan)+1)
+	if m.marker != 0 {
+		// Prioritised messages sent before the marker must be handled first
+		r.drainPriority()
+		r.handleMarker(m)
+		return
+	}
+	if r.onQueueWait != nil {
+		r.onQueueWait(r.clock.Now().Sub(m.enqueued))
+	}
+	r.send(m)
+
+}
+
+// enqueue puts a message on the message channel, applying the router's
+// admission checks and overflow policy.
+func (r *GenericRouter) enqueue(m msgMsg) error {
+
+	// Keep Resize from swapping the channel while we use it
+	r.msgMu.RLock()
+	defer r.msgMu.RUnlock()
+
+	r.boostPriority(&m)
+	if r.shed(m) {
+		r.countDropped(Shed, payloadCount(m))
+		return ErrShed
+	}
+
+	if err := r.checkPayloadSize(m); err != nil {
+		r.countDropped(PayloadTooLarge, payloadCount(m))
+		return err
+	}
+
+	m.enqueued = r.clock.Now()
+
+	// A message with a priority skips the buffered backlog while its lane has
+	// room, and otherwise queues with the rest
+	if m.priority > 0 {
+		select {
+		case r.priorityChan <- m:
+			return nil
+		default:
+		}
+	}
+
 	select {
 	case r.externalMsgChan <- m:
 		return nil
 	default:
-		return errors.New("Could not send message to router")
 	}
 
+	// Wait for buffer space if configured with WithSendTimeout
+	if r.sendTimeout > 0 {
+		timer := r.clock.NewTimer(r.sendTimeout)
+		select {
+		case r.externalMsgChan <- m:
+			timer.Stop()
+			return nil
+		case <-timer.C():
+		}
+	}
+
+	// Unbuffered channels have nothing to evict
+	if r.overflowPolicy != DropOldest || cap(r.externalMsgChan) == 0 {
+		r.countDropped(BufferFull, payloadCount(m))
+		return ErrBufferFull
+	}
+
+	// Evict the oldest message and retry until our message fits. The consume
+	// loop may drain the buffer between attempts, which is fine.
+	for {
+		if r.evictOldest() {
+			continue
+		}
+
+		select {
+		case r.externalMsgChan <- m:
+			return nil
+		default:
+		}
+	}
+
+}
+
+// evictOldest takes the oldest entry off the message channel. A message is
+// dropped; a marker must neither be lost nor overtaken by the messages behind
+// it, so it is handed to the consume loop to be handled before them, and
+// evictOldest reports true to go on evicting.
+func (r *GenericRouter) evictOldest() bool {
+
+	r.evictMu.Lock()
+	defer r.evictMu.Unlock()
+
+	select {
+	case old := <-r.internalMsgChan:
+		if old.marker != 0 {
+			r.evicted = append(r.evicted, old)
+			return true
+		}
+		r.countDropped(BufferFull, payloadCount(old))
+		notifyDropped(old, ErrBufferFull)
+	default:
+	}
+	return false
+
 }
 
+// invalidOp reports an unknown op code to the caller's error channel, if the
+// operation carried one, and to the router's error channel.
+func (r *GenericRouter) invalidOp(op int, errc chan error) {
+
+	err := fmt.Errorf("%w: %d", ErrInvalidOp, op)
+
+	if errc != nil {
+		errc <- err
+	}
+
+	r.reportError(err)
+
+}
+
+// reportError publishes an error which has no caller to return to onto the
+// router's error channel. Nil errors are ignored.
+func (r *GenericRouter) reportError(err error) {
+
+	if err == nil || r.errs == nil {
+		return
+	}
+
+	select {
+	case r.errs <- err:
+	default:
+	}
+
+}
+
+// SendBatch enqueues a slice of payloads from a single source as one message.
+// The consume loop resolves the source's routes once and every payload is
+// delivered, in order, to each destination.
+func (r *GenericRouter) SendBatch(src ComponentID, payloads []interface{}) error {
+
+	if len(payloads) == 0 {
+		return nil
+	}
+
+	return r.Send(msgMsg{src: src, batch: payloads})
+
+}
+
+// send runs inside the consume loop. It resolves the routes for the message's
+// source and hands a snapshot of them to a delivery go routine, so delivery
+// never reads the routing table concurrently with updates.
 func (r *GenericRouter) send(m msgMsg) {
 
 	// Confirm src in msgMsg is in component array
-	if _, ok := r.rc[m.src]; !ok {
+	if !r.rc.has(m.src) {
+		r.dropPayloads(m, UnregisteredSource, ErrNotRegistered)
+		notifyDropped(m, ErrNotRegistered)
 		return
 	}
 
-	// Obtain routes
-	routesArray, ok := r.rt[m.src]
-	if !ok {
+	// Drop messages from sources closed by DrainSource
+	if r.closedSources[m.src] {
+		r.dropPayloads(m, SourceClosed, ErrSourceClosed)
+		notifyDropped(m, ErrSourceClosed)
+		return
+	}
+
+	// Dead letter messages from quarantined sources
+	health := r.sourceHealth(m.src)
+	if health != nil && atomic.LoadInt32(&health.quarantined) == 1 {
+		r.dropPayloads(m, Quarantined, ErrQuarantined)
+		notifyDropped(m, ErrQuarantined)
+		return
+	}
+
+	// Drop messages whose context ended while they were buffered
+	if m.ctx != nil && m.ctx.Err() != nil {
+		r.dropPayloads(m, Expired, m.ctx.Err())
+		notifyDropped(m, m.ctx.Err())
+		return
+	}
+
+	// Deliver to the message's explicit destinations instead of its routes
+	if m.dests != nil {
+		r.sendExplicit(m, health)
+		return
+	}
+
+	// Skip the snapshot for a lone destination when delivering inline
+	if r.inlineDelivery && r.sendSingle(m, health) {
 		return
 	}
 
-	// Send payload to each route.
-	for _, comp := range routesArray {
-		comp.Send(m.payload)
+	// Obtain routes, virtual routes, taps and rules
+	routesArray := r.routesFor(m)
+	virtual := r.virtual[m.src]
+	taps := r.taps[m.src]
+	rules := r.rules[m.src]
+	wildcard := r.wildcardRoutes(m.src)
+	pending := m.routedBy(DefaultNamespace) && r.pendingFor(m)
+	if len(routesArray) == 0 && len(virtual) == 0 && len(taps) == 0 && len(rules) == 0 && len(wildcard) == 0 && !pending {
+		// Topics keep their messages for later subscribers
+		if r.replay[m.src] != nil {
+			r.retain(m.src, r.stampPayloads(m))
+			notifyDropped(m, nil)
+			return
+		}
+		r.dropPayloads(m, NoRoute, ErrNoRoute)
+		notifyDropped(m, ErrNoRoute)
+		return
+	}
+
+	// Snapshot enabled routes so later table updates don't race with delivery
+	routes := make([]route, 0, len(routesArray))
+	var skipped []DeliveryResult
+	for _, rte := range routesArray {
+		if rte.disabled {
+			if m.detail != nil {
+				dest, _ := rte.dest.GetID()
+				skipped = append(skipped, DeliveryResult{Dest: dest, Skipped: "route disabled"})
+			}
+			continue
+		}
+		routes = append(routes, *rte)
+	}
+	routes = append(routes, r.resolveRoutes(m.src)...)
+
+	// Copy taps and rules for the same reason
+	d := delivery{
+		src:    m.src,
+		mode:   r.modes[m.src],
+		routes: routes,
+		taps:   append([]Component(nil), taps...),
+		rules:  append([]rule(nil), rules...),
+		// wildcard routes are already copies
+		wildcard: wildcard,
+		// results for SendDetailed
+		detail:  m.detail,
+		skipped: skipped,
+		done:    m.done,
+		health:  health,
+	}
+
+	// Leave out the destinations SendWhere's predicate doesn't select
+	if !m.narrow(&d) && !pending {
+		r.dropPayloads(m, NoRoute, ErrNoRoute)
+		notifyDropped(m, ErrNoRoute)
+		return
+	}
+
+	msgs := r.stampPayloads(m)
+	r.retain(m.src, msgs)
+
+	// Hold copies for destinations which haven't registered yet
+	if pending {
+		r.park(m, msgs)
+	}
+
+	// Advance the round robin position past this delivery's messages
+	if d.mode == RoundRobin && len(routes) > 0 {
+		d.rrStart = r.rrIndex[m.src]
+		r.rrIndex[m.src] = (d.rrStart + len(msgs)) % len(routes)
 	}
 
+	r.dispatch(d, msgs)
+
+}
+
+// dispatch hands a delivery to its go routine, or makes it in the consume
+// loop when delivering inline.
+func (r *GenericRouter) dispatch(d delivery, msgs []msgMsg) {
+
+	if r.inlineDelivery {
+		r.fanout(d, msgs)
+		return
+	}
+	// Queueing is quick, so the consume loop does it itself
+	if r.queues != nil {
+		d.queued = true
+		r.fanout(d, msgs)
+		return
+	}
+	finish := r.startDelivery()
+	if r.ordered != nil {
+		r.orderedFanout(d, msgs, finish)
+		return
+	}
+	go func() {
+		defer finish()
+		r.fanout(d, msgs)
+	}()
+
+}
+
+// stampPayloads splits m into a message per payload, each stamped with the
+// source's next sequence number.
+func (r *GenericRouter) stampPayloads(m msgMsg) []msgMsg {
+
+	payloads := m.batch
+	if payloads == nil {
+		payloads = []interface{}{m.payload}
+	}
+
+	msgs := make([]msgMsg, len(payloads))
+	for i, payload := range payloads {
+		msgs[i] = r.stampSeq(m, payload)
+	}
+	return msgs
+
+}
+
+// sendSingle delivers m straight to its source's only route, without the
+// allocations of a delivery snapshot, if nothing else would receive it. It
+// reports false, doing nothing, otherwise. It is only used with inline
+// delivery, where no snapshot has to outlive the consume loop.
+func (r *GenericRouter) sendSingle(m msgMsg, health *sourceHealth) bool {
+
+	routes := r.table(m.namespace)[m.src]
+	if len(routes) != 1 || routes[0].disabled || m.batch != nil || m.detail != nil || m.dests != nil || m.where != nil || m.namespaces != nil {
+		return false
+	}
+	if r.modes[m.src] == Keyed {
+		return false
+	}
+	if len(r.virtual[m.src]) > 0 || len(r.taps[m.src]) > 0 || len(r.rules[m.src]) > 0 {
+		return false
+	}
+	if len(r.rt[WildcardSource]) > 0 || r.hasPending(m.src) || r.replay[m.src] != nil {
+		return false
+	}
+
+	d := delivery{src: m.src, done: m.done, health: health}
+	rec := newDetailRecorder(d)
+	r.deliverRoute(&d, *routes[0], r.stampSeq(m, m.payload), rec)
+	rec.finish()
+	return true
+
+}
+
+// stampSeq returns a copy of m carrying payload, stamped with the source's
+// next sequence number.
+func (r *GenericRouter) stampSeq(m msgMsg, payload interface{}) msgMsg {
+
+	headers := copyStringMap(m.headers)
+	if headers == nil {
+		headers = make(map[string]string, 1)
+	}
+	headers[SeqHeader] = strconv.FormatUint(r.seq[m.src], 10)
+	r.seq[m.src]++
+	return msgMsg{src: m.src, payload: payload, headers: headers, enqueued: m.enqueued, ctx: m.ctx, priority: m.priority}
+
+}
+
+// fanout sends each message to the routes selected by the source's delivery
+// mode and to the destinations of matching rules, in order, reporting
+// failures. It then mirrors the message to the source's taps.
+func (r *GenericRouter) fanout(d delivery, msgs []msgMsg) {
+
+	rec := newDetailRecorder(d)
+	rec.hold()
+	defer rec.release()
+
+	for i, m := range msgs {
+		if m.ctx != nil && m.ctx.Err() != nil {
+			r.dropMessage(d.src, m.payload, Expired, m.ctx.Err())
+			rec.fail(m.ctx.Err())
+			continue
+		}
+		selected, err := r.selectRoutes(d, i, m)
+		if err != nil {
+			r.dropMessage(d.src, m.payload, NoRoute, err)
+			rec.fail(err)
+		}
+		rec.unselected(d.routes, selected)
+		if d.queued {
+			r.fanoutQueued(&d, selected, m, rec)
+			continue
+		}
+		if d.mode == ParallelFanout {
+			r.deliverParallel(&d, selected, m, rec)
+		} else {
+			for _, rte := range selected {
+				r.deliverRoute(&d, rte, m, rec)
+			}
+		}
+		for _, rl := range matchRules(d.rules, m.payload) {
+			r.deliverRule(&d, rl, m, rec)
+		}
+		for _, rte := range d.wildcard {
+			r.deliverRoute(&d, rte, m, rec)
+		}
+		for _, observer := range d.taps {
+			r.deliverTap(observer, r.clonePayload(m))
+		}
+	}
+
+}
+
+// deliverRoute delivers a message over a single route, or hands it to the
+// route's coalescer.
+func (r *GenericRouter) deliverRoute(d *delivery, rte route, m msgMsg, rec *detailRecorder) {
+
+	if !r.accepted(d.src, rte.dest, m, rec) {
+		return
+	}
+	m = r.clonePayload(m)
+	if rte.coalescer != nil {
+		rte.coalescer.add(m.payload)
+		rec.skip(rte.dest, "coalesced")
+		return
+	}
+	if !r.breakerAllows(d.src, rte.dest, m, rec) {
+		return
+	}
+	atomic.AddInt64(rte.inflight, 1)
+	err := r.deliverTo(d.src, rte.dest, rte.delivered, m)
+	atomic.AddInt64(rte.inflight, -1)
+	rec.record(rte.dest, err)
+	r.trackBreaker(rte.dest, err)
+	r.trackHealth(d, err)
+
+}
+
+// deliverRule delivers m to the destination of a matching rule.
+func (r *GenericRouter) deliverRule(d *delivery, rl rule, m msgMsg, rec *detailRecorder) {
+
+	if !r.accepted(d.src, rl.dest, m, rec) || !r.breakerAllows(d.src, rl.dest, m, rec) {
+		return
+	}
+	err := r.deliverTo(d.src, rl.dest, rl.delivered, r.clonePayload(m))
+	rec.record(rl.dest, err)
+	r.trackBreaker(rl.dest, err)
+	r.trackHealth(d, err)
+
+}
+
+// deliverParallel delivers a message over every route concurrently and
+// returns once all deliveries have. Results are recorded in route order.
+func (r *GenericRouter) deliverParallel(d *delivery, routes []route, m msgMsg, rec *detailRecorder) {
+
+	recs := make([]*detailRecorder, len(routes))
+	var wg sync.WaitGroup
+	for i, rte := range routes {
+		if rec != nil {
+			recs[i] = &detailRecorder{}
+		}
+		wg.Add(1)
+		go func(rte route, sub *detailRecorder) {
+			defer wg.Done()
+			r.deliverRoute(d, rte, m, sub)
+		}(rte, recs[i])
+	}
+	wg.Wait()
+
+	for _, sub := range recs {
+		rec.merge(sub)
+	}
+
+}
+
+// deliverTo delivers a message to a single destination and records the
+// outcome, including on the edge's delivery counter. It returns the delivery
+// error, which has already been reported.
+func (r *GenericRouter) deliverTo(src ComponentID, dest Component, delivered *uint64, m msgMsg) error {
+
+	if err := r.deliverWithRetry(dest, m); err != nil {
+		r.sendError(src, dest, m.payload, err)
+		return err
+	}
+	r.recordDelivery(src, dest, m)
+	atomic.AddUint64(delivered, 1)
+	return nil
+
+}
+
+// deliver sends a message to a destination, bounded by the delivery timeout
+// if one is configured.
+func (r *GenericRouter) deliver(comp Component, m msgMsg) error {
+
+	if r.deliveryTimeout <= 0 {
+		return r.deliverOnce(comp, m)
+	}
+
+	// Buffered so an abandoned Send can still complete and exit
+	done := make(chan error, 1)
+	go func() {
+		done <- r.deliverOnce(comp, m)
+	}()
+
+	timer := r.clock.NewTimer(r.deliveryTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C():
+		return ErrSendTimeout
+	}
+}
+
+// deliverOnce calls the component's Send, SendAck for an AckingComponent,
+// SendCtx for a CtxComponent given a message sent with a context,
+// SendMessage for a MessageComponent or SendWithHeaders for a
+// HeaderComponent. If the router was configured with
+// WithRecoverSends a panic inside Send is recovered and returned as an error.
+func (r *GenericRouter) deliverOnce(comp Component, m msgMsg) (err error) {
+
+	if r.recoverSends {
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("Component panicked during Send: %v", p)
+			}
+		}()
+	}
+
+	if ac, ok := comp.(AckingComponent); ok {
+		return r.deliverAck(ac, m.payload)
+	}
+
+	if cc, ok := comp.(CtxComponent); ok && m.ctx != nil {
+		return cc.SendCtx(m.ctx, m.payload)
+	}
+
+	if mc, ok := comp.(MessageComponent); ok {
+		return mc.SendMessage(m.message())
+	}
+
+	if hc, ok := comp.(HeaderComponent); ok {
+		return hc.SendWithHeaders(m.payload, copyStringMap(m.headers))
+	}
+
+	return comp.Send(m.payload)
+}
+
+// sendError reports a failed delivery to the OnSendError callback and the
+// dead letter queue if they are configured.
+func (r *GenericRouter) sendError(src ComponentID, comp Component, payload interface{}, err error) {
+
+	r.countDropped(SendError, 1)
+
+	dest, _ := comp.GetID()
+
+	if r.onSendError != nil {
+		r.onSendError(src, dest, payload, err)
+	}
+
+	r.deadLetter(DeadLetter{
+		Src:     src,
+		Dest:    dest,
+		Payload: payload,
+		Err:     err,
+		Reason:  SendError,
+		Time:    r.clock.Now(),
+	})
 }
 
 // // internal send method for routing messages to correct destinations
@@ -183,7 +1274,7 @@ func (r *GenericRouter) send(m msgMsg) {
 // func (r *GenericRouter) register(c Component) (ComponentID, error) {
 // 	uuid, err := newUUID()
 // 	if err != nil {
-// 		return ComponentID(""), errors.New("Could not generate UUID")
+// 		return ZeroComponentID, errors.New("Could not generate UUID")
 // 	}
 // 	c.SetID(uuid)
 // 	r.rc[uuid] = c
@@ -191,51 +1282,113 @@ func (r *GenericRouter) send(m msgMsg) {
 // }
 
 // RegisterComponent is a wrapper for external usage. Wrapping a send to the
-// external registration channel of our router.
-func (r *GenericRouter) RegisterComponent(m msgReg) {
+// external registration channel of our router. It waits for the registration
+// and returns its error, e.g. when the component rejects its ID in SetID.
+func (r *GenericRouter) RegisterComponent(m msgReg) error {
+	if !r.initialized() {
+		return opError("RegisterComponent", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
 	// Tag on operation constant
 	m.op = REGISTER
-	// Send msgReg to external msgChan
-	r.externalRegChan <- m
+	return opError("RegisterComponent", ZeroComponentID, ZeroComponentID, r.execReg(m))
 
 }
 
-func (r GenericRouter) registerComponent(m msgReg) error {
+func (r *GenericRouter) registerComponent(m msgReg) error {
 	// Check to see if component already has ID
 	id, err := m.c.GetID()
-	if err == nil {
+	if err == nil && !id.IsZero() {
 
 		// If component ID found, do lookup of ID in rc table.
-		if comp, ok := r.rc[id]; ok {
+		if comp, ok := r.rc.get(id); ok {
 
 			// Lookup of id succeeded, and component being registered matches lookup,
 			// return hash, already registered.
-			if comp == m.c {
+			if sameComponent(comp, m.c) {
 				return nil
 			}
 
 		}
 	}
 
+	if r.registerGuard != nil {
+		if err := r.registerGuard(m.c); err != nil {
+			return err
+		}
+	}
+
 	// This is a fallthrough. Didn't come in with ID or came in with ID but component
 	// didn't match. Register and setID on component.
-	uuid, err := newUUID()
+	uuid, err := r.generateID()
 	if err != nil {
 		return errors.New("Could not generate UUID")
 	}
-	m.c.SetID(uuid)
-	r.rc[uuid] = m.c
+	// Only store components which accepted their ID
+	if err := m.c.SetID(uuid); err != nil {
+		return err
+	}
+	if !r.rc.add(uuid, m.c) {
+		return ErrAlreadyRegistered
+	}
+	r.notifyRegister(uuid, m.c)
+	return nil
+
+}
+
+// RegisterWithID registers a component under an externally supplied ID, e.g.
+// a stable ID from another system, so routes can be rebuilt with the same IDs
+// across restarts. It fails with ErrAlreadyRegistered if the ID is taken.
+func (r *GenericRouter) RegisterWithID(id ComponentID, c Component) error {
+	if !r.initialized() {
+		return opError("RegisterWithID", id, ZeroComponentID, ErrNotInitialized)
+	}
+	if id.IsZero() {
+		return opError("RegisterWithID", id, ZeroComponentID, errors.New("Component ID must not be empty"))
+	}
+	if id == WildcardSource {
+		return opError("RegisterWithID", id, ZeroComponentID, errors.New("Component ID is reserved"))
+	}
+
+	return opError("RegisterWithID", id, ZeroComponentID, r.execReg(msgReg{op: REGISTERWITHID, id: id, c: c}))
+}
+
+// registerWithID stores the component under the supplied ID.
+func (r *GenericRouter) registerWithID(m msgReg) error {
+
+	if r.rc.has(m.id) {
+		return ErrAlreadyRegistered
+	}
+
+	if r.registerGuard != nil {
+		if err := r.registerGuard(m.c); err != nil {
+			return err
+		}
+	}
+
+	if err := m.c.SetID(m.id); err != nil {
+		return err
+	}
+	if !r.rc.add(m.id, m.c) {
+		return ErrAlreadyRegistered
+	}
+	r.notifyRegister(m.id, m.c)
+	r.flushPending(m.id)
 	return nil
 
 }
 
 // UnregisterComponent is a wrapper for external usage. Wrapping a send to the
-// external unregistration channel of our router.
-func (r *GenericRouter) UnregisterComponent(m msgReg) {
+// external unregistration channel of our router. It waits for the
+// unregistration and returns its error, e.g. when the router's unregister
+// guard vetoes it.
+func (r *GenericRouter) UnregisterComponent(m msgReg) error {
+	if !r.initialized() {
+		return opError("UnregisterComponent", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
 	// Tag on operation constant
 	m.op = UNREGISTER
-	// send msgReg to external msgChan
-	r.externalRegChan <- m
+	id, _ := m.c.GetID()
+	return opError("UnregisterComponent", id, ZeroComponentID, r.execReg(m))
 }
 
 // unregisterComponent searches the registeredComponent table for the hash
@@ -243,82 +1396,358 @@ func (r *GenericRouter) UnregisterComponent(m msgReg) {
 // This does not stop routing of registered components. Removing the route
 // is necessary. TODO: Block removal of component if route exists for the
 // component.
-func (r GenericRouter) unregisterComponent(m msgReg) error {
+func (r *GenericRouter) unregisterComponent(m msgReg) error {
 	// Check to see if component has ID
 	id, err := m.c.GetID()
-	if err == nil {
-		// If component has hash, look up hash in rc. If lookup succeeds, delete
-		// the map entry
-		if _, ok := r.rc[id]; ok {
-			delete(r.rc, id)
-			return nil
+	if err != nil || !r.rc.has(id) {
+		return ErrNotRegistered
+	}
+
+	if r.unregisterGuard != nil {
+		if err := r.unregisterGuard(m.c); err != nil {
+			return err
 		}
+	}
+
+	// If component has hash, look up hash in rc. If lookup succeeds, delete
+	// the map entry
+	if r.rc.remove(id) {
+		delete(r.closedSources, id)
+		r.notifyUnregister(id)
+		return nil
+	}
+	return ErrNotRegistered
+}
 
+// ListComponents returns the IDs of all registered components, sorted.
+func (r *GenericRouter) ListComponents() ([]ComponentID, error) {
+	if !r.initialized() {
+		return nil, opError("ListComponents", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
 	}
-	return errors.New("Component not registered")
+
+	v, err := r.queryReg(msgReg{op: LISTCOMPONENTS})
+	if err != nil {
+		return nil, opError("ListComponents", ZeroComponentID, ZeroComponentID, err)
+	}
+	return v.([]ComponentID), nil
+}
+
+// listComponents answers a ListComponents query from the consume loop.
+func (r *GenericRouter) listComponents(m msgReg) {
+	m.reply <- sortedIDs(r.rc.snapshot())
+}
+
+// ForEachComponent calls fn for every registered component, in no particular
+// order, until fn returns false. fn runs inside the consume loop, so it sees
+// a consistent registry without it being copied, and the router is blocked
+// until it returns. fn must not call back into the router: operations waiting
+// on the consume loop deadlock, and registering or unregistering deadlocks on
+// the registry.
+func (r *GenericRouter) ForEachComponent(fn func(id ComponentID, c Component) bool) error {
+	if !r.initialized() {
+		return opError("ForEachComponent", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+
+	return opError("ForEachComponent", ZeroComponentID, ZeroComponentID, r.execReg(msgReg{op: FOREACHCOMPONENT, visit: fn}))
+}
+
+// forEachComponent visits the registered components for ForEachComponent.
+func (r *GenericRouter) forEachComponent(m msgReg) {
+	r.rc.each(m.visit)
+	m.errc <- nil
 }
 
 // AddRoute is a wrapper for external usage. Wrapping a send to the
-// external route channel of our router.
-func (r *GenericRouter) AddRoute(m msgRt) {
+// external route channel of our router. added reports whether a new route
+// was added; it is false if the route already existed and was only renewed.
+func (r *GenericRouter) AddRoute(m msgRt) (added bool, err error) {
+	if !r.initialized() {
+		return false, opError("AddRoute", m.src, m.dest, ErrNotInitialized)
+	}
 	// Tag on operation constant
 	m.op = ADDROUTE
 	// send msgRt to external msgChan
-	r.externalRtChan <- m
+	v, err := r.query(m)
+	if err != nil {
+		return false, opError("AddRoute", m.src, m.dest, err)
+	}
+	return v.(bool), nil
+}
+
+// replyAddRoute adds a route for AddRoute, replying whether it was new.
+func (r *GenericRouter) replyAddRoute(m msgRt) {
+	added, err := r.addRoute(m)
+	if err != nil {
+		m.reply <- err
+		return
+	}
+	m.reply <- added
 }
 
 // addRoute adds a component to an array of components. This array is hashed
 // on the componetID, associating a component with it's routes. Only components
-// registered by RegisterComponent are applicable for routes.
-func (r *GenericRouter) addRoute(m msgRt) {
+// registered by RegisterComponent are applicable for routes. Re-adding an
+// existing route renews its TTL instead of adding a duplicate, and reports
+// false. Routes added with a coalescing window or max deliver payloads to the
+// destination in batches as a []interface{}, so the destination must handle
+// slice payloads.
+// A route carrying a resolver targets a virtual destination named by dest,
+// whose concrete destinations are resolved on every delivery. Routes are
+// delivered to in insertion order. A route added with an explicit order, e.g.
+// one recorded in RouteInfo.Order, takes that place among its source's routes
+// so a table rebuilt in any order fans out as the original did.
+func (r *GenericRouter) addRoute(m msgRt) (bool, error) {
+
+	// Routes to a virtual destination are stored apart from the table
+	if m.resolver != nil {
+		return r.addVirtualRoute(m)
+	}
 
 	// Confirm source is in registered components array
-	if _, ok := r.rc[m.src]; !ok {
-		return
+	if !r.routableSource(m.src) {
+		return false, ErrNotRegistered
 	}
-	if _, ok := r.rc[m.dest]; !ok {
-		return
+	destComp, ok := r.rc.get(m.dest)
+	if !ok {
+		// Hold the route until dest registers, if enabled
+		if r.pendingMax > 0 && m.namespace == DefaultNamespace {
+			return r.addPendingRoute(m), nil
+		}
+		return false, ErrNotRegistered
+	}
+
+	var expires time.Time
+	if m.ttl > 0 {
+		expires = r.clock.Now().Add(m.ttl)
 	}
 
-	srcArray := r.rt[m.src]
+	// Renew an existing route
+	rt := r.tableFor(m.namespace)
+	for _, rte := range rt[m.src] {
+		if r.matchDest(rte.dest, m.dest) {
+			rte.dest = destComp
+			rte.expires = expires
+			if m.labels != nil {
+				rte.labels = copyStringMap(m.labels)
+			}
+			r.resetExpiryTimer()
+			return false, nil
+		}
+	}
 
 	// Add destination component into source component's array. Lookup component
 	// in registered component array
-	srcArray = append(srcArray, r.rc[m.dest])
+	rte := &route{
+		dest:      destComp,
+		labels:    copyStringMap(m.labels),
+		expires:   expires,
+		inflight:  r.inflightCounter(m.dest),
+		delivered: r.routeCounter(m.src, m.dest),
+	}
+	if m.coalesceWindow > 0 || m.coalesceMax > 0 {
+		rte.coalescer = newCoalescer(r.clock, m.coalesceWindow, m.coalesceMax, r.coalesceFlush(m.src, rte.dest, rte.delivered))
+	}
+	r.insertRoute(rt, m.src, rte, m.order)
+	r.replayTo(m.src, rte)
+
+	if !expires.IsZero() {
+		r.resetExpiryTimer()
+	}
+	return true, nil
+
+}
+
+// insertRoute places rte among src's routes in rt by insertion order. A zero
+// order assigns the next insertion sequence, appending the route.
+func (r *GenericRouter) insertRoute(rt routingTable, src ComponentID, rte *route, order uint64) {
+
+	if order == 0 {
+		r.routeOrder++
+		order = r.routeOrder
+	} else if order > r.routeOrder {
+		r.routeOrder = order
+	}
+	rte.order = order
+
+	routesArray := rt[src]
+	i := sort.Search(len(routesArray), func(i int) bool {
+		return routesArray[i].order > order
+	})
+	routesArray = append(routesArray, nil)
+	copy(routesArray[i+1:], routesArray[i:])
+	routesArray[i] = rte
+	rt[src] = routesArray
 
 }
 
 // RemoveRoute is a wrapper for external usage. Wrapping a send to the
 // external route channel of our router.
-func (r *GenericRouter) RemoveRoute(m msgRt) {
+func (r *GenericRouter) RemoveRoute(m msgRt) error {
+	if !r.initialized() {
+		return opError("RemoveRoute", m.src, m.dest, ErrNotInitialized)
+	}
 	// Tag on operation constant
 	m.op = REMOVEROUTE
-	// Send msgRt to external msgChan
-	r.externalRtChan <- m
+	return opError("RemoveRoute", m.src, m.dest, r.exec(m))
 }
 
 // removeRoute lookups a route's source, locates the given destination and
 // removes this destination from the route's component array.
-func (r *GenericRouter) removeRoute(m msgRt) {
+func (r *GenericRouter) removeRoute(m msgRt) error {
 
-	// Confirm source is in registered components array
-	if _, ok := r.rc[m.src]; !ok {
-		return
+	if r.removeVirtualRoute(m) {
+		return nil
 	}
-	if _, ok := r.rc[m.dest]; !ok {
-		return
+
+	// Confirm source is in registered components array
+	if !r.routableSource(m.src) {
+		return ErrNotRegistered
 	}
 
 	// Lookup component array for source
-	srcArray := r.rt[m.src]
+	rt := r.table(m.namespace)
+	srcArray := rt[m.src]
 
-	// Cycle through source array, remove destination component if found. Rrder
-	// not important so just swap to last and return len - 1
-	for i, c := range srcArray {
-		if r.rc[m.dest] == c {
-			srcArray[len(srcArray)-1], srcArray[i] = srcArray[i], srcArray[len(srcArray)-1]
+	// Cycle through source array, remove destination component if found.
+	// Shift the remaining routes down to keep them in insertion order.
+	for i, rte := range srcArray {
+		if r.matchDest(rte.dest, m.dest) {
+			rte.stop()
+			copy(srcArray[i:], srcArray[i+1:])
+			srcArray[len(srcArray)-1] = nil
 			srcArray = srcArray[:len(srcArray)-1]
+			rt[m.src] = srcArray
+			return nil
 		}
 	}
 
+	// The route may still be waiting for dest to register
+	if m.namespace == DefaultNamespace {
+		r.removePendingRoute(m)
+	}
+	return nil
+
+}
+
+// GetRoutes returns the IDs of the destinations src routes to.
+func (r *GenericRouter) GetRoutes(src ComponentID) ([]ComponentID, error) {
+	if !r.initialized() {
+		return nil, opError("GetRoutes", src, ZeroComponentID, ErrNotInitialized)
+	}
+
+	v, err := r.query(msgRt{op: GETROUTES, src: src})
+	if err != nil {
+		return nil, opError("GetRoutes", src, ZeroComponentID, err)
+	}
+	return v.([]ComponentID), nil
+}
+
+// getRoutes collects the destination IDs of a source's routes.
+func (r *GenericRouter) getRoutes(m msgRt) {
+
+	var dests []ComponentID
+	for _, rte := range r.rt[m.src] {
+		dest, _ := rte.dest.GetID()
+		dests = append(dests, dest)
+	}
+
+	m.reply <- dests
+}
+
+// RoutesTo returns the IDs of the sources with a route to dest, sorted. It is
+// the reverse of GetRoutes, e.g. to see who is affected by removing dest.
+func (r *GenericRouter) RoutesTo(dest ComponentID) ([]ComponentID, error) {
+	if !r.initialized() {
+		return nil, opError("RoutesTo", ZeroComponentID, dest, ErrNotInitialized)
+	}
+
+	v, err := r.query(msgRt{op: ROUTESTO, dest: dest})
+	if err != nil {
+		return nil, opError("RoutesTo", ZeroComponentID, dest, err)
+	}
+	return v.([]ComponentID), nil
+}
+
+// routesTo collects the sources routing to a destination.
+func (r *GenericRouter) routesTo(m msgRt) {
+
+	var srcs []ComponentID
+	for _, src := range r.sortedSources() {
+		for _, rte := range r.rt[src] {
+			if dest, _ := rte.dest.GetID(); dest == m.dest {
+				srcs = append(srcs, src)
+				break
+			}
+		}
+	}
+
+	m.reply <- srcs
+}
+
+// ListRoutesByLabel returns every route carrying the label key=value. The
+// query is answered by the consume loop so the result is a consistent
+// snapshot of the routing table.
+func (r *GenericRouter) ListRoutesByLabel(key, value string) ([]RouteInfo, error) {
+
+	if !r.initialized() {
+		return nil, opError("ListRoutesByLabel", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+	if key == "" {
+		return nil, opError("ListRoutesByLabel", ZeroComponentID, ZeroComponentID, errors.New("Label key must not be empty"))
+	}
+
+	v, err := r.query(msgRt{
+		op:     LISTROUTESBYLABEL,
+		labels: map[string]string{key: value},
+	})
+	if err != nil {
+		return nil, opError("ListRoutesByLabel", ZeroComponentID, ZeroComponentID, err)
+	}
+	return v.([]RouteInfo), nil
+}
+
+// listRoutesByLabel walks the routing table collecting routes whose labels
+// contain every key/value pair in msgRt.labels.
+func (r *GenericRouter) listRoutesByLabel(m msgRt) {
+
+	var infos []RouteInfo
+	for src, routesArray := range r.rt {
+		for _, rte := range routesArray {
+			if !matchLabels(rte.labels, m.labels) {
+				continue
+			}
+			dest, _ := rte.dest.GetID()
+			infos = append(infos, RouteInfo{
+				Src:    src,
+				Dest:   dest,
+				Labels: copyStringMap(rte.labels),
+				Order:  rte.order,
+			})
+		}
+	}
+
+	m.reply <- infos
+}
+
+// matchLabels reports whether labels contains every key/value pair in want.
+func matchLabels(labels, want map[string]string) bool {
+	for k, v := range want {
+		if lv, ok := labels[k]; !ok || lv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// copyStringMap returns an independent copy of a label or header map so
+// callers can't mutate maps stored by the router.
+func copyStringMap(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+	c := make(map[string]string, len(labels))
+	for k, v := range labels {
+		c[k] = v
+	}
+	return c
 }
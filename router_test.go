@@ -0,0 +1,957 @@
+package msgrouter
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testComponent records every payload sent to it.
+type testComponent struct {
+	mu       sync.Mutex
+	id       ComponentID
+	payloads []interface{}
+	// err is returned by Send, after recording the payload
+	err error
+}
+
+func (c *testComponent) Send(payload interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.payloads = append(c.payloads, payload)
+	return c.err
+}
+
+func (c *testComponent) SetID(id ComponentID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.id = id
+	return nil
+}
+
+func (c *testComponent) GetID() (ComponentID, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.id, nil
+}
+
+// received returns a copy of the payloads sent to c.
+func (c *testComponent) received() []interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]interface{}(nil), c.payloads...)
+}
+
+// count returns the number of payloads sent to c.
+func (c *testComponent) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.payloads)
+}
+
+// newRouter creates a router with opts for a test. Set up its components and
+// routes with register and addRoute, then run it with start.
+func newRouter(t testing.TB, opts ...Option) *GenericRouter {
+	t.Helper()
+	return NewGenericRouter(64, opts...)
+}
+
+// register registers a testComponent under id. It must be called before
+// start.
+func register(t testing.TB, r *GenericRouter, id ComponentID) *testComponent {
+	t.Helper()
+	c := &testComponent{}
+	registerAs(t, r, id, c)
+	return c
+}
+
+// registerAs registers c under id. It must be called before start.
+func registerAs(t testing.TB, r *GenericRouter, id ComponentID, c Component) {
+	t.Helper()
+	c.SetID(id)
+	r.rc.add(id, c)
+}
+
+// addRoute adds the route src -> dest. It must be called before start.
+func addRoute(t testing.TB, r *GenericRouter, src, dest ComponentID) {
+	t.Helper()
+	r.addRoute(msgRt{src: src, dest: dest})
+}
+
+// start runs r's consume loop until the test ends.
+func start(t testing.TB, r *GenericRouter) {
+	t.Helper()
+	go r.Consume()
+	t.Cleanup(func() { r.Stop() })
+	// Stop on a router whose loop hasn't started yet fails with ErrNotRunning
+	eventually(t, func() bool {
+		r.lifeMu.Lock()
+		defer r.lifeMu.Unlock()
+		return r.consuming || isClosed(r.done)
+	})
+}
+
+// eventually fails the test if cond doesn't hold within a second.
+func eventually(t testing.TB, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// within fails the test if fn doesn't return within a second.
+func within(t testing.TB, name string, fn func()) {
+	t.Helper()
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		fn()
+	}()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatalf("%s did not return", name)
+	}
+}
+
+func TestConsumeRoutesEveryMessage(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	a := register(t, r, "a")
+	b := register(t, r, "b")
+	c := register(t, r, "c")
+	addRoute(t, r, "src", "a")
+	addRoute(t, r, "src", "b")
+	addRoute(t, r, "src", "c")
+	r.removeRoute(msgRt{src: "src", dest: "b"})
+	start(t, r)
+
+	for i := 0; i < 3; i++ {
+		if err := r.Send(msgMsg{src: "src", payload: i}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	eventually(t, func() bool { return a.count() == 3 && c.count() == 3 })
+	if n := b.count(); n != 0 {
+		t.Fatalf("removed destination received %d payloads", n)
+	}
+}
+
+// panicComponent panics in Send.
+type panicComponent struct {
+	testComponent
+}
+
+func (c *panicComponent) Send(payload interface{}) error {
+	panic("boom")
+}
+
+func TestRecoverSendsReportsPanic(t *testing.T) {
+	errs := make(chan error, 2)
+	r := newRouter(t, WithRecoverSends(), WithOnSendError(func(src, dest ComponentID, payload interface{}, err error) {
+		errs <- err
+	}))
+	register(t, r, "src")
+	registerAs(t, r, "panics", &panicComponent{})
+	dest := register(t, r, "dest")
+	addRoute(t, r, "src", "panics")
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	for i := 0; i < 2; i++ {
+		if err := r.Send(msgMsg{src: "src", payload: i}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		select {
+		case err := <-errs:
+			if err == nil || !strings.Contains(err.Error(), "boom") {
+				t.Fatalf("reported %v, want the panic", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("panic was not reported")
+		}
+	}
+
+	// The router keeps routing after the panic
+	eventually(t, func() bool { return dest.count() == 2 })
+}
+
+func TestListRoutesByLabel(t *testing.T) {
+	r := newRouter(t)
+	for _, id := range []ComponentID{"src", "a", "b", "c"} {
+		register(t, r, id)
+	}
+	for dest, env := range map[ComponentID]string{"a": "prod", "b": "dev", "c": "prod"} {
+		r.addRoute(msgRt{src: "src", dest: dest, labels: map[string]string{"env": env}})
+	}
+	start(t, r)
+
+	infos, err := r.ListRoutesByLabel("env", "prod")
+	if err != nil {
+		t.Fatalf("ListRoutesByLabel: %v", err)
+	}
+	var dests []string
+	for _, info := range infos {
+		if info.Src != "src" || info.Labels["env"] != "prod" {
+			t.Fatalf("unexpected route %+v", info)
+		}
+		dests = append(dests, string(info.Dest))
+	}
+	sort.Strings(dests)
+	if strings.Join(dests, ",") != "a,c" {
+		t.Fatalf("routes labeled env=prod go to %v, want [a c]", dests)
+	}
+
+	// The returned labels are copies
+	infos[0].Labels["env"] = "dev"
+	if infos, _ := r.ListRoutesByLabel("env", "dev"); len(infos) != 1 {
+		t.Fatalf("env=dev matches %d routes, want 1", len(infos))
+	}
+}
+
+func TestSendBatchDeliversInOrder(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	dest := register(t, r, "dest")
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	if err := r.SendBatch("src", []interface{}{0, 1, 2, 3, 4}); err != nil {
+		t.Fatalf("SendBatch: %v", err)
+	}
+	eventually(t, func() bool { return dest.count() == 5 })
+	for i, p := range dest.received() {
+		if p != i {
+			t.Fatalf("received %v, want 0..4 in order", dest.received())
+		}
+	}
+}
+
+// overflow fills a router with a two message buffer and the given policy
+// before its consume loop runs, then sends one more message. It returns the
+// payloads delivered once the router runs, sorted, and the last Send's error.
+func overflow(t *testing.T, policy OverflowPolicy) ([]int, error) {
+	r := NewGenericRouter(2, WithOverflowPolicy(policy))
+	register(t, r, "src")
+	dest := register(t, r, "dest")
+	addRoute(t, r, "src", "dest")
+
+	for i := 1; i <= 2; i++ {
+		if err := r.Send(msgMsg{src: "src", payload: i}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	err := r.Send(msgMsg{src: "src", payload: 3})
+
+	start(t, r)
+	eventually(t, func() bool { return dest.count() == 2 })
+	var got []int
+	for _, p := range dest.received() {
+		got = append(got, p.(int))
+	}
+	sort.Ints(got)
+	return got, err
+}
+
+func TestOverflowPolicy(t *testing.T) {
+	got, err := overflow(t, DropNewest)
+	if !errors.Is(err, ErrBufferFull) {
+		t.Fatalf("DropNewest: Send to a full buffer = %v, want ErrBufferFull", err)
+	}
+	if got[0] != 1 || got[1] != 2 {
+		t.Fatalf("DropNewest delivered %v, want [1 2]", got)
+	}
+
+	got, err = overflow(t, DropOldest)
+	if err != nil {
+		t.Fatalf("DropOldest: Send to a full buffer = %v, want nil", err)
+	}
+	if got[0] != 2 || got[1] != 3 {
+		t.Fatalf("DropOldest delivered %v, want [2 3]", got)
+	}
+}
+
+// headerRecorder records the sequence header of every message sent to it.
+type headerRecorder struct {
+	testComponent
+	seqs []string
+}
+
+func (c *headerRecorder) SendWithHeaders(payload interface{}, headers map[string]string) error {
+	c.mu.Lock()
+	c.seqs = append(c.seqs, headers[SeqHeader])
+	c.mu.Unlock()
+	return c.Send(payload)
+}
+
+// blockingComponent blocks in Send until release is closed.
+type blockingComponent struct {
+	testComponent
+	release chan struct{}
+}
+
+func (c *blockingComponent) Send(payload interface{}) error {
+	<-c.release
+	return c.testComponent.Send(payload)
+}
+
+func TestDeliveryTimeout(t *testing.T) {
+	dlq := make(chan DeadLetter, 1)
+	r := newRouter(t, WithDeliveryTimeout(20*time.Millisecond), WithDeadLetterQueue(dlq))
+	register(t, r, "src")
+	dest := &blockingComponent{release: make(chan struct{})}
+	defer close(dest.release)
+	registerAs(t, r, "dest", dest)
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	if err := r.Send(msgMsg{src: "src", payload: 1}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	select {
+	case dl := <-dlq:
+		if dl.Dest != "dest" || !errors.Is(dl.Err, ErrSendTimeout) {
+			t.Fatalf("dead letter %+v, want a timed out delivery to dest", dl)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out delivery was not dead lettered")
+	}
+}
+
+func TestSendTimeoutWaitsForSpace(t *testing.T) {
+	r := NewGenericRouter(1, WithSendTimeout(30*time.Millisecond))
+	register(t, r, "src")
+	dest := register(t, r, "dest")
+	addRoute(t, r, "src", "dest")
+
+	// Fill the buffer before the consume loop runs
+	if err := r.Send(msgMsg{src: "src", payload: 0}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	began := time.Now()
+	err := r.Send(msgMsg{src: "src", payload: 1})
+	if !errors.Is(err, ErrBufferFull) {
+		t.Fatalf("Send to a full buffer = %v, want ErrBufferFull", err)
+	}
+	if waited := time.Since(began); waited < 30*time.Millisecond {
+		t.Fatalf("Send gave up after %v, want it to wait 30ms", waited)
+	}
+
+	// Space freed while Send waits lets it through
+	time.AfterFunc(10*time.Millisecond, func() { start(t, r) })
+	if err := r.Send(msgMsg{src: "src", payload: 2}); err != nil {
+		t.Fatalf("Send while the buffer drains = %v, want nil", err)
+	}
+	eventually(t, func() bool { return dest.count() == 2 })
+}
+
+func TestInvalidOp(t *testing.T) {
+	errs := make(chan error, 2)
+	r := newRouter(t, WithErrors(errs))
+	start(t, r)
+
+	// An op with a caller returns the error to it
+	errc := make(chan error, 1)
+	r.externalRtChan <- msgRt{op: 99, errc: errc}
+	if err := <-errc; !errors.Is(err, ErrInvalidOp) || !strings.Contains(err.Error(), "99") {
+		t.Fatalf("op 99 = %v, want ErrInvalidOp naming the op code", err)
+	}
+	if err := <-errs; !errors.Is(err, ErrInvalidOp) {
+		t.Fatalf("published %v, want ErrInvalidOp", err)
+	}
+
+	// A registry op without one only publishes it
+	r.externalRegChan <- msgReg{op: 99}
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrInvalidOp) {
+			t.Fatalf("published %v, want ErrInvalidOp", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("invalid registry op was not reported")
+	}
+}
+
+func TestRegisterWithID(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	start(t, r)
+
+	dest := &testComponent{}
+	if err := r.RegisterWithID("orders", dest); err != nil {
+		t.Fatalf("RegisterWithID: %v", err)
+	}
+	if id, _ := dest.GetID(); id != "orders" {
+		t.Fatalf("component got ID %q, want orders", id)
+	}
+	if err := r.RegisterWithID("orders", &testComponent{}); !errors.Is(err, ErrAlreadyRegistered) {
+		t.Fatalf("registering a taken ID = %v, want ErrAlreadyRegistered", err)
+	}
+
+	if _, err := r.AddRoute(msgRt{src: "src", dest: "orders"}); err != nil {
+		t.Fatalf("AddRoute: %v", err)
+	}
+	if dests, _ := r.GetRoutes("src"); len(dests) != 1 || dests[0] != "orders" {
+		t.Fatalf("GetRoutes = %v, want [orders]", dests)
+	}
+	if err := r.Send(msgMsg{src: "src", payload: 1}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	eventually(t, func() bool { return dest.count() == 1 })
+}
+
+// gateComponent blocks its first Send until gate is closed.
+type gateComponent struct {
+	testComponent
+	entered chan struct{}
+	gate    chan struct{}
+}
+
+func (c *gateComponent) Send(payload interface{}) error {
+	c.mu.Lock()
+	first := len(c.payloads) == 0
+	c.mu.Unlock()
+	if first {
+		close(c.entered)
+		<-c.gate
+	}
+	return c.testComponent.Send(payload)
+}
+
+func TestComponentIDIsZeroUntilRegistered(t *testing.T) {
+	r := newRouter(t)
+	start(t, r)
+	c := &testComponent{}
+	if id, _ := c.GetID(); !id.IsZero() || id != ZeroComponentID {
+		t.Fatalf("new component has ID %q, want the zero ID", id)
+	}
+
+	if err := r.RegisterComponent(msgReg{c: c}); err != nil {
+		t.Fatalf("RegisterComponent: %v", err)
+	}
+	eventually(t, func() bool {
+		id, _ := c.GetID()
+		return !id.IsZero()
+	})
+}
+
+// pickyComponent rejects any ID it is given with reject.
+type pickyComponent struct {
+	testComponent
+	reject error
+}
+
+func (c *pickyComponent) SetID(id ComponentID) error {
+	if c.reject != nil && !id.IsZero() {
+		return c.reject
+	}
+	return c.testComponent.SetID(id)
+}
+
+func TestSetIDFailureLeavesRegistryUnchanged(t *testing.T) {
+	r := newRouter(t)
+	start(t, r)
+	errRejected := errors.New("rejected")
+	c := &pickyComponent{reject: errRejected}
+
+	if err := r.RegisterComponent(msgReg{c: c}); !errors.Is(err, errRejected) {
+		t.Fatalf("RegisterComponent = %v, want the SetID error", err)
+	}
+	if err := r.RegisterWithID("c", c); !errors.Is(err, errRejected) {
+		t.Fatalf("RegisterWithID = %v, want the SetID error", err)
+	}
+	if ids, _ := r.ListComponents(); len(ids) != 0 {
+		t.Fatalf("registered %v after SetID failed, want none", ids)
+	}
+}
+
+// logComponent appends its ID to a log shared by several components.
+type logComponent struct {
+	testComponent
+	log *[]ComponentID
+	mu  *sync.Mutex
+}
+
+func (c *logComponent) Send(payload interface{}) error {
+	id, _ := c.GetID()
+	c.mu.Lock()
+	*c.log = append(*c.log, id)
+	c.mu.Unlock()
+	return c.testComponent.Send(payload)
+}
+
+func TestImportedRoutesKeepOrder(t *testing.T) {
+	src := newRouter(t)
+	for _, id := range []ComponentID{"src", "c", "a", "b"} {
+		register(t, src, id)
+	}
+	for _, dest := range []ComponentID{"c", "a", "b"} {
+		src.addRoute(msgRt{src: "src", dest: dest, labels: map[string]string{"export": "yes"}})
+	}
+	start(t, src)
+	infos, err := src.ListRoutesByLabel("export", "yes")
+	if err != nil {
+		t.Fatalf("ListRoutesByLabel: %v", err)
+	}
+
+	// Import into a new router in the opposite order of the recorded one
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Order > infos[j].Order })
+	r := newRouter(t)
+	register(t, r, "src")
+	var mu sync.Mutex
+	var log []ComponentID
+	for _, id := range []ComponentID{"a", "b", "c"} {
+		registerAs(t, r, id, &logComponent{log: &log, mu: &mu})
+	}
+	start(t, r)
+	for _, info := range infos {
+		if _, err := r.AddRoute(msgRt{src: info.Src, dest: info.Dest, order: info.Order}); err != nil {
+			t.Fatalf("AddRoute: %v", err)
+		}
+	}
+	if dests, _ := r.GetRoutes("src"); len(dests) != 3 {
+		t.Fatalf("GetRoutes = %v, want three routes", dests)
+	}
+
+	if err := r.Send(msgMsg{src: "src", payload: 1}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(log) == 3
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if log[0] != "c" || log[1] != "a" || log[2] != "b" {
+		t.Fatalf("delivered to %v, want [c a b]", log)
+	}
+}
+
+func TestRegisterGuards(t *testing.T) {
+	errVeto := errors.New("veto")
+	guarded := &testComponent{}
+	guard := func(c Component) error {
+		if c == Component(guarded) {
+			return errVeto
+		}
+		return nil
+	}
+	r := newRouter(t, WithRegisterGuard(guard), WithUnregisterGuard(guard))
+	start(t, r)
+
+	if err := r.RegisterComponent(msgReg{c: guarded}); !errors.Is(err, errVeto) {
+		t.Fatalf("RegisterComponent = %v, want the guard's error", err)
+	}
+	if err := r.RegisterWithID("guarded", guarded); !errors.Is(err, errVeto) {
+		t.Fatalf("RegisterWithID = %v, want the guard's error", err)
+	}
+	if ids, _ := r.ListComponents(); len(ids) != 0 {
+		t.Fatalf("registered %v, want none", ids)
+	}
+	if id, _ := guarded.GetID(); !id.IsZero() {
+		t.Fatalf("rejected component was given ID %q", id)
+	}
+
+	// The unregister guard vetoes unregistration
+	c := &testComponent{}
+	if err := r.RegisterWithID("c", c); err != nil {
+		t.Fatalf("RegisterWithID: %v", err)
+	}
+	guarded = c
+	if err := r.UnregisterComponent(msgReg{c: c}); !errors.Is(err, errVeto) {
+		t.Fatalf("UnregisterComponent = %v, want the guard's error", err)
+	}
+	if ids, _ := r.ListComponents(); len(ids) != 1 {
+		t.Fatal("vetoed unregistration removed the component")
+	}
+}
+
+func TestRoutesTo(t *testing.T) {
+	r := newRouter(t)
+	for _, id := range []ComponentID{"a", "b", "c", "d"} {
+		register(t, r, id)
+	}
+	addRoute(t, r, "a", "c")
+	addRoute(t, r, "b", "c")
+	addRoute(t, r, "a", "d")
+	start(t, r)
+
+	srcs, err := r.RoutesTo("c")
+	if err != nil {
+		t.Fatalf("RoutesTo: %v", err)
+	}
+	sort.Slice(srcs, func(i, j int) bool { return srcs[i] < srcs[j] })
+	if len(srcs) != 2 || srcs[0] != "a" || srcs[1] != "b" {
+		t.Fatalf("RoutesTo(c) = %v, want [a b]", srcs)
+	}
+}
+
+func TestOnQueueWait(t *testing.T) {
+	waits := make(chan time.Duration, 1)
+	r := newRouter(t, WithOnQueueWait(func(d time.Duration) {
+		waits <- d
+	}))
+	register(t, r, "src")
+	register(t, r, "dest")
+	addRoute(t, r, "src", "dest")
+
+	// Let a message wait 40ms in the buffer before the consume loop starts
+	if err := r.Send(msgMsg{src: "src", payload: 0}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	time.Sleep(40 * time.Millisecond)
+	start(t, r)
+
+	if d := <-waits; d < 40*time.Millisecond {
+		t.Fatalf("buffered message waited %v, want at least 40ms", d)
+	}
+}
+
+// goroutineRecorder records the go routine each Send runs on.
+type goroutineRecorder struct {
+	testComponent
+	goroutines []string
+}
+
+func (c *goroutineRecorder) Send(payload interface{}) error {
+	// The stack trace starts with "goroutine <id> ["
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	id := strings.Fields(string(buf))[1]
+	c.mu.Lock()
+	c.goroutines = append(c.goroutines, id)
+	c.mu.Unlock()
+	return c.testComponent.Send(payload)
+}
+
+func TestInlineDelivery(t *testing.T) {
+	r := newRouter(t, WithInlineDelivery())
+	register(t, r, "src")
+	dest := &goroutineRecorder{}
+	registerAs(t, r, "dest", dest)
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	for i := 0; i < 50; i++ {
+		if err := r.Send(msgMsg{src: "src", payload: i}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	eventually(t, func() bool { return dest.count() == 50 })
+
+	for i, p := range dest.received() {
+		if p != i {
+			t.Fatalf("message %d arrived as %v, want strict order", i, p)
+		}
+	}
+	// Every delivery is made by the consume loop itself
+	dest.mu.Lock()
+	defer dest.mu.Unlock()
+	for _, id := range dest.goroutines {
+		if id != dest.goroutines[0] {
+			t.Fatalf("delivered on go routines %v, want one", dest.goroutines)
+		}
+	}
+}
+
+// wrappedComponent wraps a Component, so wrapping the same component twice
+// gives two distinct pointers.
+type wrappedComponent struct {
+	Component
+}
+
+func TestRemoveRouteMatchesByID(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	inner := &testComponent{}
+	registerAs(t, r, "dest", &wrappedComponent{inner})
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	// Re-register dest in a new wrapper, the route keeps the old one
+	if err := r.UnregisterComponent(msgReg{c: inner}); err != nil {
+		t.Fatalf("UnregisterComponent: %v", err)
+	}
+	if err := r.RegisterWithID("dest", &wrappedComponent{inner}); err != nil {
+		t.Fatalf("RegisterWithID: %v", err)
+	}
+
+	if err := r.RemoveRoute(msgRt{src: "src", dest: "dest"}); err != nil {
+		t.Fatalf("RemoveRoute: %v", err)
+	}
+	if dests, _ := r.GetRoutes("src"); len(dests) != 0 {
+		t.Fatalf("routes %v after removal, want none", dests)
+	}
+}
+
+// mutatingComponent overwrites a key of every map payload it receives.
+type mutatingComponent struct {
+	testComponent
+}
+
+func (c *mutatingComponent) Send(payload interface{}) error {
+	payload.(map[string]string)["k"] = "mutated"
+	return c.testComponent.Send(payload)
+}
+
+func TestPayloadCloner(t *testing.T) {
+	clone := func(p interface{}) interface{} {
+		return copyStringMap(p.(map[string]string))
+	}
+	// Inline, so the mutation happens before the other delivery
+	r := newRouter(t, WithInlineDelivery(), WithPayloadCloner(clone))
+	register(t, r, "src")
+	registerAs(t, r, "mutator", &mutatingComponent{})
+	dest := register(t, r, "dest")
+	addRoute(t, r, "src", "mutator")
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	payload := map[string]string{"k": "original"}
+	if _, err := r.SendDetailed(msgMsg{src: "src", payload: payload}); err != nil {
+		t.Fatalf("SendDetailed: %v", err)
+	}
+
+	got := dest.received()
+	if len(got) != 1 {
+		t.Fatalf("dest received %d payloads, want 1", len(got))
+	}
+	if v := got[0].(map[string]string)["k"]; v != "original" {
+		t.Fatalf("dest saw k = %q, want original", v)
+	}
+	if payload["k"] != "original" {
+		t.Fatalf("sender's payload has k = %q, want original", payload["k"])
+	}
+}
+
+func TestForEachComponent(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "a")
+	register(t, r, "b")
+	start(t, r)
+
+	var seen []ComponentID
+	err := r.ForEachComponent(func(id ComponentID, c Component) bool {
+		seen = append(seen, id)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("ForEachComponent: %v", err)
+	}
+	if len(seen) != 2 {
+		t.Fatalf("visited %v, want a and b", seen)
+	}
+
+	// Returning false stops the visit
+	seen = nil
+	r.ForEachComponent(func(id ComponentID, c Component) bool {
+		seen = append(seen, id)
+		return false
+	})
+	if len(seen) != 1 {
+		t.Fatalf("visited %v, want one component", seen)
+	}
+
+	r.Stop()
+	err = r.ForEachComponent(func(ComponentID, Component) bool { return true })
+	var rerr *RouterError
+	if !errors.As(err, &rerr) || rerr.Op != "ForEachComponent" || !errors.Is(err, ErrRouterClosed) {
+		t.Fatalf("ForEachComponent after Stop = %v, want a RouterError wrapping ErrRouterClosed", err)
+	}
+}
+
+// deliverSingle sends n payloads from src to its destination and returns the
+// payloads, sequence headers and delivery count the destination ends up with.
+// With tap set the source also has a tap, which takes it off the single
+// destination fast path.
+func deliverSingle(t *testing.T, n int, tap bool) ([]interface{}, []string, uint64) {
+	r := newRouter(t, WithInlineDelivery())
+	register(t, r, "src")
+	dest := &headerRecorder{}
+	registerAs(t, r, "dest", dest)
+	addRoute(t, r, "src", "dest")
+	if tap {
+		register(t, r, "tap")
+	}
+	start(t, r)
+	if tap {
+		if err := r.AddTap("src", "tap"); err != nil {
+			t.Fatalf("AddTap: %v", err)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		done := make(chan error, 1)
+		if err := r.Send(msgMsg{src: "src", payload: i, done: done}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		if err := <-done; err != nil {
+			t.Fatalf("delivery of %d: %v", i, err)
+		}
+	}
+
+	stats, err := r.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	dest.mu.Lock()
+	defer dest.mu.Unlock()
+	return dest.payloads, dest.seqs, stats.MessagesDelivered
+}
+
+func TestSendSingleDestMatchesFanout(t *testing.T) {
+	fastPayloads, fastSeqs, fastDelivered := deliverSingle(t, 10, false)
+	slowPayloads, slowSeqs, slowDelivered := deliverSingle(t, 10, true)
+
+	if len(fastPayloads) != 10 {
+		t.Fatalf("delivered %d payloads, want 10", len(fastPayloads))
+	}
+	for i := range fastPayloads {
+		if fastPayloads[i] != slowPayloads[i] || fastSeqs[i] != slowSeqs[i] {
+			t.Fatalf("message %d: fast path got %v seq %s, fanout got %v seq %s",
+				i, fastPayloads[i], fastSeqs[i], slowPayloads[i], slowSeqs[i])
+		}
+	}
+	if fastDelivered != 10 || slowDelivered != 10 {
+		t.Fatalf("delivered counts %d and %d, want 10", fastDelivered, slowDelivered)
+	}
+}
+
+func BenchmarkSendSingleDest(b *testing.B) {
+	r := newRouter(b, WithInlineDelivery())
+	register(b, r, "src")
+	register(b, r, "dest")
+	addRoute(b, r, "src", "dest")
+	start(b, r)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for r.Send(msgMsg{src: "src", payload: i}) != nil {
+			runtime.Gosched()
+		}
+	}
+}
+
+func TestOnRegisterAndUnregister(t *testing.T) {
+	var mu sync.Mutex
+	var registered, unregistered []ComponentID
+	r := newRouter(t,
+		WithOnRegister(func(id ComponentID, c Component) {
+			mu.Lock()
+			defer mu.Unlock()
+			registered = append(registered, id)
+		}),
+		WithOnUnregister(func(id ComponentID) {
+			mu.Lock()
+			defer mu.Unlock()
+			unregistered = append(unregistered, id)
+		}),
+	)
+	start(t, r)
+
+	a := &testComponent{}
+	if err := r.RegisterWithID("a", a); err != nil {
+		t.Fatalf("RegisterWithID: %v", err)
+	}
+	if err := r.RegisterWithID("b", &testComponent{}); err != nil {
+		t.Fatalf("RegisterWithID: %v", err)
+	}
+	// Failed operations aren't reported
+	if err := r.RegisterWithID("a", &testComponent{}); err == nil {
+		t.Fatal("RegisterWithID of a taken ID succeeded")
+	}
+	if err := r.UnregisterComponent(msgReg{c: a}); err != nil {
+		t.Fatalf("UnregisterComponent: %v", err)
+	}
+	if err := r.UnregisterComponent(msgReg{c: a}); err == nil {
+		t.Fatal("UnregisterComponent of an unregistered component succeeded")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(registered) != 2 || registered[0] != "a" || registered[1] != "b" {
+		t.Fatalf("OnRegister saw %v, want [a b]", registered)
+	}
+	if len(unregistered) != 1 || unregistered[0] != "a" {
+		t.Fatalf("OnUnregister saw %v, want [a]", unregistered)
+	}
+}
+
+func TestAddRouteReportsAdded(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	register(t, r, "dest")
+	start(t, r)
+
+	for i, want := range []bool{true, false} {
+		added, err := r.AddRoute(msgRt{src: "src", dest: "dest"})
+		if err != nil {
+			t.Fatalf("AddRoute: %v", err)
+		}
+		if added != want {
+			t.Fatalf("AddRoute call %d reported added %v, want %v", i+1, added, want)
+		}
+	}
+	if dests, _ := r.GetRoutes("src"); len(dests) != 1 {
+		t.Fatalf("routes %v, want the route once", dests)
+	}
+}
+
+func TestDropOldestKeepsMarkersInPlace(t *testing.T) {
+	r := NewGenericRouter(2, WithOverflowPolicy(DropOldest), WithInlineDelivery())
+	register(t, r, "a")
+	register(t, r, "b")
+	dest := &gateComponent{entered: make(chan struct{}), gate: make(chan struct{})}
+	registerAs(t, r, "dest", dest)
+	addRoute(t, r, "a", "dest")
+	addRoute(t, r, "b", "dest")
+	start(t, r)
+
+	// Stall the consume loop delivering a0, then fill the buffer with markers
+	if err := r.Send(msgMsg{src: "a", payload: "a0"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	<-dest.entered
+	drained := make(chan error, 1)
+	go func() { drained <- r.DrainSource("a") }()
+	eventually(t, func() bool { return len(r.internalMsgChan) == 1 })
+	flushed := make(chan error, 1)
+	go func() { flushed <- r.Flush(context.Background()) }()
+	eventually(t, func() bool { return len(r.internalMsgChan) == 2 })
+
+	// Only markers are buffered; they must make room without losing their place
+	within(t, "Send to a buffer of markers", func() {
+		if err := r.Send(msgMsg{src: "b", payload: "b1"}); err != nil {
+			t.Errorf("Send: %v", err)
+		}
+	})
+	// Sent after DrainSource, so dropped once the marker closes a
+	if err := r.Send(msgMsg{src: "a", payload: "a1"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	close(dest.gate)
+	if err := <-drained; err != nil {
+		t.Fatalf("DrainSource: %v", err)
+	}
+	if err := <-flushed; err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	r.Flush(context.Background())
+	got := dest.received()
+	if len(got) != 2 || got[0] != "a0" || got[1] != "b1" {
+		t.Fatalf("delivered %v, want [a0 b1]", got)
+	}
+}
@@ -0,0 +1,79 @@
+package msgrouter
+
+import "sync/atomic"
+
+// RouteKey identifies the edge between a source and a destination.
+type RouteKey struct {
+	Src  ComponentID
+	Dest ComponentID
+}
+
+// RouteStats returns a copy of the number of successful deliveries per
+// (source, destination) edge. Counts include deliveries made through rules
+// and virtual destinations and survive the route's removal.
+func (r *GenericRouter) RouteStats() (map[RouteKey]uint64, error) {
+	if !r.initialized() {
+		return nil, opError("RouteStats", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+
+	v, err := r.query(msgRt{op: ROUTESTATS})
+	if err != nil {
+		return nil, opError("RouteStats", ZeroComponentID, ZeroComponentID, err)
+	}
+	return v.(map[RouteKey]uint64), nil
+}
+
+// routeStats copies the per edge counters.
+func (r *GenericRouter) routeStats(m msgRt) {
+
+	stats := make(map[RouteKey]uint64, len(r.routeCounters))
+	for key, c := range r.routeCounters {
+		stats[key] = atomic.LoadUint64(c)
+	}
+
+	m.reply <- stats
+}
+
+// routeCounter returns the delivery counter for an edge, creating it on
+// first use. Counters are created in the consume loop and handed to delivery
+// go routines, which only ever increment them.
+func (r *GenericRouter) routeCounter(src, dest ComponentID) *uint64 {
+	key := RouteKey{Src: src, Dest: dest}
+	c, ok := r.routeCounters[key]
+	if !ok {
+		c = new(uint64)
+		r.routeCounters[key] = c
+	}
+	return c
+}
+
+// RouteCount returns the number of sources with at least one route and the
+// total number of routes in the routing table. Rules, taps and virtual routes
+// are not counted.
+func (r *GenericRouter) RouteCount() (sources int, edges int, err error) {
+	if !r.initialized() {
+		return 0, 0, opError("RouteCount", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+
+	v, err := r.query(msgRt{op: ROUTECOUNT})
+	if err != nil {
+		return 0, 0, opError("RouteCount", ZeroComponentID, ZeroComponentID, err)
+	}
+	counts := v.([2]int)
+	return counts[0], counts[1], nil
+}
+
+// routeCount counts the sources and edges of the routing table.
+func (r *GenericRouter) routeCount(m msgRt) {
+
+	var counts [2]int
+	for _, routes := range r.rt {
+		if len(routes) == 0 {
+			continue
+		}
+		counts[0]++
+		counts[1] += len(routes)
+	}
+
+	m.reply <- counts
+}
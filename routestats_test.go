@@ -0,0 +1,59 @@
+package msgrouter
+
+import "testing"
+
+func TestRouteStatsPerEdge(t *testing.T) {
+	r := newRouter(t)
+	for _, id := range []ComponentID{"a", "b", "x", "y"} {
+		register(t, r, id)
+	}
+	addRoute(t, r, "a", "x")
+	addRoute(t, r, "b", "y")
+	start(t, r)
+
+	for i := 0; i < 3; i++ {
+		if err := r.Send(msgMsg{src: "a", payload: i}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	if err := r.Send(msgMsg{src: "b", payload: 0}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	var stats map[RouteKey]uint64
+	eventually(t, func() bool {
+		var err error
+		if stats, err = r.RouteStats(); err != nil {
+			t.Fatalf("RouteStats: %v", err)
+		}
+		return stats[RouteKey{"a", "x"}] == 3 && stats[RouteKey{"b", "y"}] == 1
+	})
+	if len(stats) != 2 {
+		t.Fatalf("RouteStats = %v, want a->x: 3 and b->y: 1", stats)
+	}
+}
+
+func TestRouteCount(t *testing.T) {
+	r := newRouter(t)
+	for _, id := range []ComponentID{"a", "b", "c", "d"} {
+		register(t, r, id)
+	}
+	addRoute(t, r, "a", "b")
+	addRoute(t, r, "a", "c")
+	addRoute(t, r, "a", "d")
+	addRoute(t, r, "b", "c")
+	addRoute(t, r, "c", "d")
+	start(t, r)
+	// A source whose last route is removed no longer counts
+	if err := r.RemoveRoute(msgRt{src: "c", dest: "d"}); err != nil {
+		t.Fatalf("RemoveRoute: %v", err)
+	}
+
+	sources, edges, err := r.RouteCount()
+	if err != nil {
+		t.Fatalf("RouteCount: %v", err)
+	}
+	if sources != 2 || edges != 4 {
+		t.Fatalf("RouteCount = %d sources, %d edges, want 2 and 4", sources, edges)
+	}
+}
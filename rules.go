@@ -0,0 +1,99 @@
+package msgrouter
+
+import "errors"
+
+// RuleMode controls whether rule evaluation continues after a rule matches.
+type RuleMode int
+
+const (
+	// FirstMatch stops evaluating a source's rules once this rule matches.
+	// This is the default.
+	FirstMatch RuleMode = iota
+	// AllMatch delivers to this rule's destination when it matches and keeps
+	// evaluating the following rules.
+	AllMatch
+)
+
+// Rule routes a source's payloads matching Predicate to Dest. A source's
+// rules are evaluated in the order they were added, in addition to the
+// source's regular routes.
+type Rule struct {
+	Predicate func(payload interface{}) bool
+	Dest      ComponentID
+	Mode      RuleMode
+}
+
+// rule is a Rule with its destination resolved to a registered component.
+type rule struct {
+	predicate func(payload interface{}) bool
+	dest      Component
+	mode      RuleMode
+	delivered *uint64
+}
+
+// AddRule appends a content based routing rule to src's rule set.
+func (r *GenericRouter) AddRule(src ComponentID, rl Rule) error {
+	if !r.initialized() {
+		return opError("AddRule", src, rl.Dest, ErrNotInitialized)
+	}
+
+	return opError("AddRule", src, rl.Dest, r.exec(msgRt{op: ADDRULE, src: src, dest: rl.Dest, rule: rl}))
+}
+
+// ClearRules removes every rule from src's rule set.
+func (r *GenericRouter) ClearRules(src ComponentID) error {
+	if !r.initialized() {
+		return opError("ClearRules", src, ZeroComponentID, ErrNotInitialized)
+	}
+
+	return opError("ClearRules", src, ZeroComponentID, r.exec(msgRt{op: CLEARRULES, src: src}))
+}
+
+// addRule validates and stores a rule. Both source and destination must be
+// registered.
+func (r *GenericRouter) addRule(m msgRt) error {
+
+	if m.rule.Predicate == nil {
+		return errors.New("Rule has no predicate")
+	}
+	if !r.rc.has(m.src) {
+		return ErrNotRegistered
+	}
+	dest, ok := r.rc.get(m.dest)
+	if !ok {
+		return ErrNotRegistered
+	}
+
+	r.rules[m.src] = append(r.rules[m.src], rule{
+		predicate: m.rule.Predicate,
+		dest:      dest,
+		mode:      m.rule.Mode,
+		delivered: r.routeCounter(m.src, m.dest),
+	})
+	return nil
+
+}
+
+// clearRules drops a source's rule set.
+func (r *GenericRouter) clearRules(m msgRt) error {
+	delete(r.rules, m.src)
+	return nil
+}
+
+// matchRules evaluates rules against a payload, returning the matching
+// rules.
+func matchRules(rules []rule, payload interface{}) []rule {
+
+	var matched []rule
+	for _, rl := range rules {
+		if !rl.predicate(payload) {
+			continue
+		}
+		matched = append(matched, rl)
+		if rl.mode == FirstMatch {
+			break
+		}
+	}
+
+	return matched
+}
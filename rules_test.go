@@ -0,0 +1,32 @@
+package msgrouter
+
+import "testing"
+
+func TestRulesRouteByContent(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	strs := register(t, r, "strings")
+	ints := register(t, r, "ints")
+	start(t, r)
+
+	isString := func(p interface{}) bool { _, ok := p.(string); return ok }
+	isInt := func(p interface{}) bool { _, ok := p.(int); return ok }
+	if err := r.AddRule("src", Rule{Predicate: isString, Dest: "strings"}); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	if err := r.AddRule("src", Rule{Predicate: isInt, Dest: "ints"}); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+
+	if err := r.SendBatch("src", []interface{}{"a", 1, "b", 2}); err != nil {
+		t.Fatalf("SendBatch: %v", err)
+	}
+	eventually(t, func() bool { return strs.count() == 2 && ints.count() == 2 })
+
+	if got := strs.received(); got[0] != "a" || got[1] != "b" {
+		t.Fatalf("strings received %v, want [a b]", got)
+	}
+	if got := ints.received(); got[0] != 1 || got[1] != 2 {
+		t.Fatalf("ints received %v, want [1 2]", got)
+	}
+}
@@ -0,0 +1,166 @@
+package msgrouter
+
+import (
+	"container/heap"
+	"errors"
+	"time"
+)
+
+// ScheduleID identifies a message scheduled with SendAfter.
+type ScheduleID uint64
+
+// ErrNotScheduled is returned by CancelScheduled when the scheduled message
+// has already been delivered or cancelled.
+var ErrNotScheduled = errors.New("Message not scheduled")
+
+// scheduled is a message waiting in the router's timer heap.
+type scheduled struct {
+	id    ScheduleID
+	due   time.Time
+	msg   msgMsg
+	index int
+}
+
+// scheduleHeap is a min-heap of scheduled messages ordered by due time.
+type scheduleHeap []*scheduled
+
+func (h scheduleHeap) Len() int           { return len(h) }
+func (h scheduleHeap) Less(i, j int) bool { return h[i].due.Before(h[j].due) }
+func (h scheduleHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *scheduleHeap) Push(x interface{}) {
+	s := x.(*scheduled)
+	s.index = len(*h)
+	*h = append(*h, s)
+}
+
+func (h *scheduleHeap) Pop() interface{} {
+	old := *h
+	s := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return s
+}
+
+// SendAfter routes m once d has elapsed. The returned ScheduleID can be
+// passed to CancelScheduled to drop the message before it fires. A payload
+// too large for the router is rejected up front. Once it fires, the message
+// is enqueued as by Send, so load shedding and the overflow policy apply; if
+// it is rejected then, the error goes to m's done channel and the router's
+// error channel.
+func (r *GenericRouter) SendAfter(m msgMsg, d time.Duration) (ScheduleID, error) {
+	if !r.initialized() {
+		return 0, opError("SendAfter", m.src, ZeroComponentID, ErrNotInitialized)
+	}
+
+	if err := r.checkPayloadSize(m); err != nil {
+		r.countDropped(PayloadTooLarge, payloadCount(m))
+		return 0, opError("SendAfter", m.src, ZeroComponentID, err)
+	}
+
+	v, err := r.query(msgRt{op: SCHEDULE, msg: m, delay: d})
+	if err != nil {
+		return 0, opError("SendAfter", m.src, ZeroComponentID, err)
+	}
+	return v.(ScheduleID), nil
+}
+
+// CancelScheduled removes a message scheduled by SendAfter from the timer
+// heap. It returns ErrNotScheduled if the message already fired or was
+// cancelled.
+func (r *GenericRouter) CancelScheduled(id ScheduleID) error {
+	if !r.initialized() {
+		return opError("CancelScheduled", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+
+	return opError("CancelScheduled", ZeroComponentID, ZeroComponentID, r.exec(msgRt{op: CANCELSCHEDULED, scheduleID: id}))
+}
+
+// schedule pushes a message onto the timer heap and returns its ID.
+func (r *GenericRouter) schedule(m msgRt) {
+
+	r.nextScheduleID++
+	s := &scheduled{
+		id:  r.nextScheduleID,
+		due: r.clock.Now().Add(m.delay),
+		msg: m.msg,
+	}
+	heap.Push(&r.schedules, s)
+	r.scheduleIndex[s.id] = s
+	r.resetScheduleTimer()
+
+	m.reply <- s.id
+}
+
+// cancelScheduled removes a message from the timer heap.
+func (r *GenericRouter) cancelScheduled(m msgRt) error {
+
+	s, ok := r.scheduleIndex[m.scheduleID]
+	if !ok {
+		return ErrNotScheduled
+	}
+
+	heap.Remove(&r.schedules, s.index)
+	delete(r.scheduleIndex, s.id)
+	r.resetScheduleTimer()
+	return nil
+
+}
+
+// fireScheduled enqueues every scheduled message which is due. It runs in the
+// consume loop when the schedule timer fires, so the messages are enqueued on
+// their own go routine; enqueue may wait for buffer space only the consume
+// loop can free.
+func (r *GenericRouter) fireScheduled() {
+
+	now := r.clock.Now()
+	var due []msgMsg
+	for len(r.schedules) > 0 && !r.schedules[0].due.After(now) {
+		s := heap.Pop(&r.schedules).(*scheduled)
+		delete(r.scheduleIndex, s.id)
+		due = append(due, s.msg)
+	}
+	r.resetScheduleTimer()
+
+	if len(due) == 0 {
+		return
+	}
+	go func() {
+		for _, m := range due {
+			if err := r.enqueue(m); err != nil {
+				notifyDropped(m, err)
+				r.reportError(opError("SendAfter", m.src, ZeroComponentID, err))
+			}
+		}
+	}()
+
+}
+
+// resetScheduleTimer arms the schedule timer for the earliest due message, or
+// disarms it when the heap is empty.
+func (r *GenericRouter) resetScheduleTimer() {
+
+	if r.scheduleTimer != nil {
+		r.scheduleTimer.Stop()
+		r.scheduleTimer = nil
+	}
+
+	if len(r.schedules) == 0 {
+		return
+	}
+	r.scheduleTimer = r.clock.NewTimer(r.schedules[0].due.Sub(r.clock.Now()))
+
+}
+
+// scheduleC returns the channel of the schedule timer. A nil channel is
+// returned when nothing is scheduled, which blocks forever in a select.
+func (r *GenericRouter) scheduleC() <-chan time.Time {
+	if r.scheduleTimer == nil {
+		return nil
+	}
+	return r.scheduleTimer.C()
+}
@@ -0,0 +1,80 @@
+package msgrouter
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCancelScheduled(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	dest := register(t, r, "dest")
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	cancelled, err := r.SendAfter(msgMsg{src: "src", payload: "cancelled"}, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("SendAfter: %v", err)
+	}
+	if _, err := r.SendAfter(msgMsg{src: "src", payload: "kept"}, 10*time.Millisecond); err != nil {
+		t.Fatalf("SendAfter: %v", err)
+	}
+	if err := r.CancelScheduled(cancelled); err != nil {
+		t.Fatalf("CancelScheduled: %v", err)
+	}
+	if err := r.CancelScheduled(cancelled); !errors.Is(err, ErrNotScheduled) {
+		t.Fatalf("second CancelScheduled = %v, want ErrNotScheduled", err)
+	}
+
+	eventually(t, func() bool { return dest.count() == 1 })
+	time.Sleep(20 * time.Millisecond)
+	if got := dest.received(); len(got) != 1 || got[0] != "kept" {
+		t.Fatalf("delivered %v, want [kept]", got)
+	}
+}
+
+func TestSendAfterGoesThroughSend(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	errs := make(chan error, 4)
+	r := NewGenericRouter(1, WithClock(clock), WithInlineDelivery(), WithErrors(errs), WithMaxPayloadBytes(4))
+	register(t, r, "src")
+	dest := &gateComponent{entered: make(chan struct{}), gate: make(chan struct{})}
+	registerAs(t, r, "dest", dest)
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	if _, err := r.SendAfter(msgMsg{src: "src", payload: "too large"}, time.Millisecond); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Fatalf("SendAfter = %v, want ErrPayloadTooLarge", err)
+	}
+
+	// The first message to fire holds the consume loop in delivery, so the
+	// one-slot buffer can't take all three
+	dones := make([]chan error, 3)
+	for i := range dones {
+		dones[i] = make(chan error, 1)
+		if _, err := r.SendAfter(msgMsg{src: "src", payload: i, done: dones[i]}, 10*time.Millisecond); err != nil {
+			t.Fatalf("SendAfter: %v", err)
+		}
+	}
+	clock.Advance(10 * time.Millisecond)
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrBufferFull) {
+			t.Fatalf("router error %v, want ErrBufferFull", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no message rejected by the full buffer")
+	}
+
+	close(dest.gate)
+	rejected := 0
+	for _, done := range dones {
+		if err := <-done; errors.Is(err, ErrBufferFull) {
+			rejected++
+		}
+	}
+	if rejected == 0 || dest.count()+rejected != 3 {
+		t.Fatalf("%d delivered and %d rejected, want every message accounted for", dest.count(), rejected)
+	}
+}
@@ -0,0 +1,55 @@
+package msgrouter
+
+// DeliverySemantics is the guarantee the router gives for a delivery to a
+// destination.
+type DeliverySemantics int
+
+const (
+	// AtMostOnce reports a failed delivery and moves on; the message is
+	// dead lettered and never redelivered. AckingComponents still follow
+	// the RetryPolicy when they NACK or fail to ACK. This is the default.
+	AtMostOnce DeliverySemantics = iota
+	// AtLeastOnce redelivers every failed delivery according to the
+	// RetryPolicy, and dead letters the message only once the retries are
+	// exhausted. A destination whose Send failed after acting on the
+	// message, or timed out (see WithDeliveryTimeout) while still working
+	// on it, receives it again, so destinations must tolerate duplicates.
+	// Retries are made before the delivery moves on to the next message,
+	// so with WithOrderedDelivery or WithInlineDelivery a retried message
+	// never falls behind newer messages from its source.
+	AtLeastOnce
+)
+
+// WithDeliverySemantics sets the delivery guarantee for every destination.
+func WithDeliverySemantics(s DeliverySemantics) Option {
+	return func(r *GenericRouter) {
+		r.semantics = s
+	}
+}
+
+// deliverWithRetry delivers a message to comp, redelivering failures
+// according to the retry policy under AtLeastOnce.
+func (r *GenericRouter) deliverWithRetry(comp Component, m msgMsg) error {
+
+	err := r.deliver(comp, m)
+	if r.semantics != AtLeastOnce {
+		return err
+	}
+
+	for attempt := 1; attempt <= r.retry.MaxRetries && err != nil; attempt++ {
+		// deliverAck has already retried these
+		if err == ErrNack || err == ErrAckTimeout {
+			break
+		}
+		if m.ctx != nil && m.ctx.Err() != nil {
+			break
+		}
+		if r.retry.Backoff > 0 {
+			<-r.clock.After(r.retry.Backoff)
+		}
+		err = r.deliver(comp, m)
+	}
+
+	return err
+
+}
@@ -0,0 +1,81 @@
+package msgrouter
+
+import (
+	"errors"
+	"testing"
+)
+
+// flakyComponent fails the first delivery of each payload in fail.
+type flakyComponent struct {
+	testComponent
+	fail map[interface{}]bool
+	// attempts counts every delivery, failed or not
+	attempts int
+}
+
+func (c *flakyComponent) Send(payload interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attempts++
+	if c.fail[payload] {
+		delete(c.fail, payload)
+		return errors.New("failed")
+	}
+	c.payloads = append(c.payloads, payload)
+	return nil
+}
+
+func TestDeliverySemantics(t *testing.T) {
+	for _, tc := range []struct {
+		semantics DeliverySemantics
+		attempts  int
+		delivered int
+	}{
+		{AtMostOnce, 1, 0},
+		{AtLeastOnce, 2, 1},
+	} {
+		r := newRouter(t, WithDeliverySemantics(tc.semantics), WithRetryPolicy(RetryPolicy{MaxRetries: 3}), WithDeadLetterRing(1))
+		register(t, r, "src")
+		dest := &flakyComponent{fail: map[interface{}]bool{1: true}}
+		registerAs(t, r, "dest", dest)
+		addRoute(t, r, "src", "dest")
+		start(t, r)
+
+		r.SendDetailed(msgMsg{src: "src", payload: 1})
+
+		dest.mu.Lock()
+		attempts, delivered := dest.attempts, len(dest.payloads)
+		dest.mu.Unlock()
+		if attempts != tc.attempts || delivered != tc.delivered {
+			t.Fatalf("semantics %v: %d attempts, %d delivered, want %d and %d", tc.semantics, attempts, delivered, tc.attempts, tc.delivered)
+		}
+		if dls := r.DeadLetters(); len(dls) != 1-tc.delivered {
+			t.Fatalf("semantics %v: %d dead letters, want %d", tc.semantics, len(dls), 1-tc.delivered)
+		}
+	}
+}
+
+func TestRetriedMessageKeepsOrder(t *testing.T) {
+	for name, opt := range map[string]Option{
+		"ordered": WithOrderedDelivery(),
+		"inline":  WithInlineDelivery(),
+	} {
+		r := newRouter(t, opt, WithDeliverySemantics(AtLeastOnce), WithRetryPolicy(RetryPolicy{MaxRetries: 3}))
+		register(t, r, "src")
+		dest := &flakyComponent{fail: map[interface{}]bool{2: true}}
+		registerAs(t, r, "dest", dest)
+		addRoute(t, r, "src", "dest")
+		start(t, r)
+
+		for i := 1; i <= 3; i++ {
+			if err := r.Send(msgMsg{src: "src", payload: i}); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+		}
+		eventually(t, func() bool { return dest.count() == 3 })
+
+		if got := dest.received(); got[0] != 1 || got[1] != 2 || got[2] != 3 {
+			t.Fatalf("%s: dest received %v, want [1 2 3]", name, got)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package msgrouter
+
+import (
+	"errors"
+	"strconv"
+)
+
+// SeqHeader is the header key under which the router stamps each message's
+// per-source sequence number. Sequence numbers start at 0 for every source.
+const SeqHeader = "seq"
+
+// ParseSeq extracts the sequence number from a message's headers.
+func ParseSeq(headers map[string]string) (uint64, error) {
+	v, ok := headers[SeqHeader]
+	if !ok {
+		return 0, errors.New("Message has no sequence number")
+	}
+	return strconv.ParseUint(v, 10, 64)
+}
+
+// SeqTracker helps a destination detect dropped or reordered messages from a
+// single source. It is not safe for concurrent use; keep one per source.
+type SeqTracker struct {
+	next uint64
+}
+
+// Observe records a received sequence number and returns the gap between it
+// and the expected one. A gap of 0 means the message arrived in order, a
+// positive gap is the number of messages missed and a negative gap means the
+// message is a duplicate or arrived out of order.
+func (t *SeqTracker) Observe(seq uint64) int64 {
+	gap := int64(seq - t.next)
+	if gap >= 0 {
+		t.next = seq + 1
+	}
+	return gap
+}
+
+// ObserveHeaders is Observe for a message's headers.
+func (t *SeqTracker) ObserveHeaders(headers map[string]string) (int64, error) {
+	seq, err := ParseSeq(headers)
+	if err != nil {
+		return 0, err
+	}
+	return t.Observe(seq), nil
+}
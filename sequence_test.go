@@ -0,0 +1,45 @@
+package msgrouter
+
+import "testing"
+
+func TestSequenceNumbers(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	dest := &headerRecorder{}
+	registerAs(t, r, "dest", dest)
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	if err := r.SendBatch("src", []interface{}{0, 1, 2, 3, 4}); err != nil {
+		t.Fatalf("SendBatch: %v", err)
+	}
+	eventually(t, func() bool { return dest.count() == 5 })
+
+	var tracker SeqTracker
+	dest.mu.Lock()
+	defer dest.mu.Unlock()
+	for i, s := range dest.seqs {
+		seq, err := ParseSeq(map[string]string{SeqHeader: s})
+		if err != nil {
+			t.Fatalf("ParseSeq: %v", err)
+		}
+		if seq != uint64(i) {
+			t.Fatalf("message %d has seq %d", i, seq)
+		}
+		if gap := tracker.Observe(seq); gap != 0 {
+			t.Fatalf("Observe(%d) = %d, want no gap", seq, gap)
+		}
+	}
+}
+
+func TestSeqTrackerGaps(t *testing.T) {
+	var tracker SeqTracker
+	for _, tc := range []struct {
+		seq uint64
+		gap int64
+	}{{0, 0}, {1, 0}, {4, 2}, {3, -2}, {5, 0}} {
+		if gap := tracker.Observe(tc.seq); gap != tc.gap {
+			t.Fatalf("Observe(%d) = %d, want %d", tc.seq, gap, tc.gap)
+		}
+	}
+}
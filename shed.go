@@ -0,0 +1,46 @@
+package msgrouter
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrShed is returned by Send when the router is shedding load and the
+// message's priority is below the shedding threshold.
+var ErrShed = errors.New("Message shed under overload")
+
+// WithShedding enables overload protection. Once the message buffer holds
+// more than high messages Send rejects messages with a priority below
+// minPriority with ErrShed, until the buffer drops below low. SheddingStarted
+// and SheddingStopped events mark the transitions.
+func WithShedding(high, low, minPriority int) Option {
+	return func(r *GenericRouter) {
+		r.shedHigh = high
+		r.shedLow = low
+		r.shedPriority = minPriority
+	}
+}
+
+// shed updates the shedding state from the current buffer depth and reports
+// whether m should be rejected. It is called concurrently by senders, so the
+// state is kept atomically.
+func (r *GenericRouter) shed(m msgMsg) bool {
+
+	if r.shedHigh <= 0 {
+		return false
+	}
+
+	depth := len(r.externalMsgChan)
+	switch {
+	case depth > r.shedHigh:
+		if atomic.CompareAndSwapInt32(&r.shedding, 0, 1) {
+			r.emit(Event{Type: SheddingStarted})
+		}
+	case depth < r.shedLow:
+		if atomic.CompareAndSwapInt32(&r.shedding, 1, 0) {
+			r.emit(Event{Type: SheddingStopped})
+		}
+	}
+
+	return atomic.LoadInt32(&r.shedding) == 1 && m.priority < r.shedPriority
+}
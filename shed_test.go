@@ -0,0 +1,64 @@
+package msgrouter
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// nextEvent returns the next event not of type skip.
+func nextEvent(t *testing.T, events <-chan Event, skip EventType) Event {
+	t.Helper()
+	for {
+		select {
+		case ev := <-events:
+			if ev.Type != skip {
+				return ev
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no event")
+		}
+	}
+}
+
+func TestSheddingDropsLowPriority(t *testing.T) {
+	events := make(chan Event, 16)
+	r := newRouter(t, WithShedding(4, 2, 5), WithEvents(events))
+	register(t, r, "src")
+	dest := register(t, r, "dest")
+	addRoute(t, r, "src", "dest")
+
+	// Flood the buffer past the watermark before the consume loop runs
+	for i := 0; i < 5; i++ {
+		if err := r.Send(msgMsg{src: "src", payload: i}); err != nil {
+			t.Fatalf("Send below the watermark: %v", err)
+		}
+	}
+	if err := r.Send(msgMsg{src: "src", payload: "low"}); !errors.Is(err, ErrShed) {
+		t.Fatalf("low priority Send = %v, want ErrShed", err)
+	}
+	if err := r.Send(msgMsg{src: "src", payload: "high", priority: 5}); err != nil {
+		t.Fatalf("high priority Send: %v", err)
+	}
+	if ev := nextEvent(t, events, DeliveryComplete); ev.Type != SheddingStarted {
+		t.Fatalf("event %+v, want SheddingStarted", ev)
+	}
+
+	// Shedding stops once the buffer drains
+	start(t, r)
+	eventually(t, func() bool { return dest.count() == 6 })
+	if err := r.Send(msgMsg{src: "src", payload: "after"}); err != nil {
+		t.Fatalf("Send after draining: %v", err)
+	}
+	if ev := nextEvent(t, events, DeliveryComplete); ev.Type != SheddingStopped {
+		t.Fatalf("event %+v, want SheddingStopped", ev)
+	}
+
+	stats, err := r.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if stats.MessagesDropped != 1 {
+		t.Fatalf("dropped %d messages, want the shed one", stats.MessagesDropped)
+	}
+}
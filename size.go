@@ -0,0 +1,82 @@
+package msgrouter
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrPayloadTooLarge is returned by Send when a payload's size exceeds the
+// router's maximum payload size.
+var ErrPayloadTooLarge = errors.New("Payload exceeds maximum size")
+
+// Sizer is implemented by payloads which know their own size in bytes.
+type Sizer interface {
+	Size() int
+}
+
+// WithMaxPayloadBytes makes Send reject payloads larger than n bytes with
+// ErrPayloadTooLarge. A payload's size is taken from its Sizer
+// implementation, its length if it is a []byte or string, or else the length
+// of its encoding by the codec set with WithCodec. Payloads which can't be
+// measured are let through. A zero n disables the check.
+func WithMaxPayloadBytes(n int) Option {
+	return func(r *GenericRouter) {
+		r.maxPayloadBytes = n
+	}
+}
+
+// WithCodec sets the codec the router uses to encode payloads, e.g. to measure
+// them for WithMaxPayloadBytes, and for the components registered through
+// ControlServer.
+func WithCodec(c Codec) Option {
+	return func(r *GenericRouter) {
+		r.codec = c
+	}
+}
+
+// checkPayloadSize returns ErrPayloadTooLarge if any payload in m exceeds the
+// maximum payload size.
+func (r *GenericRouter) checkPayloadSize(m msgMsg) error {
+
+	if r.maxPayloadBytes <= 0 {
+		return nil
+	}
+
+	payloads := m.batch
+	if payloads == nil {
+		payloads = []interface{}{m.payload}
+	}
+
+	for _, payload := range payloads {
+		n, ok := r.payloadSize(payload)
+		if ok && n > r.maxPayloadBytes {
+			return fmt.Errorf("%w: %d bytes", ErrPayloadTooLarge, n)
+		}
+	}
+	return nil
+
+}
+
+// payloadSize measures a payload in bytes. It reports false if the payload
+// can't be measured.
+func (r *GenericRouter) payloadSize(payload interface{}) (int, bool) {
+
+	switch p := payload.(type) {
+	case Sizer:
+		return p.Size(), true
+	case []byte:
+		return len(p), true
+	case string:
+		return len(p), true
+	}
+
+	if r.codec == nil {
+		return 0, false
+	}
+	b, err := r.codec.Encode(payload)
+	if err != nil {
+		return 0, false
+	}
+	return len(b), true
+
+}
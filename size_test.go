@@ -0,0 +1,28 @@
+package msgrouter
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMaxPayloadBytes(t *testing.T) {
+	r := newRouter(t, WithMaxPayloadBytes(4))
+	register(t, r, "src")
+	dest := register(t, r, "dest")
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	if err := r.Send(msgMsg{src: "src", payload: "too large"}); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Fatalf("Send of an oversized payload = %v, want ErrPayloadTooLarge", err)
+	}
+	if err := r.SendBatch("src", []interface{}{"ok", []byte("too large")}); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Fatalf("SendBatch with an oversized payload = %v, want ErrPayloadTooLarge", err)
+	}
+	if err := r.Send(msgMsg{src: "src", payload: "fits"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	eventually(t, func() bool { return dest.count() == 1 })
+	if got := dest.received(); got[0] != "fits" {
+		t.Fatalf("delivered %v, want [fits]", got)
+	}
+}
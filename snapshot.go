@@ -0,0 +1,242 @@
+package msgrouter
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// RouterSnapshot is a plain copy of the router's topology. Apart from the
+// predicates of Rules, which are shared with the router by reference, it
+// shares no memory with the router, so it may be kept or modified freely, and
+// serialized if it has no rules. Installing it with InstallTable, e.g. into a
+// router with the same components registered, reproduces the topology along
+// with how messages are distributed over it.
+type RouterSnapshot struct {
+	// Components are the registered component IDs, sorted.
+	Components []ComponentID
+	// Routes maps every source with routes to its destinations, in the
+	// order they are delivered to. Only the default namespace is included.
+	Routes map[ComponentID][]ComponentID
+	// RouteMeta holds the metadata of the routes which have any.
+	RouteMeta map[RouteKey]RouteMeta
+	// Modes are the delivery modes of sources not using Fanout.
+	Modes map[ComponentID]Mode
+	// Priorities are the priorities set with SetSourcePriority.
+	Priorities map[ComponentID]int
+	// Rules are the sources' rules, in evaluation order.
+	Rules map[ComponentID][]Rule
+}
+
+// RouteMeta is the metadata of a route in a RouterSnapshot.
+type RouteMeta struct {
+	Labels   map[string]string
+	Weight   int
+	Disabled bool
+	// Tags are the route's tags, sorted.
+	Tags []string
+}
+
+// routeMeta returns the metadata of rte, reporting false if it has none.
+func routeMeta(rte *route) (RouteMeta, bool) {
+	meta := RouteMeta{
+		Labels:   copyStringMap(rte.labels),
+		Weight:   rte.weight,
+		Disabled: rte.disabled,
+	}
+	for tag := range rte.tags {
+		meta.Tags = append(meta.Tags, tag)
+	}
+	sort.Strings(meta.Tags)
+	ok := meta.Labels != nil || meta.Weight != 0 || meta.Disabled || meta.Tags != nil
+	return meta, ok
+}
+
+// apply sets the metadata on rte.
+func (meta RouteMeta) apply(rte *route) {
+	rte.labels = copyStringMap(meta.Labels)
+	rte.weight = meta.Weight
+	rte.disabled = meta.Disabled
+	for _, tag := range meta.Tags {
+		if rte.tags == nil {
+			rte.tags = make(map[string]struct{})
+		}
+		rte.tags[tag] = struct{}{}
+	}
+}
+
+// Snapshot copies the router's registered components and routing table. The
+// snapshot is taken by the consume loop so it is consistent with concurrent
+// updates.
+func (r *GenericRouter) Snapshot() (RouterSnapshot, error) {
+	if !r.initialized() {
+		return RouterSnapshot{}, opError("Snapshot", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+
+	v, err := r.query(msgRt{op: SNAPSHOT})
+	if err != nil {
+		return RouterSnapshot{}, opError("Snapshot", ZeroComponentID, ZeroComponentID, err)
+	}
+	return v.(RouterSnapshot), nil
+}
+
+// snapshot builds a RouterSnapshot.
+func (r *GenericRouter) snapshot(m msgRt) {
+
+	s := RouterSnapshot{
+		Components: sortedIDs(r.rc.snapshot()),
+		Routes:     make(map[ComponentID][]ComponentID, len(r.rt)),
+		RouteMeta:  make(map[RouteKey]RouteMeta),
+		Modes:      make(map[ComponentID]Mode, len(r.modes)),
+		Priorities: make(map[ComponentID]int),
+		Rules:      make(map[ComponentID][]Rule, len(r.rules)),
+	}
+	for src, routesArray := range r.rt {
+		if len(routesArray) == 0 {
+			continue
+		}
+		dests := make([]ComponentID, len(routesArray))
+		for i, rte := range routesArray {
+			dests[i], _ = rte.dest.GetID()
+			if meta, ok := routeMeta(rte); ok {
+				s.RouteMeta[RouteKey{Src: src, Dest: dests[i]}] = meta
+			}
+		}
+		s.Routes[src] = dests
+	}
+
+	for src, mode := range r.modes {
+		s.Modes[src] = mode
+	}
+
+	r.priorityMu.RLock()
+	for src, p := range r.priorities {
+		s.Priorities[src] = p
+	}
+	r.priorityMu.RUnlock()
+
+	for src, rules := range r.rules {
+		for _, rl := range rules {
+			dest, _ := rl.dest.GetID()
+			s.Rules[src] = append(s.Rules[src], Rule{Predicate: rl.predicate, Dest: dest, Mode: rl.mode})
+		}
+	}
+
+	m.reply <- s
+}
+
+// InstallTable replaces the routing table of the default namespace, the
+// sources' delivery modes, priorities and rules with those of s in a single
+// operation, so senders see either the old or the new topology and never a
+// mix. Nothing of the old topology is kept: routes missing from s are
+// removed, along with their TTLs and coalescing, and the routes of s are
+// added in the order given, carrying the metadata in s.RouteMeta. Sources
+// missing from s.Modes, s.Priorities and s.Rules are reset to Fanout, no
+// priority and no rules. s.Components is ignored; every component s names
+// must already be registered, otherwise nothing is changed and the error
+// wraps ErrNotRegistered. Duplicate destinations of a source are added once.
+func (r *GenericRouter) InstallTable(s RouterSnapshot) error {
+	if !r.initialized() {
+		return opError("InstallTable", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+
+	return opError("InstallTable", ZeroComponentID, ZeroComponentID, r.exec(msgRt{op: INSTALLTABLE, snap: s}))
+}
+
+// installTable validates m.snap and swaps it in as the routing table.
+func (r *GenericRouter) installTable(m msgRt) error {
+
+	for src, dests := range m.snap.Routes {
+		if !r.routableSource(src) {
+			return fmt.Errorf("%w: %v", ErrNotRegistered, src)
+		}
+		for _, dest := range dests {
+			if !r.rc.has(dest) {
+				return fmt.Errorf("%w: %v", ErrNotRegistered, dest)
+			}
+		}
+	}
+	for src := range m.snap.Modes {
+		if !r.rc.has(src) {
+			return fmt.Errorf("%w: %v", ErrNotRegistered, src)
+		}
+	}
+	for src := range m.snap.Priorities {
+		if !r.rc.has(src) {
+			return fmt.Errorf("%w: %v", ErrNotRegistered, src)
+		}
+	}
+	for src, rules := range m.snap.Rules {
+		if !r.rc.has(src) {
+			return fmt.Errorf("%w: %v", ErrNotRegistered, src)
+		}
+		for _, rl := range rules {
+			if rl.Predicate == nil {
+				return errors.New("Rule has no predicate")
+			}
+			if !r.rc.has(rl.Dest) {
+				return fmt.Errorf("%w: %v", ErrNotRegistered, rl.Dest)
+			}
+		}
+	}
+
+	rt := routingTable{}
+	for src, dests := range m.snap.Routes {
+		seen := make(map[ComponentID]bool, len(dests))
+		for _, dest := range dests {
+			if seen[dest] {
+				continue
+			}
+			seen[dest] = true
+			c, _ := r.rc.get(dest)
+			rte := &route{
+				dest:      c,
+				inflight:  r.inflightCounter(dest),
+				delivered: r.routeCounter(src, dest),
+			}
+			m.snap.RouteMeta[RouteKey{Src: src, Dest: dest}].apply(rte)
+			r.insertRoute(rt, src, rte, 0)
+		}
+	}
+
+	rules := make(map[ComponentID][]rule, len(m.snap.Rules))
+	for src, rls := range m.snap.Rules {
+		for _, rl := range rls {
+			dest, _ := r.rc.get(rl.Dest)
+			rules[src] = append(rules[src], rule{
+				predicate: rl.Predicate,
+				dest:      dest,
+				mode:      rl.Mode,
+				delivered: r.routeCounter(src, rl.Dest),
+			})
+		}
+	}
+
+	for _, routesArray := range r.rt {
+		for _, rte := range routesArray {
+			rte.stop()
+		}
+	}
+	r.rt = rt
+	r.resetExpiryTimer()
+	r.rules = rules
+
+	r.modes = make(map[ComponentID]Mode, len(m.snap.Modes))
+	for src, mode := range m.snap.Modes {
+		if mode != Fanout {
+			r.modes[src] = mode
+		}
+	}
+	r.rrIndex = make(map[ComponentID]int)
+
+	r.priorityMu.Lock()
+	r.priorities = make(map[ComponentID]int, len(m.snap.Priorities))
+	for src, p := range m.snap.Priorities {
+		if p != 0 {
+			r.priorities[src] = p
+		}
+	}
+	r.priorityMu.Unlock()
+	return nil
+
+}
@@ -0,0 +1,140 @@
+package msgrouter
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshotIsIndependentCopy(t *testing.T) {
+	r := newRouter(t)
+	for _, id := range []ComponentID{"a", "b", "c"} {
+		register(t, r, id)
+	}
+	addRoute(t, r, "a", "b")
+	addRoute(t, r, "a", "c")
+	addRoute(t, r, "b", "c")
+	start(t, r)
+
+	snap, err := r.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if want := []ComponentID{"a", "b", "c"}; !reflect.DeepEqual(snap.Components, want) {
+		t.Fatalf("Components = %v, want %v", snap.Components, want)
+	}
+	want := map[ComponentID][]ComponentID{"a": {"b", "c"}, "b": {"c"}}
+	if !reflect.DeepEqual(snap.Routes, want) {
+		t.Fatalf("Routes = %v, want %v", snap.Routes, want)
+	}
+
+	// Changing the snapshot leaves the router alone and vice versa
+	snap.Components[0] = "z"
+	snap.Routes["a"][0] = "z"
+	delete(snap.Routes, "b")
+	if err := r.RemoveRoute(msgRt{src: "a", dest: "c"}); err != nil {
+		t.Fatalf("RemoveRoute: %v", err)
+	}
+	if dests, _ := r.GetRoutes("a"); !reflect.DeepEqual(dests, []ComponentID{"b"}) {
+		t.Fatalf("a routes to %v, want [b]", dests)
+	}
+	if dests, _ := r.GetRoutes("b"); !reflect.DeepEqual(dests, []ComponentID{"c"}) {
+		t.Fatalf("b routes to %v, want [c]", dests)
+	}
+	if ids, _ := r.ListComponents(); !reflect.DeepEqual(ids, []ComponentID{"a", "b", "c"}) {
+		t.Fatalf("registered %v, want [a b c]", ids)
+	}
+	if got := snap.Routes["a"]; !reflect.DeepEqual(got, []ComponentID{"z", "c"}) {
+		t.Fatalf("snapshot routes of a = %v after RemoveRoute, want [z c]", got)
+	}
+}
+
+func TestInstallTableReplacesRoutes(t *testing.T) {
+	r := newRouter(t)
+	for _, id := range []ComponentID{"a", "b", "c"} {
+		register(t, r, id)
+	}
+	addRoute(t, r, "a", "b")
+	addRoute(t, r, "b", "c")
+	start(t, r)
+
+	table := RouterSnapshot{Routes: map[ComponentID][]ComponentID{"c": {"a", "b"}}}
+	if err := r.InstallTable(table); err != nil {
+		t.Fatalf("InstallTable: %v", err)
+	}
+	snap, err := r.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if !reflect.DeepEqual(snap.Routes, table.Routes) {
+		t.Fatalf("routes %v after InstallTable, want only %v", snap.Routes, table.Routes)
+	}
+
+	// A table naming an unknown component changes nothing
+	bad := RouterSnapshot{Routes: map[ComponentID][]ComponentID{"a": {"unknown"}}}
+	if err := r.InstallTable(bad); !errors.Is(err, ErrNotRegistered) {
+		t.Fatalf("InstallTable with an unknown component = %v, want ErrNotRegistered", err)
+	}
+	if snap, _ := r.Snapshot(); !reflect.DeepEqual(snap.Routes, table.Routes) {
+		t.Fatalf("routes %v after a failed InstallTable, want %v", snap.Routes, table.Routes)
+	}
+}
+
+func TestInstallSnapshotForksWeightedRouting(t *testing.T) {
+	ids := []ComponentID{"src", "x", "y", "z"}
+	orig := newRouter(t)
+	for _, id := range ids {
+		register(t, orig, id)
+	}
+	for _, dest := range []ComponentID{"x", "y", "z"} {
+		addRoute(t, orig, "src", dest)
+	}
+	start(t, orig)
+	if err := orig.SetDeliveryMode("src", Weighted); err != nil {
+		t.Fatalf("SetDeliveryMode: %v", err)
+	}
+	if err := orig.SetRouteWeight("src", "x", 9); err != nil {
+		t.Fatalf("SetRouteWeight: %v", err)
+	}
+	if err := orig.DisableRoute("src", "z"); err != nil {
+		t.Fatalf("DisableRoute: %v", err)
+	}
+
+	snap, err := orig.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	fork := newRouter(t, WithSendTimeout(time.Second))
+	dests := make(map[ComponentID]*testComponent)
+	for _, id := range ids {
+		dests[id] = register(t, fork, id)
+	}
+	start(t, fork)
+	if err := fork.InstallTable(snap); err != nil {
+		t.Fatalf("InstallTable: %v", err)
+	}
+	forked, err := fork.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if !reflect.DeepEqual(forked, snap) {
+		t.Fatalf("fork snapshot %+v, want %+v", forked, snap)
+	}
+
+	// x gets about 9 in 10 messages, y the rest and the disabled z none
+	const n = 400
+	for i := 0; i < n; i++ {
+		if err := fork.Send(msgMsg{src: "src", payload: i}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	if err := fork.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	nx, ny, nz := dests["x"].count(), dests["y"].count(), dests["z"].count()
+	if nx+ny != n || nz != 0 || ny > n/4 {
+		t.Fatalf("fork delivered %d to x, %d to y and %d to z, want about %d, %d and 0", nx, ny, nz, n*9/10, n/10)
+	}
+}
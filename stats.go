@@ -0,0 +1,151 @@
+package msgrouter
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// rateSamples is the number of counter samples kept across the rate window.
+const rateSamples = 10
+
+// DefaultRateWindow is the sliding window over which Stats computes rates.
+const DefaultRateWindow = 10 * time.Second
+
+// Stats is a snapshot of the router's delivery counters. Deliveries are
+// counted per destination, so a message fanned out to three destinations
+// counts three times. Messages dropped before routing (full buffer, unknown
+// source, no routes) count once per payload.
+type Stats struct {
+	MessagesDelivered uint64
+	MessagesDropped   uint64
+	// DeliveredPerSec and DroppedPerSec are rates over the router's rate
+	// window.
+	DeliveredPerSec float64
+	DroppedPerSec   float64
+	// Latency is the histogram of enqueue to delivery latency.
+	Latency LatencyHistogram
+	// HighWater is the greatest depth of each buffer since the router was
+	// created or ResetHighWater was last called.
+	HighWater HighWater
+	// DeadLettersDropped counts dead letters lost because the dead letter
+	// queue was full.
+	DeadLettersDropped uint64
+	// DroppedByReason breaks MessagesDropped down by why messages were
+	// dropped. Reasons nothing was dropped for are left out.
+	DroppedByReason map[DeadLetterReason]uint64
+	// ActiveDeliveries is the number of delivery go routines running, see
+	// GenericRouter.ActiveDeliveries.
+	ActiveDeliveries int
+}
+
+// counters are updated atomically from delivery go routines. It is allocated
+// separately so the 64 bit fields are aligned on 32 bit platforms.
+type counters struct {
+	delivered          uint64
+	dropped            uint64
+	deadLettersDropped uint64
+	droppedBy          [numReasons]uint64
+	// active is the number of running delivery go routines
+	active int64
+}
+
+// rateSample is a point in time reading of the counters.
+type rateSample struct {
+	at        time.Time
+	delivered uint64
+	dropped   uint64
+}
+
+// WithRateWindow sets the sliding window over which Stats computes per
+// second rates. Defaults to DefaultRateWindow; non-positive durations are
+// ignored.
+func WithRateWindow(d time.Duration) Option {
+	return func(r *GenericRouter) {
+		if d > 0 {
+			r.rateWindow = d
+		}
+	}
+}
+
+// Stats returns the router's cumulative counters along with per second rates
+// computed over the rate window.
+func (r *GenericRouter) Stats() (Stats, error) {
+	if !r.initialized() {
+		return Stats{}, opError("Stats", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+
+	v, err := r.query(msgRt{op: STATS})
+	if err != nil {
+		return Stats{}, opError("Stats", ZeroComponentID, ZeroComponentID, err)
+	}
+	return v.(Stats), nil
+}
+
+// stats answers a Stats query from the consume loop.
+func (r *GenericRouter) stats(m msgRt) {
+
+	now := r.sample()
+	s := Stats{
+		MessagesDelivered:  now.delivered,
+		MessagesDropped:    now.dropped,
+		Latency:            r.latency.snapshot(),
+		HighWater:          r.highWater,
+		DeadLettersDropped: atomic.LoadUint64(&r.counters.deadLettersDropped),
+		DroppedByReason:    make(map[DeadLetterReason]uint64),
+		ActiveDeliveries:   r.ActiveDeliveries(),
+	}
+	for reason := range r.counters.droppedBy {
+		if n := atomic.LoadUint64(&r.counters.droppedBy[reason]); n > 0 {
+			s.DroppedByReason[DeadLetterReason(reason)] = n
+		}
+	}
+
+	if len(r.rates) > 0 {
+		oldest := r.rates[0]
+		if elapsed := now.at.Sub(oldest.at).Seconds(); elapsed > 0 {
+			s.DeliveredPerSec = float64(now.delivered-oldest.delivered) / elapsed
+			s.DroppedPerSec = float64(now.dropped-oldest.dropped) / elapsed
+		}
+	}
+
+	m.reply <- s
+}
+
+// sampleRates records a counter sample, discarding samples which have slid
+// out of the rate window. It runs in the consume loop on every rate tick.
+func (r *GenericRouter) sampleRates() {
+
+	r.rates = append(r.rates, r.sample())
+	if len(r.rates) > rateSamples+1 {
+		r.rates = append(r.rates[:0], r.rates[len(r.rates)-rateSamples-1:]...)
+	}
+
+}
+
+// sample reads the counters.
+func (r *GenericRouter) sample() rateSample {
+	return rateSample{
+		at:        r.clock.Now(),
+		delivered: atomic.LoadUint64(&r.counters.delivered),
+		dropped:   atomic.LoadUint64(&r.counters.dropped),
+	}
+}
+
+// countDelivered records n successful deliveries.
+func (r *GenericRouter) countDelivered(n int) {
+	atomic.AddUint64(&r.counters.delivered, uint64(n))
+}
+
+// countDropped records n messages dropped for reason.
+func (r *GenericRouter) countDropped(reason DeadLetterReason, n int) {
+	atomic.AddUint64(&r.counters.dropped, uint64(n))
+	atomic.AddUint64(&r.counters.droppedBy[reason], uint64(n))
+}
+
+// payloadCount returns the number of payloads carried by a message.
+func payloadCount(m msgMsg) int {
+	if m.batch != nil {
+		return len(m.batch)
+	}
+	return 1
+}
@@ -0,0 +1,67 @@
+package msgrouter
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestStatsRates(t *testing.T) {
+	r := newRouter(t, WithRateWindow(time.Second))
+	register(t, r, "src")
+	register(t, r, "dest")
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	// 50 deliveries and 10 drops within the window
+	for i := 0; i < 50; i++ {
+		if err := r.Send(msgMsg{src: "src", payload: i}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		if err := r.Send(msgMsg{src: "unknown", payload: i}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+
+	var stats Stats
+	eventually(t, func() bool {
+		var err error
+		if stats, err = r.Stats(); err != nil {
+			t.Fatalf("Stats: %v", err)
+		}
+		return stats.MessagesDelivered == 50 && stats.MessagesDropped == 10
+	})
+	// All of it happened less than a second into the window
+	if stats.DeliveredPerSec < 50 || math.Abs(stats.DeliveredPerSec-5*stats.DroppedPerSec) > 1 {
+		t.Fatalf("rates %.1f/s delivered and %.1f/s dropped, want over 50/s at five times the drop rate", stats.DeliveredPerSec, stats.DroppedPerSec)
+	}
+}
+
+func TestStatsDroppedByReason(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "lonely")
+	for _, m := range []msgMsg{
+		{src: "lonely", payload: 1},
+		{src: "lonely", payload: 2},
+		{src: "unknown", payload: 3},
+	} {
+		if err := r.Send(m); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	start(t, r)
+
+	var stats Stats
+	eventually(t, func() bool {
+		stats, _ = r.Stats()
+		return stats.MessagesDropped == 3
+	})
+	if n := stats.DroppedByReason[NoRoute]; n != 2 {
+		t.Fatalf("dropped %d messages for NoRoute, want 2", n)
+	}
+	if n := stats.DroppedByReason[UnregisteredSource]; n != 1 {
+		t.Fatalf("dropped %d messages for UnregisteredSource, want 1", n)
+	}
+}
@@ -0,0 +1,70 @@
+package msgrouter
+
+// AddTap mirrors everything src sends to observer in addition to src's
+// regular routes. Taps are delivered to after the regular destinations and a
+// failing tap never affects normal delivery.
+func (r *GenericRouter) AddTap(src ComponentID, observer ComponentID) error {
+	if !r.initialized() {
+		return opError("AddTap", src, observer, ErrNotInitialized)
+	}
+
+	return opError("AddTap", src, observer, r.exec(msgRt{op: ADDTAP, src: src, dest: observer}))
+}
+
+// RemoveTap stops mirroring src's traffic to observer.
+func (r *GenericRouter) RemoveTap(src ComponentID, observer ComponentID) error {
+	if !r.initialized() {
+		return opError("RemoveTap", src, observer, ErrNotInitialized)
+	}
+
+	return opError("RemoveTap", src, observer, r.exec(msgRt{op: REMOVETAP, src: src, dest: observer}))
+}
+
+// addTap appends the observer to the source's taps. Both source and observer
+// must be registered.
+func (r *GenericRouter) addTap(m msgRt) error {
+
+	if !r.rc.has(m.src) {
+		return ErrNotRegistered
+	}
+	observer, ok := r.rc.get(m.dest)
+	if !ok {
+		return ErrNotRegistered
+	}
+
+	r.taps[m.src] = append(r.taps[m.src], observer)
+	return nil
+
+}
+
+// removeTap removes the observer from the source's taps.
+func (r *GenericRouter) removeTap(m msgRt) error {
+
+	taps := r.taps[m.src]
+	for i, c := range taps {
+		if r.matchDest(c, m.dest) {
+			taps = append(taps[:i:i], taps[i+1:]...)
+			break
+		}
+	}
+
+	if len(taps) == 0 {
+		delete(r.taps, m.src)
+		return nil
+	}
+	r.taps[m.src] = taps
+	return nil
+
+}
+
+// deliverTap sends a mirrored message to an observer. Errors and panics are
+// swallowed so a tap can never disturb the delivery go routine.
+func (r *GenericRouter) deliverTap(observer Component, m msgMsg) {
+
+	defer func() {
+		recover()
+	}()
+
+	observer.Send(m.payload)
+
+}
@@ -0,0 +1,42 @@
+package msgrouter
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTapMirrorsTraffic(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	dest := register(t, r, "dest")
+	addRoute(t, r, "src", "dest")
+	tap := register(t, r, "tap")
+	// A failing tap must not disturb delivery either
+	failing := register(t, r, "failing")
+	failing.err = errors.New("tap failed")
+	start(t, r)
+	if err := r.AddTap("src", "tap"); err != nil {
+		t.Fatalf("AddTap: %v", err)
+	}
+	if err := r.AddTap("src", "failing"); err != nil {
+		t.Fatalf("AddTap: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := r.Send(msgMsg{src: "src", payload: i}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	eventually(t, func() bool { return dest.count() == 5 && tap.count() == 5 && failing.count() == 5 })
+
+	if err := r.RemoveTap("src", "tap"); err != nil {
+		t.Fatalf("RemoveTap: %v", err)
+	}
+	if err := r.Send(msgMsg{src: "src", payload: 5}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	eventually(t, func() bool { return failing.count() == 6 })
+	if tap.count() != 5 {
+		t.Fatalf("removed tap received %d messages, want 5", tap.count())
+	}
+}
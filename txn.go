@@ -0,0 +1,245 @@
+package msgrouter
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// OpType identifies the operation carried by an Op.
+type OpType int
+
+const (
+	// OpRegister registers Op.Component under Op.ID, or a generated ID when
+	// Op.ID is empty.
+	OpRegister OpType = iota
+	// OpUnregister unregisters Op.Component.
+	OpUnregister
+	// OpAddRoute adds a route from Op.Src to Op.Dest.
+	OpAddRoute
+	// OpRemoveRoute removes the route from Op.Src to Op.Dest.
+	OpRemoveRoute
+)
+
+// Op is a single operation in a transaction applied with Apply.
+type Op struct {
+	Type      OpType
+	Component Component
+	ID        ComponentID
+	Src       ComponentID
+	Dest      ComponentID
+}
+
+// ErrAlreadyRegistered is returned when registering under an ID which is
+// already in use.
+var ErrAlreadyRegistered = errors.New("Component ID already registered")
+
+// Apply applies a set of operations all-or-nothing. The whole batch is
+// validated and applied in a single consume loop iteration, so concurrent
+// senders never observe an intermediate state. If any operation would fail
+// none are applied and the error wraps a BatchError naming every offending
+// operation's index.
+//
+// Registrations and unregistrations take effect before the batch's route
+// operations, which see the registry as it is after the whole batch.
+// Components registered in the batch can be routed to by giving them an
+// explicit Op.ID. A component rejecting its ID in SetID fails the batch like
+// any other operation.
+func (r *GenericRouter) Apply(ops []Op) error {
+	if !r.initialized() {
+		return opError("Apply", ZeroComponentID, ZeroComponentID, ErrNotInitialized)
+	}
+
+	return opError("Apply", ZeroComponentID, ZeroComponentID, r.exec(msgRt{op: APPLY, ops: ops}))
+}
+
+// apply validates every op against a simulated view of the registry and
+// routing table, then applies them: the registry changes first, in order,
+// then the route changes, in order.
+func (r *GenericRouter) apply(m msgRt) error {
+
+	ids, err := r.validateOps(m.ops)
+	if err != nil {
+		return err
+	}
+	if err := r.applyRegistry(m.ops, ids); err != nil {
+		return err
+	}
+
+	for i, op := range m.ops {
+		switch op.Type {
+		case OpRegister:
+			r.notifyRegister(ids[i], op.Component)
+			r.flushPending(ids[i])
+		case OpUnregister:
+			delete(r.closedSources, ids[i])
+			r.notifyUnregister(ids[i])
+		case OpAddRoute:
+			r.addRoute(msgRt{src: op.Src, dest: op.Dest})
+		case OpRemoveRoute:
+			r.removeRoute(msgRt{src: op.Src, dest: op.Dest})
+		}
+	}
+
+	return nil
+}
+
+// applyRegistry makes the registry changes of ops, in order. These can still
+// fail after validation, when a component rejects its ID in SetID or a router
+// sharing the registry takes an ID first, in which case the changes already
+// made are undone and a BatchError naming the failed op returned.
+func (r *GenericRouter) applyRegistry(ops []Op, ids map[int]ComponentID) error {
+
+	var undo []func()
+	fail := func(i int, err error) error {
+		for j := len(undo) - 1; j >= 0; j-- {
+			undo[j]()
+		}
+		var batch BatchError
+		batch.fail(i, err)
+		return &batch
+	}
+
+	for i, op := range ops {
+		c, id := op.Component, ids[i]
+		switch op.Type {
+		case OpRegister:
+			prev, _ := c.GetID()
+			if err := c.SetID(id); err != nil {
+				return fail(i, err)
+			}
+			if !r.rc.add(id, c) {
+				c.SetID(prev)
+				return fail(i, ErrAlreadyRegistered)
+			}
+			undo = append(undo, func() {
+				r.rc.remove(id)
+				c.SetID(prev)
+			})
+		case OpUnregister:
+			// Guards already vetted the op in validateOps
+			if !r.rc.remove(id) {
+				return fail(i, ErrNotRegistered)
+			}
+			undo = append(undo, func() { r.rc.add(id, c) })
+		}
+	}
+	return nil
+
+}
+
+// validateOps checks that every op would succeed when applied by apply: the
+// registry ops in order, then the route ops in order against the resulting
+// registry. It returns the ID each OpRegister and OpUnregister acts on, or a
+// BatchError listing every op which would fail. A failing op is left out when
+// validating the ops after it.
+func (r *GenericRouter) validateOps(ops []Op) (map[int]ComponentID, error) {
+
+	// Registry membership and routes as they will be after each op
+	registered := r.rc.snapshot()
+	edges := make(map[ComponentID]map[ComponentID]bool)
+	routesOf := func(src ComponentID) map[ComponentID]bool {
+		if e, ok := edges[src]; ok {
+			return e
+		}
+		e := make(map[ComponentID]bool)
+		for _, rte := range r.rt[src] {
+			dest, _ := rte.dest.GetID()
+			e[dest] = true
+		}
+		edges[src] = e
+		return e
+	}
+
+	var batch BatchError
+	ids := make(map[int]ComponentID)
+	for i, op := range ops {
+		switch op.Type {
+		case OpRegister:
+			if op.Component == nil {
+				batch.fail(i, errors.New("no component to register"))
+				continue
+			}
+			id := op.ID
+			if id.IsZero() {
+				uuid, err := r.generateID()
+				if err != nil {
+					batch.fail(i, errors.New("could not generate UUID"))
+					continue
+				}
+				id = uuid
+			}
+			if _, ok := registered[id]; ok {
+				batch.fail(i, ErrAlreadyRegistered)
+				continue
+			}
+			if r.registerGuard != nil {
+				if err := r.registerGuard(op.Component); err != nil {
+					batch.fail(i, err)
+					continue
+				}
+			}
+			registered[id] = op.Component
+			ids[i] = id
+		case OpUnregister:
+			if op.Component == nil {
+				batch.fail(i, errors.New("no component to unregister"))
+				continue
+			}
+			id, err := op.Component.GetID()
+			if err != nil {
+				batch.fail(i, ErrNotRegistered)
+				continue
+			}
+			if _, ok := registered[id]; !ok {
+				batch.fail(i, ErrNotRegistered)
+				continue
+			}
+			if r.unregisterGuard != nil {
+				if err := r.unregisterGuard(op.Component); err != nil {
+					batch.fail(i, err)
+					continue
+				}
+			}
+			delete(registered, id)
+			ids[i] = id
+		case OpAddRoute, OpRemoveRoute:
+			// Checked below, once the registry ops are through
+		default:
+			batch.fail(i, fmt.Errorf("unknown op type %d", op.Type))
+		}
+	}
+
+	for i, op := range ops {
+		switch op.Type {
+		case OpAddRoute:
+			if _, ok := registered[op.Src]; !ok {
+				batch.fail(i, ErrNotRegistered)
+				continue
+			}
+			if _, ok := registered[op.Dest]; !ok {
+				batch.fail(i, ErrNotRegistered)
+				continue
+			}
+			routesOf(op.Src)[op.Dest] = true
+		case OpRemoveRoute:
+			if _, ok := registered[op.Src]; !ok {
+				batch.fail(i, ErrNotRegistered)
+				continue
+			}
+			if !routesOf(op.Src)[op.Dest] {
+				batch.fail(i, ErrNoRoute)
+				continue
+			}
+			delete(routesOf(op.Src), op.Dest)
+		}
+	}
+
+	if len(batch.Items) > 0 {
+		sort.Slice(batch.Items, func(i, j int) bool {
+			return batch.Items[i].Index < batch.Items[j].Index
+		})
+		return nil, &batch
+	}
+	return ids, nil
+}
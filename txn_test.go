@@ -0,0 +1,156 @@
+package msgrouter
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApplyIsAllOrNothing(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "a")
+	start(t, r)
+
+	err := r.Apply([]Op{
+		{Type: OpRegister, Component: &testComponent{}, ID: "b"},
+		{Type: OpAddRoute, Src: "a", Dest: "missing"},
+		{Type: OpAddRoute, Src: "a", Dest: "b"},
+	})
+	var batch *BatchError
+	if !errors.As(err, &batch) || len(batch.Items) != 1 || batch.Items[0].Index != 1 {
+		t.Fatalf("got %v, want a BatchError for op 1", err)
+	}
+	if !errors.Is(err, ErrNotRegistered) {
+		t.Fatalf("got %v, want op 1 failing with ErrNotRegistered", err)
+	}
+
+	if ids, _ := r.ListComponents(); len(ids) != 1 {
+		t.Fatalf("registered %v after failed Apply, want [a]", ids)
+	}
+	if dests, _ := r.GetRoutes("a"); len(dests) != 0 {
+		t.Fatalf("routes %v after failed Apply, want none", dests)
+	}
+}
+
+func TestApplyBatchErrorListsFailures(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "a")
+	register(t, r, "b")
+	start(t, r)
+
+	err := r.Apply([]Op{
+		{Type: OpAddRoute, Src: "a", Dest: "b"},
+		{Type: OpAddRoute, Src: "a", Dest: "missing"},
+		{Type: OpAddRoute, Src: "b", Dest: "a"},
+		{Type: OpRegister, Component: &testComponent{}, ID: "a"},
+	})
+
+	var batch *BatchError
+	if !errors.As(err, &batch) || len(batch.Items) != 2 {
+		t.Fatalf("got %v, want a BatchError with two items", err)
+	}
+	indices := []int{batch.Items[0].Index, batch.Items[1].Index}
+	if indices[0] > indices[1] {
+		indices[0], indices[1] = indices[1], indices[0]
+	}
+	if indices[0] != 1 || indices[1] != 3 {
+		t.Fatalf("BatchError lists ops %v, want 1 and 3", indices)
+	}
+	if !errors.Is(err, ErrNotRegistered) || !errors.Is(err, ErrAlreadyRegistered) {
+		t.Fatalf("got %v, want it to wrap each op's error", err)
+	}
+}
+
+func TestApplyRollsBackWhenSetIDFails(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "a")
+	start(t, r)
+	errRejected := errors.New("rejected")
+
+	ok := &testComponent{}
+	err := r.Apply([]Op{
+		{Type: OpRegister, Component: ok, ID: "b"},
+		{Type: OpAddRoute, Src: "a", Dest: "b"},
+		{Type: OpRegister, Component: &pickyComponent{reject: errRejected}, ID: "c"},
+	})
+
+	var batch *BatchError
+	if !errors.As(err, &batch) || len(batch.Items) != 1 || batch.Items[0].Index != 2 {
+		t.Fatalf("got %v, want a BatchError for op 2", err)
+	}
+	if !errors.Is(err, errRejected) {
+		t.Fatalf("got %v, want the SetID error", err)
+	}
+
+	ids, _ := r.ListComponents()
+	if len(ids) != 1 || ids[0] != "a" {
+		t.Fatalf("registered %v after failed Apply, want [a]", ids)
+	}
+	if id, _ := ok.GetID(); !id.IsZero() {
+		t.Fatalf("rolled back component kept ID %v", id)
+	}
+	if dests, _ := r.GetRoutes("a"); len(dests) != 0 {
+		t.Fatalf("routes %v after failed Apply, want none", dests)
+	}
+}
+
+// racingComponent registers another component under its ID through a second
+// router sharing the registry, the moment Apply hands it the ID.
+type racingComponent struct {
+	testComponent
+	other *GenericRouter
+}
+
+func (c *racingComponent) SetID(id ComponentID) error {
+	if !id.IsZero() {
+		c.other.RegisterWithID(id, &testComponent{})
+	}
+	return c.testComponent.SetID(id)
+}
+
+func TestApplyFailsWhenSharedRegistryTakesID(t *testing.T) {
+	reg := NewRegistry()
+	r := newRouter(t, WithRegistry(reg))
+	other := newRouter(t, WithRegistry(reg))
+	start(t, r)
+	start(t, other)
+
+	err := r.Apply([]Op{
+		{Type: OpRegister, Component: &testComponent{}, ID: "a"},
+		{Type: OpRegister, Component: &racingComponent{other: other}, ID: "b"},
+	})
+	if !errors.Is(err, ErrAlreadyRegistered) {
+		t.Fatalf("got %v, want ErrAlreadyRegistered", err)
+	}
+	if reg.has("a") {
+		t.Fatal("registration of a was not rolled back")
+	}
+}
+
+func TestApplyValidatesRoutesAgainstFinalRegistry(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "a")
+	b := register(t, r, "b")
+	start(t, r)
+
+	err := r.Apply([]Op{
+		{Type: OpAddRoute, Src: "a", Dest: "b"},
+		{Type: OpUnregister, Component: b},
+	})
+	if !errors.Is(err, ErrNotRegistered) {
+		t.Fatalf("got %v, want ErrNotRegistered", err)
+	}
+
+	b2 := &testComponent{}
+	err = r.Apply([]Op{
+		{Type: OpUnregister, Component: b},
+		{Type: OpRegister, Component: b2, ID: "b"},
+		{Type: OpAddRoute, Src: "a", Dest: "b"},
+	})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if err := r.Send(msgMsg{src: "a", payload: 1}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	eventually(t, func() bool { return b2.count() == 1 })
+}
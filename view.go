@@ -0,0 +1,38 @@
+package msgrouter
+
+// RouterView is a read-only handle on a router. It can query routes,
+// components and counters but exposes no way to mutate the router, making it
+// safe to hand to monitoring code.
+type RouterView interface {
+	ListRoutes() (string, error)
+	ListComponents() ([]ComponentID, error)
+	GetRoutes(src ComponentID) ([]ComponentID, error)
+	Stats() (Stats, error)
+}
+
+// routerView wraps a GenericRouter so callers can't type assert their way
+// back to the mutating methods.
+type routerView struct {
+	r *GenericRouter
+}
+
+// View returns a read-only RouterView of the router.
+func (r *GenericRouter) View() RouterView {
+	return routerView{r: r}
+}
+
+func (v routerView) ListRoutes() (string, error) {
+	return v.r.ListRoutes()
+}
+
+func (v routerView) ListComponents() ([]ComponentID, error) {
+	return v.r.ListComponents()
+}
+
+func (v routerView) GetRoutes(src ComponentID) ([]ComponentID, error) {
+	return v.r.GetRoutes(src)
+}
+
+func (v routerView) Stats() (Stats, error) {
+	return v.r.Stats()
+}
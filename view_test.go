@@ -0,0 +1,41 @@
+package msgrouter
+
+import "testing"
+
+func TestView(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "src")
+	register(t, r, "dest")
+	addRoute(t, r, "src", "dest")
+	start(t, r)
+
+	v := r.View()
+	if _, ok := v.(*GenericRouter); ok {
+		t.Fatal("View can be asserted back to the router")
+	}
+	if _, ok := v.(interface {
+		AddRoute(msgRt) (bool, error)
+	}); ok {
+		t.Fatal("View has AddRoute")
+	}
+	if _, ok := v.(interface {
+		RegisterComponent(msgReg) error
+	}); ok {
+		t.Fatal("View has RegisterComponent")
+	}
+
+	ids, err := v.ListComponents()
+	if err != nil || len(ids) != 2 {
+		t.Fatalf("ListComponents = %v, %v, want two components", ids, err)
+	}
+	dests, err := v.GetRoutes("src")
+	if err != nil || len(dests) != 1 || dests[0] != "dest" {
+		t.Fatalf("GetRoutes = %v, %v, want [dest]", dests, err)
+	}
+	if routes, err := v.ListRoutes(); err != nil || routes != "src -> dest\n" {
+		t.Fatalf("ListRoutes = %q, %v", routes, err)
+	}
+	if _, err := v.Stats(); err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package msgrouter
+
+import (
+	"errors"
+	"math/rand"
+)
+
+// SetRouteWeight sets the weight of the route from src to dest for the
+// Weighted delivery mode, in which a route with weight 3 receives three times
+// as many messages as one with weight 1. Routes have weight 1 until set. The
+// new weight applies from the next message the consume loop routes.
+func (r *GenericRouter) SetRouteWeight(src, dest ComponentID, weight int) error {
+	if !r.initialized() {
+		return opError("SetRouteWeight", src, dest, ErrNotInitialized)
+	}
+	if weight < 1 {
+		return opError("SetRouteWeight", src, dest, errors.New("Route weight must be positive"))
+	}
+
+	return opError("SetRouteWeight", src, dest, r.exec(msgRt{op: SETWEIGHT, src: src, dest: dest, weight: weight}))
+}
+
+// setRouteWeight stores a route's weight.
+func (r *GenericRouter) setRouteWeight(m msgRt) error {
+
+	rte, err := r.findRoute(m.src, m.dest)
+	if err != nil {
+		return err
+	}
+
+	rte.weight = m.weight
+	return nil
+
+}
+
+// selectionWeight returns the route's weight, 1 if none was set.
+func (rte route) selectionWeight() int {
+	if rte.weight == 0 {
+		return 1
+	}
+	return rte.weight
+}
+
+// selectWeighted chooses a single route at random, with a probability
+// proportional to its weight.
+func selectWeighted(routes []route) []route {
+
+	total := 0
+	for _, rte := range routes {
+		total += rte.selectionWeight()
+	}
+	if total == 0 {
+		return nil
+	}
+
+	n := rand.Intn(total)
+	for i, rte := range routes {
+		n -= rte.selectionWeight()
+		if n < 0 {
+			return routes[i : i+1]
+		}
+	}
+	return nil
+
+}
@@ -0,0 +1,44 @@
+package msgrouter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetRouteWeightShiftsDistribution(t *testing.T) {
+	r := newRouter(t, WithSendTimeout(time.Second))
+	register(t, r, "src")
+	x := register(t, r, "x")
+	y := register(t, r, "y")
+	addRoute(t, r, "src", "x")
+	addRoute(t, r, "src", "y")
+	r.modes["src"] = Weighted
+	start(t, r)
+
+	const n = 400
+	send := func(total int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			if err := r.Send(msgMsg{src: "src", payload: i}); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+		}
+		eventually(t, func() bool { return x.count()+y.count() == total })
+	}
+
+	// Equal weights split the messages roughly evenly. The bounds are wide
+	// enough that random selection practically never misses them.
+	send(n)
+	if nx, ny := x.count(), y.count(); nx < n/4 || ny < n/4 {
+		t.Fatalf("equal weights delivered %d to x and %d to y, want about %d each", nx, ny, n/2)
+	}
+
+	if err := r.SetRouteWeight("src", "x", 99); err != nil {
+		t.Fatalf("SetRouteWeight: %v", err)
+	}
+	before := y.count()
+	send(2 * n)
+	if ny := y.count() - before; ny > n/10 {
+		t.Fatalf("y received %d of %d messages at weight 1 against 99, want about %d", ny, n, n/100)
+	}
+}
@@ -0,0 +1,68 @@
+package msgrouter
+
+// SendWhere sends m like Send, but only to the destinations of m's source for
+// which pred returns true. pred narrows the source's routes, rules, virtual
+// routes, wildcard routes and pending destinations for this message alone;
+// the delivery mode then chooses among the routes left. Disabled routes stay
+// disabled and taps still observe the message. If pred leaves no destination
+// the message is dead lettered with ErrNoRoute. pred is called from the
+// consume loop and must not call back into the router.
+func (r *GenericRouter) SendWhere(m msgMsg, pred func(dest ComponentID) bool) error {
+
+	m.where = pred
+	return r.Send(m)
+
+}
+
+// selects reports whether the message's predicate selects dest. Messages
+// without a predicate select every destination.
+func (m msgMsg) selects(dest Component) bool {
+
+	if m.where == nil {
+		return true
+	}
+	id, _ := dest.GetID()
+	return m.where(id)
+
+}
+
+// narrow drops the routes and rules of d which m's predicate doesn't select,
+// recording them as skipped. It reports false if no route or rule is left.
+func (m msgMsg) narrow(d *delivery) bool {
+
+	if m.where == nil {
+		return true
+	}
+
+	routes := d.routes[:0]
+	for _, rte := range d.routes {
+		if !m.selects(rte.dest) {
+			if d.detail != nil {
+				id, _ := rte.dest.GetID()
+				d.skipped = append(d.skipped, DeliveryResult{Dest: id, Skipped: "not selected by predicate"})
+			}
+			continue
+		}
+		routes = append(routes, rte)
+	}
+	d.routes = routes
+
+	rules := d.rules[:0]
+	for _, rl := range d.rules {
+		if m.selects(rl.dest) {
+			rules = append(rules, rl)
+		}
+	}
+	d.rules = rules
+
+	wildcard := d.wildcard[:0]
+	for _, rte := range d.wildcard {
+		if m.selects(rte.dest) {
+			wildcard = append(wildcard, rte)
+		}
+	}
+	d.wildcard = wildcard
+
+	return len(d.routes) > 0 || len(d.rules) > 0 || len(d.wildcard) > 0
+
+}
@@ -0,0 +1,43 @@
+package msgrouter
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSendWhere(t *testing.T) {
+	r := newRouter(t, WithDeadLetterRing(1))
+	register(t, r, "src")
+	dests := make(map[ComponentID]*testComponent)
+	for _, id := range []ComponentID{"eu-1", "eu-2", "us-1"} {
+		dests[id] = register(t, r, id)
+		addRoute(t, r, "src", id)
+	}
+	start(t, r)
+
+	eu := func(dest ComponentID) bool { return strings.HasPrefix(string(dest), "eu-") }
+	if err := r.SendWhere(msgMsg{src: "src", payload: "eu only"}, eu); err != nil {
+		t.Fatalf("SendWhere: %v", err)
+	}
+	none := func(dest ComponentID) bool { return false }
+	if err := r.SendWhere(msgMsg{src: "src", payload: "nowhere"}, none); err != nil {
+		t.Fatalf("SendWhere: %v", err)
+	}
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	for id, dest := range dests {
+		want := 0
+		if eu(id) {
+			want = 1
+		}
+		if n := dest.count(); n != want {
+			t.Fatalf("%v received %d messages, want %d", id, n, want)
+		}
+	}
+	if dls := r.DeadLetters(); len(dls) != 1 || dls[0].Payload != "nowhere" || dls[0].Reason != NoRoute {
+		t.Fatalf("dead letters %+v, want the message selecting nothing", dls)
+	}
+}
@@ -0,0 +1,31 @@
+package msgrouter
+
+// WildcardSource is the source of routes which receive every message, from
+// any source, e.g. for an audit sink. Add such a route with AddRoute using
+// WildcardSource as src; it is removed with RemoveRoute the same way.
+// Wildcard routes are delivered to in addition to, and after, the routes,
+// rules and delivery mode of the message's own source, and a destination
+// never receives its own messages through one. The ID can't be registered.
+const WildcardSource ComponentID = "*"
+
+// routableSource reports whether routes may be added from or removed for src.
+func (r *GenericRouter) routableSource(src ComponentID) bool {
+	return src == WildcardSource || r.rc.has(src)
+}
+
+// wildcardRoutes snapshots the enabled wildcard routes for a message from src.
+func (r *GenericRouter) wildcardRoutes(src ComponentID) []route {
+
+	var routes []route
+	for _, rte := range r.rt[WildcardSource] {
+		if rte.disabled {
+			continue
+		}
+		if dest, _ := rte.dest.GetID(); dest == src {
+			continue
+		}
+		routes = append(routes, *rte)
+	}
+	return routes
+
+}
@@ -0,0 +1,30 @@
+package msgrouter
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestWildcardSource(t *testing.T) {
+	r := newRouter(t)
+	register(t, r, "a")
+	register(t, r, "b")
+	x := register(t, r, "x")
+	audit := register(t, r, "audit")
+	addRoute(t, r, "a", "x")
+	addRoute(t, r, WildcardSource, "audit")
+	start(t, r)
+
+	for _, src := range []ComponentID{"a", "b", "audit"} {
+		r.SendDetailed(msgMsg{src: src, payload: "from " + string(src)})
+	}
+
+	got := audit.received()
+	sort.Slice(got, func(i, j int) bool { return got[i].(string) < got[j].(string) })
+	if len(got) != 2 || got[0] != "from a" || got[1] != "from b" {
+		t.Fatalf("audit received %v, want the messages of a and b", got)
+	}
+	if n := x.count(); n != 1 {
+		t.Fatalf("x received %d messages, want 1", n)
+	}
+}